@@ -0,0 +1,64 @@
+package control
+
+import (
+	"time"
+
+	"github.com/spuf/mockable-server/storage"
+)
+
+// Callbacks are deliveries of callbacks sent by mock server after serving responses.
+type Callbacks struct {
+	queues *storage.Queues
+}
+
+func NewCallbacks(queues *storage.Queues) *Callbacks {
+	return &Callbacks{queues: queues}
+}
+
+type Delivery struct {
+	ID         string `json:"id"`
+	ResponseID string `json:"responseId"`
+	RequestID  string `json:"requestId"`
+	Method     string `json:"method"`
+	Url        string `json:"url"`
+	// Status is of the last attempt, it is 0 if no response was received.
+	Status   int           `json:"status"`
+	Error    string        `json:"error"`
+	Attempts int           `json:"attempts"`
+	SentAt   time.Time     `json:"sentAt"`
+	Latency  DelayDuration `json:"latency"`
+}
+
+// List returns deliveries in order they were finished.
+func (c *Callbacks) List(arg Scope, reply *[]Delivery) error {
+	queues, ok := arg.lookup(c.queues)
+	if !ok {
+		return nil
+	}
+
+	for _, delivery := range queues.Deliveries() {
+		*reply = append(*reply, Delivery{
+			ID:         delivery.ID,
+			ResponseID: delivery.ResponseID,
+			RequestID:  delivery.RequestID,
+			Method:     delivery.Method,
+			Url:        delivery.URL,
+			Status:     delivery.Status,
+			Error:      delivery.Error,
+			Attempts:   delivery.Attempts,
+			SentAt:     delivery.SentAt,
+			Latency:    DelayDuration{delivery.Latency},
+		})
+	}
+
+	return nil
+}
+
+func (c *Callbacks) Clear(arg Scope, reply *bool) error {
+	if queues, ok := arg.lookup(c.queues); ok {
+		queues.ClearDeliveries()
+	}
+	*reply = true
+
+	return nil
+}
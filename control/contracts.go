@@ -0,0 +1,43 @@
+package control
+
+import (
+	"fmt"
+
+	"github.com/spuf/mockable-server/openapi"
+	"github.com/spuf/mockable-server/storage"
+)
+
+// Contracts check requests received by mock server against OpenAPI documents.
+type Contracts struct {
+	queues *storage.Queues
+}
+
+func NewContracts(queues *storage.Queues) *Contracts {
+	return &Contracts{queues: queues}
+}
+
+// Set replaces contract requests to the scope are checked against, it is not persisted in journal.
+func (c *Contracts) Set(arg OpenAPIArgs, reply *bool) error {
+	contract, err := openapi.NewContract([]byte(arg.Document))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrValidation, err)
+	}
+	queues, err := arg.queues(c.queues)
+	if err != nil {
+		return err
+	}
+	queues.SetContract(contract)
+	*reply = true
+
+	return nil
+}
+
+// Clear removes contract of the scope, requests to it are checked against contract of the enclosing scope if any.
+func (c *Contracts) Clear(arg Scope, reply *bool) error {
+	if queues, ok := arg.lookup(c.queues); ok {
+		queues.SetContract(nil)
+	}
+	*reply = true
+
+	return nil
+}
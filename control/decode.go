@@ -0,0 +1,157 @@
+package control
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// maxDecodedSize limits decompressed body to protect from compression bombs.
+const maxDecodedSize = 64 << 20
+
+// Decoded is parsed representation of request body.
+type Decoded struct {
+	// Body is decompressed according to Content-Encoding, it is set only if the header is present.
+	Body         string `json:"body,omitempty"`
+	IsBodyBase64 bool   `json:"isBodyBase64,omitempty"`
+
+	JSON      interface{} `json:"json,omitempty"`
+	Form      url.Values  `json:"form,omitempty"`
+	Multipart []Part      `json:"multipart,omitempty"`
+	// Error explains why body could not be decoded.
+	Error string `json:"error,omitempty"`
+}
+
+// Part is multipart body part, its content is base64 encoded.
+type Part struct {
+	Name        string  `json:"name"`
+	Filename    string  `json:"filename,omitempty"`
+	ContentType string  `json:"contentType,omitempty"`
+	Headers     Headers `json:"headers"`
+	Content     string  `json:"content"`
+}
+
+func decodeBody(headers http.Header, body string) *Decoded {
+	decoded := &Decoded{}
+
+	if encoding := headers.Get("Content-Encoding"); encoding != "" {
+		data, err := decompress(encoding, []byte(body))
+		if err != nil {
+			decoded.Error = err.Error()
+			return decoded
+		}
+		body = string(data)
+		decoded.Body = body
+		plain := headers.Clone()
+		plain.Del("Content-Encoding")
+		if isBinary(plain, body) {
+			decoded.Body = base64.StdEncoding.EncodeToString(data)
+			decoded.IsBodyBase64 = true
+		}
+	}
+
+	mediaType, params, err := mime.ParseMediaType(headers.Get("Content-Type"))
+	if err != nil {
+		return decoded
+	}
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		if err := json.Unmarshal([]byte(body), &decoded.JSON); err != nil {
+			decoded.Error = fmt.Sprintf("invalid JSON: %v", err)
+		}
+	case mediaType == "application/x-www-form-urlencoded":
+		decoded.Form, err = url.ParseQuery(body)
+		if err != nil {
+			decoded.Error = fmt.Sprintf("invalid form: %v", err)
+		}
+	case strings.HasPrefix(mediaType, "multipart/"):
+		decoded.Multipart, err = parseMultipart(body, params["boundary"])
+		if err != nil {
+			decoded.Error = fmt.Sprintf("invalid multipart: %v", err)
+		}
+	}
+
+	return decoded
+}
+
+// decompress removes content codings in reverse order of their application.
+func decompress(encoding string, data []byte) ([]byte, error) {
+	codings := strings.Split(encoding, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+		var r io.Reader
+		var err error
+		switch coding {
+		case "identity", "":
+			continue
+		case "gzip", "x-gzip":
+			r, err = gzip.NewReader(bytes.NewReader(data))
+		case "br":
+			r = brotli.NewReader(bytes.NewReader(data))
+		case "deflate":
+			// Deflate is zlib stream by the standard, but some clients send raw deflate.
+			r, err = zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				r, err = flate.NewReader(bytes.NewReader(data)), nil
+			}
+		default:
+			return nil, fmt.Errorf("unsupported content encoding %s", coding)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s body: %w", coding, err)
+		}
+
+		var buf bytes.Buffer
+		n, err := io.Copy(&buf, io.LimitReader(r, maxDecodedSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s body: %w", coding, err)
+		}
+		if n > maxDecodedSize {
+			return nil, fmt.Errorf("decoded body exceeds %d bytes", maxDecodedSize)
+		}
+		data = buf.Bytes()
+	}
+
+	return data, nil
+}
+
+func parseMultipart(body, boundary string) ([]Part, error) {
+	if boundary == "" {
+		return nil, fmt.Errorf("boundary is missing")
+	}
+
+	parts := []Part{}
+	r := multipart.NewReader(strings.NewReader(body), boundary)
+	for {
+		p, err := r.NextRawPart()
+		if err == io.EOF {
+			return parts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(p)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, Part{
+			Name:        p.FormName(),
+			Filename:    p.FileName(),
+			ContentType: p.Header.Get("Content-Type"),
+			Headers:     fromHttpHeaders(http.Header(p.Header)),
+			Content:     base64.StdEncoding.EncodeToString(content),
+		})
+	}
+}
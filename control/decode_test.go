@@ -0,0 +1,114 @@
+package control
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestDecodeBody(t *testing.T) {
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	_, _ = gw.Write([]byte(`{"id": 1}`))
+	_ = gw.Close()
+
+	var deflated bytes.Buffer
+	fw, _ := flate.NewWriter(&deflated, flate.BestSpeed)
+	_, _ = fw.Write([]byte("a=1&a=2&b=x"))
+	_ = fw.Close()
+
+	var brotlied bytes.Buffer
+	bw := brotli.NewWriter(&brotlied)
+	_, _ = bw.Write([]byte(`{"id": 2}`))
+	_ = bw.Close()
+
+	multipartBody := "--XYZ\r\n" +
+		"Content-Disposition: form-data; name=\"title\"\r\n\r\n" +
+		"Hello\r\n" +
+		"--XYZ\r\n" +
+		"Content-Disposition: form-data; name=\"file\"; filename=\"a.bin\"\r\n" +
+		"Content-Type: application/octet-stream\r\n\r\n" +
+		"\x00\xff\r\n" +
+		"--XYZ--\r\n"
+
+	for _, tt := range [...]struct {
+		name    string
+		headers http.Header
+		body    string
+		want    *Decoded
+	}{
+		{
+			name:    "gzip JSON",
+			headers: http.Header{"Content-Type": {"application/json"}, "Content-Encoding": {"gzip"}},
+			body:    gzipped.String(),
+			want:    &Decoded{Body: `{"id": 1}`, JSON: map[string]interface{}{"id": float64(1)}},
+		},
+		{
+			name:    "raw deflate form",
+			headers: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}, "Content-Encoding": {"deflate"}},
+			body:    deflated.String(),
+			want:    &Decoded{Body: "a=1&a=2&b=x", Form: url.Values{"a": {"1", "2"}, "b": {"x"}}},
+		},
+		{
+			name:    "brotli JSON",
+			headers: http.Header{"Content-Type": {"application/json"}, "Content-Encoding": {"br"}},
+			body:    brotlied.String(),
+			want:    &Decoded{Body: `{"id": 2}`, JSON: map[string]interface{}{"id": float64(2)}},
+		},
+		{
+			name:    "invalid brotli",
+			headers: http.Header{"Content-Type": {"application/json"}, "Content-Encoding": {"br"}},
+			body:    "\xff\xff",
+			want:    &Decoded{Error: "invalid br body: brotli: PADDING_2"},
+		},
+		{
+			name:    "multipart",
+			headers: http.Header{"Content-Type": {"multipart/form-data; boundary=XYZ"}},
+			body:    multipartBody,
+			want: &Decoded{Multipart: []Part{
+				{
+					Name:    "title",
+					Headers: Headers{"Content-Disposition": {`form-data; name="title"`}},
+					Content: "SGVsbG8=",
+				},
+				{
+					Name:        "file",
+					Filename:    "a.bin",
+					ContentType: "application/octet-stream",
+					Headers:     Headers{"Content-Disposition": {`form-data; name="file"; filename="a.bin"`}, "Content-Type": {"application/octet-stream"}},
+					Content:     "AP8=",
+				},
+			}},
+		},
+		{
+			name:    "unsupported encoding",
+			headers: http.Header{"Content-Type": {"application/json"}, "Content-Encoding": {"zstd"}},
+			body:    "\x28\xb5\x2f\xfd",
+			want:    &Decoded{Error: "unsupported content encoding zstd"},
+		},
+		{
+			name:    "invalid JSON",
+			headers: http.Header{"Content-Type": {"application/vnd.api+json"}},
+			body:    "{",
+			want:    &Decoded{Error: "invalid JSON: unexpected end of JSON input"},
+		},
+		{
+			name:    "plain text",
+			headers: http.Header{"Content-Type": {"text/plain"}},
+			body:    "Hello",
+			want:    &Decoded{},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeBody(tt.headers, tt.body); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mismatch decoded:\n got: %#v\nwant: %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package control
+
+import (
+	"github.com/spuf/mockable-server/storage"
+)
+
+// Failures are unmatched requests recorded by mock server in strict mode.
+type Failures struct {
+	queues *storage.Queues
+}
+
+func NewFailures(queues *storage.Queues) *Failures {
+	return &Failures{queues: queues}
+}
+
+func (f *Failures) List(arg Scope, reply *[]UnmatchedRequest) error {
+	queues, ok := arg.lookup(f.queues)
+	if !ok {
+		return nil
+	}
+
+	for _, msg := range queues.Failures() {
+		request, err := unmatchedFromMessage(msg)
+		if err != nil {
+			return err
+		}
+		*reply = append(*reply, *request)
+	}
+
+	return nil
+}
+
+func (f *Failures) Clear(arg Scope, reply *bool) error {
+	if queues, ok := arg.lookup(f.queues); ok {
+		queues.ClearFailures()
+	}
+	*reply = true
+
+	return nil
+}
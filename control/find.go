@@ -0,0 +1,123 @@
+package control
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spuf/mockable-server/storage"
+)
+
+// Filter selects captured requests, its empty fields match anything.
+type Filter struct {
+	Match storage.Matcher `json:"match"`
+	// Since and Until bound receive time of requests, Since is inclusive and Until is exclusive.
+	Since *time.Time `json:"since"`
+	Until *time.Time `json:"until"`
+}
+
+type FindArgs struct {
+	ListArgs
+	Filter
+	Offset      int  `json:"offset"`
+	Limit       int  `json:"limit"`
+	NewestFirst bool `json:"newestFirst"`
+}
+
+type RemoveArgs struct {
+	Scope
+	Filter
+}
+
+func (f Filter) validate() error {
+	if err := validateMatcher(f.Match); err != nil {
+		return err
+	}
+	if f.Since != nil && f.Until != nil && !f.Since.Before(*f.Until) {
+		return fmt.Errorf("%w: since %s must be before until %s", ErrValidation, f.Since.Format(time.RFC3339Nano), f.Until.Format(time.RFC3339Nano))
+	}
+
+	return nil
+}
+
+func (f Filter) matches(msg storage.Message) bool {
+	if f.Since != nil || f.Until != nil {
+		if !msg.IsRequest() || msg.Request.ReceivedAt.IsZero() {
+			return false
+		}
+		receivedAt := msg.Request.ReceivedAt
+		if f.Since != nil && receivedAt.Before(*f.Since) {
+			return false
+		}
+		if f.Until != nil && !receivedAt.Before(*f.Until) {
+			return false
+		}
+	}
+
+	return f.Match.Matches(msg)
+}
+
+func (r *Requests) Find(arg FindArgs, reply *[]Request) error {
+	if err := arg.Filter.validate(); err != nil {
+		return err
+	}
+	if arg.Offset < 0 || arg.Limit < 0 {
+		return fmt.Errorf("%w: offset %d and limit %d must not be negative", ErrValidation, arg.Offset, arg.Limit)
+	}
+
+	queues, ok := arg.lookup(r.queues)
+	if !ok {
+		return nil
+	}
+
+	list := queues.Requests.List()
+	if arg.NewestFirst {
+		for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+			list[i], list[j] = list[j], list[i]
+		}
+	}
+
+	skipped := 0
+	for _, msg := range list {
+		if arg.Limit > 0 && len(*reply) >= arg.Limit {
+			break
+		}
+		if !arg.Filter.matches(msg) {
+			continue
+		}
+		if skipped < arg.Offset {
+			skipped++
+			continue
+		}
+
+		request, err := requestFromMessage(msg)
+		if err != nil {
+			return err
+		}
+		arg.extend(request, msg)
+		*reply = append(*reply, *request)
+	}
+
+	return nil
+}
+
+// Remove deletes only matching requests and returns them.
+func (r *Requests) Remove(arg RemoveArgs, reply *[]Request) error {
+	if err := arg.Filter.validate(); err != nil {
+		return err
+	}
+
+	queues, ok := arg.lookup(r.queues)
+	if !ok {
+		return nil
+	}
+
+	for _, msg := range queues.Requests.Remove(arg.Filter.matches) {
+		request, err := requestFromMessage(msg)
+		if err != nil {
+			return err
+		}
+		*reply = append(*reply, *request)
+	}
+
+	return nil
+}
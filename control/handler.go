@@ -1,10 +1,14 @@
 package control
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/rpc"
+	"strings"
 
+	"github.com/spuf/mockable-server/har"
+	"github.com/spuf/mockable-server/openapi"
 	"github.com/spuf/mockable-server/storage"
 )
 
@@ -15,10 +19,40 @@ type control struct {
 
 func NewHandler(queues *storage.Queues) http.Handler {
 	rpcServer := rpc.NewServer()
-	if err := rpcServer.Register(NewResponses(queues.Responses)); err != nil {
+	if err := rpcServer.Register(NewResponses(queues)); err != nil {
 		panic(err)
 	}
-	if err := rpcServer.Register(NewRequests(queues.Requests)); err != nil {
+	if err := rpcServer.Register(NewRequests(queues)); err != nil {
+		panic(err)
+	}
+	if err := rpcServer.Register(NewHosts(queues)); err != nil {
+		panic(err)
+	}
+	if err := rpcServer.Register(NewSessions(queues)); err != nil {
+		panic(err)
+	}
+	if err := rpcServer.Register(NewState(queues)); err != nil {
+		panic(err)
+	}
+	if err := rpcServer.Register(NewLimits(queues)); err != nil {
+		panic(err)
+	}
+	if err := rpcServer.Register(NewStats(queues)); err != nil {
+		panic(err)
+	}
+	if err := rpcServer.Register(NewFailures(queues)); err != nil {
+		panic(err)
+	}
+	if err := rpcServer.Register(NewScenarios(queues)); err != nil {
+		panic(err)
+	}
+	if err := rpcServer.Register(NewCallbacks(queues)); err != nil {
+		panic(err)
+	}
+	if err := rpcServer.Register(NewRateLimits(queues)); err != nil {
+		panic(err)
+	}
+	if err := rpcServer.Register(NewContracts(queues)); err != nil {
 		panic(err)
 	}
 
@@ -37,6 +71,21 @@ func (c *control) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if r.URL.Path == "/state" {
+		c.serveState(w, r)
+		return
+	}
+
+	if r.URL.Path == "/snippets" {
+		c.serveSnippets(w, r)
+		return
+	}
+
+	if r.URL.Path == "/har" {
+		c.serveHAR(w, r)
+		return
+	}
+
 	if r.URL.Path != "/rpc/1" {
 		status := http.StatusNotFound
 		http.Error(w, http.StatusText(status), status)
@@ -51,3 +100,100 @@ func (c *control) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	c.jsonrpc.ServeHTTP(w, r)
 }
+
+// serveSnippets renders captured requests as curl commands, or Go code with format=go, on GET.
+// Session and host query parameters select their queues, and id selects one request.
+func (c *control) serveSnippets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		status := http.StatusMethodNotAllowed
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	comment := "#"
+	switch format {
+	case "", SnippetCurl:
+		format = SnippetCurl
+	case SnippetGo:
+		comment = "//"
+	default:
+		http.Error(w, fmt.Sprintf("format %q must be %s or %s", format, SnippetCurl, SnippetGo), http.StatusBadRequest)
+		return
+	}
+
+	var requests []Request
+	args := ListArgs{Scope: Scope{Session: query.Get("session"), Host: query.Get("host")}, Snippets: true}
+	if err := NewRequests(c.queues).List(args, &requests); err != nil {
+		panic(err)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, request := range requests {
+		if id := query.Get("id"); id != "" && request.ID != id {
+			continue
+		}
+		snippet := request.Snippets.Curl
+		if format == SnippetGo {
+			snippet = request.Snippets.Go
+		}
+		fmt.Fprintf(w, "%s %s %s %s\n%s\n\n", comment, request.ID, request.Method, request.Url, strings.TrimSuffix(snippet, "\n"))
+	}
+}
+
+// serveHAR downloads captured requests as HAR log on GET, session and host query parameters select their queues.
+func (c *control) serveHAR(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		status := http.StatusMethodNotAllowed
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	var log har.HAR
+	scope := Scope{Session: r.URL.Query().Get("session"), Host: r.URL.Query().Get("host")}
+	if err := NewRequests(c.queues).ExportHAR(scope, &log); err != nil {
+		panic(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="requests.har"`)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(log); err != nil {
+		panic(err)
+	}
+}
+
+// serveState downloads snapshot of all queues on GET, and imports uploaded one on PUT.
+func (c *control) serveState(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="state.json"`)
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(c.queues.Export()); err != nil {
+			panic(err)
+		}
+
+	case http.MethodPut:
+		var snapshot storage.Snapshot
+		if err := json.NewDecoder(r.Body).Decode(&snapshot); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := c.queues.Import(snapshot, openapi.ParseContract); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPut)
+		status := http.StatusMethodNotAllowed
+		http.Error(w, http.StatusText(status), status)
+	}
+}
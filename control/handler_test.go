@@ -8,7 +8,9 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/spuf/mockable-server/har"
 	"github.com/spuf/mockable-server/storage"
 )
 
@@ -97,6 +99,7 @@ func TestHandlerResponses(t *testing.T) {
 			body: `{
 				"method": "Responses.Push",
 				"params": [{
+					"id": "res-1",
 					"status": 201,
 					"headers": {
 						"Content-Type": "text/plain",
@@ -117,7 +120,7 @@ func TestHandlerResponses(t *testing.T) {
 						"Extra-Header": {"value"},
 					},
 					Body:     "Hello",
-					Response: &storage.Response{Status: 201},
+					Response: &storage.Response{ID: "res-1", Status: 201},
 				},
 			},
 		},
@@ -127,6 +130,7 @@ func TestHandlerResponses(t *testing.T) {
 			body: `{
 				"method": "Responses.Push",
 				"params": [{
+					"id": "res-1",
 					"status": 201,
 					"headers": {
 						"Content-Type": "text/plain",
@@ -148,7 +152,7 @@ func TestHandlerResponses(t *testing.T) {
 						"Extra-Header": {"value"},
 					},
 					Body:     "Hello",
-					Response: &storage.Response{Status: 201},
+					Response: &storage.Response{ID: "res-1", Status: 201},
 				},
 			},
 		},
@@ -358,8 +362,18 @@ func TestHandlerRequests(t *testing.T) {
 					},
 					Body: "Hello",
 					Request: &storage.Request{
-						Method: "GET",
-						Url:    "/base/../path?query",
+						ID:               "req-1",
+						Method:           "GET",
+						Url:              "/base/../path?query",
+						Host:             "api.local",
+						ReceivedAt:       time.Date(2024, 5, 1, 12, 0, 0, 500, time.UTC),
+						RemoteAddr:       "192.0.2.1:1234",
+						Proto:            "HTTP/1.1",
+						TLS:              &storage.TLS{Version: "TLS 1.3", CipherSuite: "TLS_AES_128_GCM_SHA256", ServerName: "api.local"},
+						ContentLength:    -1,
+						TransferEncoding: []string{"chunked"},
+						Trailer:          http.Header{"Checksum": {"abc"}},
+						Served:           &storage.Served{ResponseID: "res-1", Status: 201, Latency: 1500 * time.Millisecond},
 					},
 				},
 			},
@@ -372,14 +386,135 @@ func TestHandlerRequests(t *testing.T) {
 				"result": {
 					"method": "GET",
 					"url": "/base/../path?query",
+					"host": "api.local",
 					"headers": {"Content-Type": "text/plain","Extra-Header": "value"},
-					"body": "Hello"
+					"body": "Hello",
+					"isBodyBase64": false,
+					"bodyLength": 5,
+					"id": "req-1",
+					"receivedAt": "2024-05-01T12:00:00.0000005Z",
+					"remoteAddr": "192.0.2.1:1234",
+					"proto": "HTTP/1.1",
+					"tls": {"version": "TLS 1.3", "cipherSuite": "TLS_AES_128_GCM_SHA256", "serverName": "api.local"},
+					"contentLength": -1,
+					"transferEncoding": ["chunked"],
+					"trailers": {"Checksum": "abc"},
+					"served": {"responseId": "res-1", "status": 201, "latency": "1.5s"}
 				},
 				"error": null
 			}`,
 			wantQueuesRequests: []storage.Message{},
 		},
 
+		{
+			name: "Requests.List binary",
+			queuesRequests: []storage.Message{
+				{
+					Headers: http.Header{"Content-Type": {"application/x-protobuf"}},
+					Body:    "Hello",
+					Request: &storage.Request{Method: "POST", Url: "/upload", Host: "api.local"},
+				},
+				{
+					Headers: http.Header{"Content-Type": {"text/plain"}},
+					Body:    "\x00\xff",
+					Request: &storage.Request{Method: "POST", Url: "/upload", Host: "api.local"},
+				},
+			},
+			body: `{
+				"method": "Requests.List",
+				"params": []
+			}`,
+			wantBody: `{
+				"id": null,
+				"result": [
+					{
+						"method": "POST",
+						"url": "/upload",
+						"host": "api.local",
+						"headers": {"Content-Type": "application/x-protobuf"},
+						"body": "SGVsbG8=",
+						"isBodyBase64": true,
+						"bodyLength": 5,
+						"id": "",
+						"receivedAt": null,
+						"remoteAddr": "",
+						"proto": "",
+						"contentLength": 0
+					},
+					{
+						"method": "POST",
+						"url": "/upload",
+						"host": "api.local",
+						"headers": {"Content-Type": "text/plain"},
+						"body": "AP8=",
+						"isBodyBase64": true,
+						"bodyLength": 2,
+						"id": "",
+						"receivedAt": null,
+						"remoteAddr": "",
+						"proto": "",
+						"contentLength": 0
+					}
+				],
+				"error": null
+			}`,
+			wantQueuesRequests: []storage.Message{
+				{
+					Headers: http.Header{"Content-Type": {"application/x-protobuf"}},
+					Body:    "Hello",
+					Request: &storage.Request{Method: "POST", Url: "/upload", Host: "api.local"},
+				},
+				{
+					Headers: http.Header{"Content-Type": {"text/plain"}},
+					Body:    "\x00\xff",
+					Request: &storage.Request{Method: "POST", Url: "/upload", Host: "api.local"},
+				},
+			},
+		},
+
+		{
+			name: "Requests.List decode",
+			queuesRequests: []storage.Message{
+				{
+					Headers: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+					Body:    "n=v",
+					Request: &storage.Request{Method: "POST", Url: "/form", Host: "api.local"},
+				},
+			},
+			body: `{
+				"method": "Requests.List",
+				"params": [{"decode": true}]
+			}`,
+			wantBody: `{
+				"id": null,
+				"result": [
+					{
+						"method": "POST",
+						"url": "/form",
+						"host": "api.local",
+						"headers": {"Content-Type": "application/x-www-form-urlencoded"},
+						"body": "n=v",
+						"isBodyBase64": false,
+						"bodyLength": 3,
+						"id": "",
+						"receivedAt": null,
+						"remoteAddr": "",
+						"proto": "",
+						"contentLength": 0,
+						"decoded": {"form": {"n": ["v"]}}
+					}
+				],
+				"error": null
+			}`,
+			wantQueuesRequests: []storage.Message{
+				{
+					Headers: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+					Body:    "n=v",
+					Request: &storage.Request{Method: "POST", Url: "/form", Host: "api.local"},
+				},
+			},
+		},
+
 		{
 			name: "Requests.List empty",
 			body: `{
@@ -406,6 +541,7 @@ func TestHandlerRequests(t *testing.T) {
 					Request: &storage.Request{
 						Method: "GET",
 						Url:    "/base/../path?query",
+						Host:   "api.local",
 					},
 				},
 				{
@@ -422,14 +558,30 @@ func TestHandlerRequests(t *testing.T) {
 					{
 						"method": "GET",
 						"url": "/base/../path?query",
+						"host": "api.local",
 						"headers": {"Content-Type": "text/plain","Extra-Header": "value"},
-						"body": "Hello"
+						"body": "Hello",
+						"isBodyBase64": false,
+						"bodyLength": 5,
+						"id": "",
+						"receivedAt": null,
+						"remoteAddr": "",
+						"proto": "",
+						"contentLength": 0
 					},
 					{
 						"method": "",
 						"url": "",
+						"host": "",
 						"headers": {},
-						"body": ""
+						"body": "",
+						"isBodyBase64": false,
+						"bodyLength": 0,
+						"id": "",
+						"receivedAt": null,
+						"remoteAddr": "",
+						"proto": "",
+						"contentLength": 0
 					}
 				],
 				"error": null
@@ -444,6 +596,7 @@ func TestHandlerRequests(t *testing.T) {
 					Request: &storage.Request{
 						Method: "GET",
 						Url:    "/base/../path?query",
+						Host:   "api.local",
 					},
 				},
 				{
@@ -534,3 +687,1392 @@ func TestHandlerRequests(t *testing.T) {
 		})
 	}
 }
+
+func TestHandlerHostScope(t *testing.T) {
+	queues := storage.NewQueues()
+	handler := NewHandler(queues)
+
+	call := func(body string) interface{} {
+		r := httptest.NewRequest(http.MethodPost, "/rpc/1", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		var got interface{}
+		if err := json.NewDecoder(w.Result().Body).Decode(&got); err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		return got.(map[string]interface{})["result"]
+	}
+
+	call(`{"method": "Responses.Push", "params": [{"host": "api.payments.local", "status": 200}]}`)
+
+	if got := call(`{"method": "Responses.List", "params": []}`); !reflect.DeepEqual(got, []interface{}{}) {
+		t.Errorf("default Responses must be empty: %#v", got)
+	}
+	if got := call(`{"method": "Responses.List", "params": [{"host": "api.users.local"}]}`); !reflect.DeepEqual(got, []interface{}{}) {
+		t.Errorf("unknown host Responses must be empty: %#v", got)
+	}
+	if got := call(`{"method": "Responses.List", "params": [{"host": "api.payments.local"}]}`); len(got.([]interface{})) != 1 {
+		t.Errorf("host Responses must contain one item: %#v", got)
+	}
+	if got := call(`{"method": "Hosts.List", "params": []}`); !reflect.DeepEqual(got, []interface{}{"api.payments.local"}) {
+		t.Errorf("unexpected hosts: %#v", got)
+	}
+	if got := call(`{"method": "Hosts.Remove", "params": [{"host": "api.payments.local"}]}`); got != true {
+		t.Errorf("unexpected Hosts.Remove result: %#v", got)
+	}
+	if got := call(`{"method": "Hosts.List", "params": []}`); !reflect.DeepEqual(got, []interface{}{}) {
+		t.Errorf("unexpected hosts: %#v", got)
+	}
+}
+
+func TestHandlerSessions(t *testing.T) {
+	queues := storage.NewQueues()
+	handler := NewHandler(queues)
+
+	call := func(body string) map[string]interface{} {
+		r := httptest.NewRequest(http.MethodPost, "/rpc/1", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		var got map[string]interface{}
+		if err := json.NewDecoder(w.Result().Body).Decode(&got); err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		return got
+	}
+
+	id, ok := call(`{"method": "Sessions.Create", "params": []}`)["result"].(string)
+	if !ok || id == "" {
+		t.Fatalf("Sessions.Create must return session id")
+	}
+
+	call(`{"method": "Responses.Push", "params": [{"session": "` + id + `", "host": "api.local", "status": 200}]}`)
+	if got := call(`{"method": "Responses.List", "params": [{"host": "api.local"}]}`)["result"]; !reflect.DeepEqual(got, []interface{}{}) {
+		t.Errorf("default Responses must be empty: %#v", got)
+	}
+	if got := call(`{"method": "Responses.List", "params": [{"session": "` + id + `", "host": "api.local"}]}`)["result"]; len(got.([]interface{})) != 1 {
+		t.Errorf("session Responses must contain one item: %#v", got)
+	}
+	if got := call(`{"method": "Hosts.List", "params": [{"session": "` + id + `"}]}`)["result"]; !reflect.DeepEqual(got, []interface{}{"api.local"}) {
+		t.Errorf("unexpected session hosts: %#v", got)
+	}
+
+	if got := call(`{"method": "Sessions.List", "params": []}`)["result"]; !reflect.DeepEqual(got, []interface{}{id}) {
+		t.Errorf("unexpected sessions: %#v", got)
+	}
+	if got := call(`{"method": "Sessions.Close", "params": [{"session": "` + id + `"}]}`)["result"]; got != true {
+		t.Errorf("unexpected Sessions.Close result: %#v", got)
+	}
+
+	got := call(`{"method": "Responses.Push", "params": [{"session": "` + id + `", "status": 200}]}`)
+	if want := "validation: session " + id + " not found"; got["error"] != want {
+		t.Errorf("unexpected error: %#v", got["error"])
+	}
+}
+
+func TestHandlerState(t *testing.T) {
+	queues := storage.NewQueues()
+	res := storage.Message{
+		Body:     "\x00Hello",
+		Response: &storage.Response{Status: 200},
+	}
+	if err := queues.Host("api.local").Responses.PushLast(res); err != nil {
+		t.Fatalf("PushLast: %v", err)
+	}
+	handler := NewHandler(queues)
+
+	r := httptest.NewRequest(http.MethodPost, "/rpc/1", strings.NewReader(`{"method": "State.Export", "params": []}`))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	var exported struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(w.Result().Body).Decode(&exported); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	other := storage.NewQueues()
+	otherHandler := NewHandler(other)
+	r = httptest.NewRequest(http.MethodPost, "/rpc/1", strings.NewReader(`{"method": "State.Import", "params": [`+string(exported.Result)+`]}`))
+	w = httptest.NewRecorder()
+	otherHandler.ServeHTTP(w, r)
+
+	if got, _ := io.ReadAll(w.Result().Body); !strings.Contains(string(got), `"result":true`) {
+		t.Fatalf("unexpected State.Import result: %s", got)
+	}
+	host, ok := other.LookupHost("api.local")
+	if !ok {
+		t.Fatalf("host must be imported")
+	}
+	if got := host.Responses.List(); !reflect.DeepEqual(got, []storage.Message{res}) {
+		t.Errorf("responses mismatch:\n got: %#v\nwant: %#v", got, []storage.Message{res})
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/state", nil)
+	w = httptest.NewRecorder()
+	otherHandler.ServeHTTP(w, r)
+	downloaded := w.Body.String()
+	if got := w.Result().Header.Get("Content-Disposition"); got != `attachment; filename="state.json"` {
+		t.Errorf("unexpected Content-Disposition: %v", got)
+	}
+
+	r = httptest.NewRequest(http.MethodPut, "/state", strings.NewReader(downloaded))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if got := w.Result().StatusCode; got != 204 {
+		t.Errorf("unexpected status code: %v", got)
+	}
+
+	r = httptest.NewRequest(http.MethodPut, "/state", strings.NewReader(`{"version": 0}`))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if got := w.Result().StatusCode; got != 422 {
+		t.Errorf("unexpected status code: %v", got)
+	}
+	if list := queues.Host("api.local").Responses.List(); len(list) != 1 {
+		t.Errorf("%#v must be left intact", list)
+	}
+}
+
+func TestHandlerLimits(t *testing.T) {
+	for _, tt := range [...]struct {
+		name     string
+		body     string
+		wantBody string
+	}{
+		{
+			name: "Limits.Get",
+			body: `{"method": "Limits.Get", "params": []}`,
+			wantBody: `{
+				"id": null,
+				"result": {
+					"responses": {"capacity": 0, "overflow": "reject"},
+					"requests": {"capacity": 0, "overflow": "drop-oldest"}
+				},
+				"error": null
+			}`,
+		},
+		{
+			name: "Limits.Set",
+			body: `{"method": "Limits.Set", "params": [{"queue": "requests", "capacity": 1, "overflow": "drop-newest"}]}`,
+			wantBody: `{
+				"id": null,
+				"result": true,
+				"error": null
+			}`,
+		},
+		{
+			name: "Limits.Set invalid",
+			body: `{"method": "Limits.Set", "params": [{"queue": "requests", "capacity": 1, "overflow": "drop"}]}`,
+			wantBody: `{
+				"id": null,
+				"result": null,
+				"error": "validation: overflow \"drop\" must be drop-oldest, drop-newest, or reject"
+			}`,
+		},
+		{
+			name: "Stats.Get",
+			body: `{"method": "Stats.Get", "params": [{"host": "api.local"}]}`,
+			wantBody: `{
+				"id": null,
+				"result": {
+					"responses": {"length": 0, "capacity": 0, "overflow": "reject", "evicted": 0, "rejected": 0, "expired": 0},
+					"requests": {"length": 1, "capacity": 1, "overflow": "drop-newest", "evicted": 1, "rejected": 0, "expired": 0}
+				},
+				"error": null
+			}`,
+		},
+		{
+			name: "Stats.Get unknown host",
+			body: `{"method": "Stats.Get", "params": [{"host": "unknown.local"}]}`,
+			wantBody: `{
+				"id": null,
+				"result": {
+					"responses": {"length": 0, "capacity": 0, "overflow": "reject", "evicted": 0, "rejected": 0, "expired": 0},
+					"requests": {"length": 0, "capacity": 0, "overflow": "drop-oldest", "evicted": 0, "rejected": 0, "expired": 0}
+				},
+				"error": null
+			}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			queues := storage.NewQueues()
+			if tt.name == "Stats.Get" {
+				if err := queues.SetLimit(storage.QueueRequests, storage.Limit{Capacity: 1, Overflow: storage.OverflowDropNewest}); err != nil {
+					t.Fatalf("SetLimit: %v", err)
+				}
+				for i := 0; i < 2; i++ {
+					if err := queues.Host("api.local").Requests.PushLast(storage.Message{Request: &storage.Request{}}); err != nil {
+						t.Fatalf("PushLast: %v", err)
+					}
+				}
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/rpc/1", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			NewHandler(queues).ServeHTTP(w, r)
+
+			var gotBodyObject, wantBodyObject interface{}
+			if err := json.NewDecoder(w.Result().Body).Decode(&gotBodyObject); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if err := json.Unmarshal([]byte(tt.wantBody), &wantBodyObject); err != nil {
+				t.Fatalf("test body is invalid json: %v\n%v", tt.wantBody, err)
+			}
+			if !reflect.DeepEqual(gotBodyObject, wantBodyObject) {
+				t.Errorf("response body mismatch:\n got: %#v\nwant: %#v", gotBodyObject, wantBodyObject)
+			}
+		})
+	}
+}
+
+func TestHandlerResponsesPushTTL(t *testing.T) {
+	queues := storage.NewQueues()
+	r := httptest.NewRequest(http.MethodPost, "/rpc/1", strings.NewReader(`{
+		"method": "Responses.Push",
+		"params": [{"status": 200, "ttl": "1m"}]
+	}`))
+	w := httptest.NewRecorder()
+
+	before := time.Now()
+	NewHandler(queues).ServeHTTP(w, r)
+
+	list := queues.Responses.List()
+	if len(list) != 1 {
+		t.Fatalf("%#v must contain one item", list)
+	}
+	if expiresAt := list[0].ExpiresAt; expiresAt.Before(before.Add(time.Minute)) || expiresAt.After(time.Now().Add(time.Minute)) {
+		t.Errorf("unexpected ExpiresAt: %v", expiresAt)
+	}
+	if list[0].IsExpired(time.Now()) {
+		t.Errorf("response must not be expired yet")
+	}
+	if list[0].Response.ID == "" {
+		t.Errorf("response must have generated ID")
+	}
+}
+
+func TestHandlerRequestsVerify(t *testing.T) {
+	queues := storage.NewQueues()
+	for _, msg := range [...]storage.Message{
+		{Request: &storage.Request{Method: "POST", Url: "/v1/users", Host: "example.com"}, Headers: http.Header{"Content-Type": {"application/json"}}, Body: `{"user": {"id": 42}}`},
+		{Request: &storage.Request{Method: "POST", Url: "/v1/user", Host: "example.com"}, Headers: http.Header{"Content-Type": {"application/json"}}, Body: `{"user": {"id": 42}}`},
+		{Request: &storage.Request{Method: "GET", Url: "/v1/users?page=2", Host: "example.com"}},
+	} {
+		if err := queues.Requests.PushLast(msg); err != nil {
+			t.Fatalf("PushLast: %v", err)
+		}
+	}
+
+	for _, tt := range [...]struct {
+		name     string
+		body     string
+		wantBody string
+	}{
+		{
+			name: "at least one by default",
+			body: `{"method": "Requests.Verify", "params": [{"match": {"method": "post", "path": "/v1/*", "json": [{"path": "$.user.id", "equals": 42}]}}]}`,
+			wantBody: `{"id": null, "error": null, "result": {
+				"pass": true, "count": 2, "expected": "at least 1", "nearMisses": []
+			}}`,
+		},
+		{
+			name: "json operators and equality",
+			body: `{"method": "Requests.Verify", "params": [{"match": {"json": [{"path": "$.user.id", "exists": true, "gte": 40, "lt": 50}], "jsonEquals": {"user": {"id": 0}}, "jsonIgnore": ["$.user.id"]}}]}`,
+			wantBody: `{"id": null, "error": null, "result": {
+				"pass": true, "count": 2, "expected": "at least 1", "nearMisses": []
+			}}`,
+		},
+		{
+			name: "exactly",
+			body: `{"method": "Requests.Verify", "params": [{"match": {"path": "/v1/users"}, "count": {"exactly": 1}}]}`,
+			wantBody: `{"id": null, "error": null, "result": {
+				"pass": false, "count": 2, "expected": "exactly 1", "nearMisses": []
+			}}`,
+		},
+		{
+			name: "near misses",
+			body: `{"method": "Requests.Verify", "params": [{"match": {"method": "POST", "path": "/v1/users", "headers": {"content-type": "*"}}, "count": {"atLeast": 2}}]}`,
+			wantBody: `{"id": null, "error": null, "result": {
+				"pass": false, "count": 1, "expected": "at least 2", "nearMisses": [
+					{
+						"request": {"method": "POST", "url": "/v1/user", "host": "example.com", "headers": {"Content-Type": "application/json"}, "body": "{\"user\": {\"id\": 42}}", "isBodyBase64": false, "bodyLength": 20, "id": "", "receivedAt": null, "remoteAddr": "", "proto": "", "contentLength": 0},
+						"mismatches": [{"field": "path", "expected": "/v1/users", "actual": "/v1/user"}]
+					},
+					{
+						"request": {"method": "GET", "url": "/v1/users?page=2", "host": "example.com", "headers": {}, "body": "", "isBodyBase64": false, "bodyLength": 0, "id": "", "receivedAt": null, "remoteAddr": "", "proto": "", "contentLength": 0},
+						"mismatches": [
+							{"field": "method", "expected": "POST", "actual": "GET"},
+							{"field": "headers.content-type", "expected": "*", "actual": "<missing>"}
+						]
+					}
+				]
+			}}`,
+		},
+		{
+			name: "never",
+			body: `{"method": "Requests.Verify", "params": [{"match": {"method": "DELETE"}, "count": {"never": true}}]}`,
+			wantBody: `{"id": null, "error": null, "result": {
+				"pass": true, "count": 0, "expected": "never", "nearMisses": []
+			}}`,
+		},
+		{
+			name: "invalid count",
+			body: `{"method": "Requests.Verify", "params": [{"count": {"atLeast": 3, "atMost": 1}}]}`,
+			wantBody: `{"id": null, "result": null,
+				"error": "validation: count range [3; 1] is invalid"
+			}`,
+		},
+		{
+			name: "invalid match",
+			body: `{"method": "Requests.Verify", "params": [{"match": {"pathRegex": "("}}]}`,
+			wantBody: `{"id": null, "result": null,
+				"error": "validation: match pathRegex \"(\": error parsing regexp: missing closing ): ` + "`(`" + `"
+			}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/rpc/1", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			NewHandler(queues).ServeHTTP(w, r)
+
+			var got, want interface{}
+			if err := json.NewDecoder(w.Result().Body).Decode(&got); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if err := json.Unmarshal([]byte(tt.wantBody), &want); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("response json mismatch:\n got: %#v\nwant: %#v", got, want)
+			}
+		})
+	}
+
+	if list := queues.Requests.List(); len(list) != 3 {
+		t.Errorf("%#v must be left intact", list)
+	}
+}
+
+func TestHandlerRequestsUnmatched(t *testing.T) {
+	queues := storage.NewQueues()
+	handler := NewHandler(queues)
+
+	call := func(body string) interface{} {
+		r := httptest.NewRequest(http.MethodPost, "/rpc/1", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		var got interface{}
+		if err := json.NewDecoder(w.Result().Body).Decode(&got); err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		return got
+	}
+	decode := func(s string) interface{} {
+		var v interface{}
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+		return v
+	}
+
+	if got, want := call(`{"method": "Responses.Push", "params": [{"status": 200, "match": {"path": "["}}]}`), decode(`{
+		"id": null, "result": null, "error": "validation: match path \"[\": syntax error in pattern"
+	}`); !reflect.DeepEqual(got, want) {
+		t.Errorf("response json mismatch:\n got: %#v\nwant: %#v", got, want)
+	}
+	call(`{"method": "Responses.Push", "params": [{"id": "users", "status": 200, "match": {"method": "GET", "path": "/v1/users"}}]}`)
+	if got, want := call(`{"method": "Responses.List", "params": []}`), decode(`{"id": null, "error": null, "result": [{
+		"id": "users", "delay": 0, "status": 200, "headers": {}, "body": "", "isBodyBase64": false,
+		"match": {"method": "GET", "path": "/v1/users"}
+	}]}`); !reflect.DeepEqual(got, want) {
+		t.Errorf("response json mismatch:\n got: %#v\nwant: %#v", got, want)
+	}
+
+	candidates := []storage.Candidate{{
+		Index:      0,
+		Status:     200,
+		Match:      storage.Matcher{Method: "GET", Path: "/v1/users"},
+		Mismatches: []storage.Mismatch{{Field: "path", Expected: "/v1/users", Actual: "/v1/user"}},
+	}}
+	for _, msg := range [...]storage.Message{
+		{Request: &storage.Request{Method: "GET", Url: "/v1/users", Host: "example.com"}},
+		{Request: &storage.Request{Method: "GET", Url: "/v1/user", Host: "example.com", Unmatched: &storage.Unmatched{Candidates: candidates}}},
+	} {
+		if err := queues.Requests.PushLast(msg); err != nil {
+			t.Fatalf("PushLast: %v", err)
+		}
+	}
+
+	if got, want := call(`{"method": "Requests.Unmatched", "params": []}`), decode(`{"id": null, "error": null, "result": [{
+		"method": "GET", "url": "/v1/user", "host": "example.com", "headers": {}, "body": "", "isBodyBase64": false, "bodyLength": 0,
+		"id": "", "receivedAt": null, "remoteAddr": "", "proto": "", "contentLength": 0,
+		"candidates": [{
+			"index": 0, "status": 200, "match": {"method": "GET", "path": "/v1/users"},
+			"mismatches": [{"field": "path", "expected": "/v1/users", "actual": "/v1/user"}]
+		}]
+	}]}`); !reflect.DeepEqual(got, want) {
+		t.Errorf("response json mismatch:\n got: %#v\nwant: %#v", got, want)
+	}
+}
+
+func TestHandlerResponsesDefault(t *testing.T) {
+	queues := storage.NewQueues()
+	handler := NewHandler(queues)
+
+	for _, tt := range [...]struct {
+		name     string
+		body     string
+		wantBody string
+	}{
+		{
+			name:     "GetDefault unset",
+			body:     `{"method": "Responses.GetDefault", "params": []}`,
+			wantBody: `{"id": null, "result": null, "error": null}`,
+		},
+		{
+			name:     "SetDefault with match",
+			body:     `{"method": "Responses.SetDefault", "params": [{"status": 200, "match": {"path": "/"}}]}`,
+			wantBody: `{"id": null, "result": null, "error": "validation: default response must not have match or scenario"}`,
+		},
+		{
+			name:     "SetDefault",
+			body:     `{"method": "Responses.SetDefault", "params": [{"id": "default", "status": 200, "headers": {"Content-Type": "text/plain"}, "body": "OK"}]}`,
+			wantBody: `{"id": null, "result": true, "error": null}`,
+		},
+		{
+			name: "GetDefault",
+			body: `{"method": "Responses.GetDefault", "params": []}`,
+			wantBody: `{"id": null, "error": null, "result": {
+				"id": "default", "delay": 0, "status": 200, "headers": {"Content-Type": "text/plain"}, "body": "OK", "isBodyBase64": false
+			}}`,
+		},
+		{
+			name:     "ClearDefault",
+			body:     `{"method": "Responses.ClearDefault", "params": []}`,
+			wantBody: `{"id": null, "result": true, "error": null}`,
+		},
+		{
+			name:     "GetDefault cleared",
+			body:     `{"method": "Responses.GetDefault", "params": []}`,
+			wantBody: `{"id": null, "result": null, "error": null}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/rpc/1", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			var got, want interface{}
+			if err := json.NewDecoder(w.Result().Body).Decode(&got); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if err := json.Unmarshal([]byte(tt.wantBody), &want); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("response json mismatch:\n got: %#v\nwant: %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestHandlerFailures(t *testing.T) {
+	queues := storage.NewQueues()
+	if err := queues.Fail(storage.Message{Request: &storage.Request{Method: "GET", Url: "/health", Host: "example.com"}}); err != nil {
+		t.Fatalf("Fail: %v", err)
+	}
+	handler := NewHandler(queues)
+
+	for _, tt := range [...]struct {
+		name     string
+		body     string
+		wantBody string
+	}{
+		{
+			name: "List",
+			body: `{"method": "Failures.List", "params": []}`,
+			wantBody: `{"id": null, "error": null, "result": [{
+				"method": "GET", "url": "/health", "host": "example.com", "headers": {}, "body": "", "isBodyBase64": false, "bodyLength": 0,
+				"id": "", "receivedAt": null, "remoteAddr": "", "proto": "", "contentLength": 0, "candidates": []
+			}]}`,
+		},
+		{
+			name:     "Clear",
+			body:     `{"method": "Failures.Clear", "params": []}`,
+			wantBody: `{"id": null, "result": true, "error": null}`,
+		},
+		{
+			name:     "List cleared",
+			body:     `{"method": "Failures.List", "params": []}`,
+			wantBody: `{"id": null, "result": [], "error": null}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/rpc/1", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			var got, want interface{}
+			if err := json.NewDecoder(w.Result().Body).Decode(&got); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if err := json.Unmarshal([]byte(tt.wantBody), &want); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("response json mismatch:\n got: %#v\nwant: %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestHandlerMultiValuedHeaders(t *testing.T) {
+	queues := storage.NewQueues()
+	if err := queues.Requests.PushLast(storage.Message{
+		Headers: http.Header{"Accept": {"text/html", "application/json"}, "X-Id": {"1"}},
+		Request: &storage.Request{Method: "GET", Url: "/", Host: "example.com"},
+	}); err != nil {
+		t.Fatalf("PushLast: %v", err)
+	}
+	handler := NewHandler(queues)
+
+	for _, tt := range [...]struct {
+		name     string
+		body     string
+		wantBody string
+	}{
+		{
+			name:     "Responses.Push",
+			body:     `{"method": "Responses.Push", "params": [{"id": "res-1", "status": 200, "headers": {"set-cookie": ["a=1", "b=2"], "Content-Type": "text/plain"}}]}`,
+			wantBody: `{"id": null, "result": true, "error": null}`,
+		},
+		{
+			name:     "Responses.Push invalid",
+			body:     `{"method": "Responses.Push", "params": [{"status": 200, "headers": {"Set-Cookie": 1}}]}`,
+			wantBody: `{"id": null, "result": null, "error": "header Set-Cookie must be string or array of strings"}`,
+		},
+		{
+			name: "Responses.List",
+			body: `{"method": "Responses.List", "params": []}`,
+			wantBody: `{"id": null, "error": null, "result": [{
+				"id": "res-1", "delay": 0, "status": 200, "headers": {"Set-Cookie": ["a=1", "b=2"], "Content-Type": "text/plain"}, "body": "", "isBodyBase64": false
+			}]}`,
+		},
+		{
+			name: "Requests.List",
+			body: `{"method": "Requests.List", "params": []}`,
+			wantBody: `{"id": null, "error": null, "result": [{
+				"method": "GET", "url": "/", "host": "example.com", "headers": {"Accept": ["text/html", "application/json"], "X-Id": "1"},
+				"body": "", "isBodyBase64": false, "bodyLength": 0,
+				"id": "", "receivedAt": null, "remoteAddr": "", "proto": "", "contentLength": 0
+			}]}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/rpc/1", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			var got, want interface{}
+			if err := json.NewDecoder(w.Result().Body).Decode(&got); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if err := json.Unmarshal([]byte(tt.wantBody), &want); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("response json mismatch:\n got: %#v\nwant: %#v", got, want)
+			}
+		})
+	}
+
+	want := http.Header{"Set-Cookie": {"a=1", "b=2"}, "Content-Type": {"text/plain"}}
+	if list := queues.Responses.List(); len(list) != 1 || !reflect.DeepEqual(list[0].Headers, want) {
+		t.Errorf("mismatch response headers:\n got: %#v\nwant: %#v", list, want)
+	}
+}
+
+func TestHandlerRequestsFind(t *testing.T) {
+	queues := storage.NewQueues()
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, url := range []string{"/a/1", "/b/1", "/a/2", "/a/3"} {
+		if err := queues.Requests.PushLast(storage.Message{
+			Request: &storage.Request{ID: url, Method: "GET", Url: url, Host: "example.com", ReceivedAt: start.Add(time.Duration(i) * time.Second)},
+		}); err != nil {
+			t.Fatalf("PushLast: %v", err)
+		}
+	}
+	handler := NewHandler(queues)
+
+	call := func(body string) (ids []string, errorMessage interface{}) {
+		r := httptest.NewRequest(http.MethodPost, "/rpc/1", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		var got struct {
+			Result []Request   `json:"result"`
+			Error  interface{} `json:"error"`
+		}
+		if err := json.NewDecoder(w.Result().Body).Decode(&got); err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		ids = []string{}
+		for _, request := range got.Result {
+			ids = append(ids, request.ID)
+		}
+		return ids, got.Error
+	}
+
+	for _, tt := range [...]struct {
+		name      string
+		body      string
+		wantIDs   []string
+		wantError interface{}
+	}{
+		{
+			name:    "all",
+			body:    `{"method": "Requests.Find", "params": []}`,
+			wantIDs: []string{"/a/1", "/b/1", "/a/2", "/a/3"},
+		},
+		{
+			name:    "path newest first with offset and limit",
+			body:    `{"method": "Requests.Find", "params": [{"match": {"path": "/a/*"}, "newestFirst": true, "offset": 1, "limit": 1}]}`,
+			wantIDs: []string{"/a/2"},
+		},
+		{
+			name:    "time range",
+			body:    `{"method": "Requests.Find", "params": [{"since": "2024-05-01T12:00:01Z", "until": "2024-05-01T12:00:03Z"}]}`,
+			wantIDs: []string{"/b/1", "/a/2"},
+		},
+		{
+			name:      "invalid time range",
+			body:      `{"method": "Requests.Find", "params": [{"since": "2024-05-01T12:00:03Z", "until": "2024-05-01T12:00:01Z"}]}`,
+			wantIDs:   []string{},
+			wantError: "validation: since 2024-05-01T12:00:03Z must be before until 2024-05-01T12:00:01Z",
+		},
+		{
+			name:      "negative limit",
+			body:      `{"method": "Requests.Find", "params": [{"limit": -1}]}`,
+			wantIDs:   []string{},
+			wantError: "validation: offset 0 and limit -1 must not be negative",
+		},
+		{
+			name:    "remove",
+			body:    `{"method": "Requests.Remove", "params": [{"match": {"pathRegex": "^/a/[12]$"}}]}`,
+			wantIDs: []string{"/a/1", "/a/2"},
+		},
+		{
+			name:    "left after remove",
+			body:    `{"method": "Requests.List", "params": []}`,
+			wantIDs: []string{"/b/1", "/a/3"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, errorMessage := call(tt.body)
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("mismatch ids:\n got: %#v\nwant: %#v", ids, tt.wantIDs)
+			}
+			if !reflect.DeepEqual(errorMessage, tt.wantError) {
+				t.Errorf("mismatch error:\n got: %#v\nwant: %#v", errorMessage, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestHandlerRequestsGet(t *testing.T) {
+	queues := storage.NewQueues()
+	for _, msg := range [...]storage.Message{
+		{Request: &storage.Request{ID: "unserved", Method: "GET", Url: "/", Host: "example.com"}},
+		{
+			Body: "ping",
+			Request: &storage.Request{
+				ID: "served", Method: "POST", Url: "/ping", Host: "example.com",
+				Served: &storage.Served{
+					ResponseID: "pong",
+					Status:     200,
+					Delay:      time.Second,
+					Headers:    http.Header{"Content-Type": {"text/plain"}},
+					Body:       []byte("pong"),
+					Latency:    time.Second,
+				},
+			},
+		},
+	} {
+		if err := queues.Requests.PushLast(msg); err != nil {
+			t.Fatalf("PushLast: %v", err)
+		}
+	}
+	handler := NewHandler(queues)
+
+	for _, tt := range [...]struct {
+		name     string
+		body     string
+		wantBody string
+	}{
+		{
+			name: "served",
+			body: `{"method": "Requests.Get", "params": [{"id": "served"}]}`,
+			wantBody: `{"id": null, "error": null, "result": {
+				"request": {
+					"method": "POST", "url": "/ping", "host": "example.com", "headers": {}, "body": "ping", "isBodyBase64": false, "bodyLength": 4,
+					"id": "served", "receivedAt": null, "remoteAddr": "", "proto": "", "contentLength": 0,
+					"served": {"responseId": "pong", "status": 200, "latency": "1s"}
+				},
+				"response": {
+					"id": "pong", "delay": "1s", "status": 200, "headers": {"Content-Type": "text/plain"}, "body": "pong", "isBodyBase64": false
+				}
+			}}`,
+		},
+		{
+			name: "unserved",
+			body: `{"method": "Requests.Get", "params": [{"id": "unserved"}]}`,
+			wantBody: `{"id": null, "error": null, "result": {
+				"request": {
+					"method": "GET", "url": "/", "host": "example.com", "headers": {}, "body": "", "isBodyBase64": false, "bodyLength": 0,
+					"id": "unserved", "receivedAt": null, "remoteAddr": "", "proto": "", "contentLength": 0
+				},
+				"response": null
+			}}`,
+		},
+		{
+			name:     "not found",
+			body:     `{"method": "Requests.Get", "params": [{"id": "unknown"}]}`,
+			wantBody: `{"id": null, "result": null, "error": null}`,
+		},
+		{
+			name:     "empty id",
+			body:     `{"method": "Requests.Get", "params": []}`,
+			wantBody: `{"id": null, "result": null, "error": "validation: id must not be empty"}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/rpc/1", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			var got, want interface{}
+			if err := json.NewDecoder(w.Result().Body).Decode(&got); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if err := json.Unmarshal([]byte(tt.wantBody), &want); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("response json mismatch:\n got: %#v\nwant: %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestHandlerScenarios(t *testing.T) {
+	queues := storage.NewQueues()
+	handler := NewHandler(queues)
+
+	for _, tt := range [...]struct {
+		name     string
+		body     string
+		wantBody string
+	}{
+		{
+			name:     "push stub",
+			body:     `{"method": "Responses.Push", "params": [{"id": "paid", "status": 200, "body": "paid", "stub": true, "scenario": {"name": "order", "state": "paid"}}]}`,
+			wantBody: `{"id": null, "result": true, "error": null}`,
+		},
+		{
+			name:     "push invalid scenario",
+			body:     `{"method": "Responses.Push", "params": [{"status": 200, "scenario": {"state": "paid"}}]}`,
+			wantBody: `{"id": null, "result": null, "error": "validation: scenario name must not be empty"}`,
+		},
+		{
+			name:     "list referenced",
+			body:     `{"method": "Scenarios.List", "params": []}`,
+			wantBody: `{"id": null, "result": [{"name": "order", "state": "Started"}], "error": null}`,
+		},
+		{
+			name:     "set",
+			body:     `{"method": "Scenarios.Set", "params": [{"name": "order", "state": "paid"}]}`,
+			wantBody: `{"id": null, "result": true, "error": null}`,
+		},
+		{
+			name:     "set invalid",
+			body:     `{"method": "Scenarios.Set", "params": [{"name": "order"}]}`,
+			wantBody: `{"id": null, "result": null, "error": "validation: scenario name and state must not be empty"}`,
+		},
+		{
+			name:     "list set",
+			body:     `{"method": "Scenarios.List", "params": []}`,
+			wantBody: `{"id": null, "result": [{"name": "order", "state": "paid"}], "error": null}`,
+		},
+		{
+			name: "responses list",
+			body: `{"method": "Responses.List", "params": []}`,
+			wantBody: `{"id": null, "error": null, "result": [{
+				"id": "paid", "delay": 0, "status": 200, "headers": {}, "body": "paid", "isBodyBase64": false,
+				"stub": true, "scenario": {"name": "order", "state": "paid"}
+			}]}`,
+		},
+		{
+			name:     "reset",
+			body:     `{"method": "Scenarios.Reset", "params": [{"name": "order"}]}`,
+			wantBody: `{"id": null, "result": true, "error": null}`,
+		},
+		{
+			name:     "list reset",
+			body:     `{"method": "Scenarios.List", "params": []}`,
+			wantBody: `{"id": null, "result": [{"name": "order", "state": "Started"}], "error": null}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/rpc/1", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			var got, want interface{}
+			if err := json.NewDecoder(w.Result().Body).Decode(&got); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if err := json.Unmarshal([]byte(tt.wantBody), &want); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("response json mismatch:\n got: %#v\nwant: %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestHandlerCallbacks(t *testing.T) {
+	queues := storage.NewQueues()
+	queues.Deliver(storage.Delivery{
+		ID: "d1", ResponseID: "charge", RequestID: "r1", Method: "POST", URL: "http://localhost/hook",
+		Status: 503, Attempts: 2, SentAt: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC), Latency: 150 * time.Millisecond,
+	})
+	handler := NewHandler(queues)
+
+	for _, tt := range [...]struct {
+		name     string
+		body     string
+		wantBody string
+	}{
+		{
+			name: "push",
+			body: `{"method": "Responses.Push", "params": [{"id": "charge", "status": 201, "callbacks": [
+				{"url": "http://localhost/hook", "headers": {"X-Signature": "abc"}, "body": "{{json .Request.Url}}", "delay": "1s", "retries": 2}
+			]}]}`,
+			wantBody: `{"id": null, "result": true, "error": null}`,
+		},
+		{
+			name:     "push invalid url",
+			body:     `{"method": "Responses.Push", "params": [{"status": 201, "callbacks": [{"url": "/hook"}]}]}`,
+			wantBody: `{"id": null, "result": null, "error": "validation: callback url \"/hook\" must be absolute http or https one"}`,
+		},
+		{
+			name: "responses list",
+			body: `{"method": "Responses.List", "params": []}`,
+			wantBody: `{"id": null, "error": null, "result": [{
+				"id": "charge", "delay": 0, "status": 201, "headers": {}, "body": "", "isBodyBase64": false,
+				"callbacks": [{"url": "http://localhost/hook", "headers": {"X-Signature": "abc"}, "body": "{{json .Request.Url}}", "delay": "1s", "retries": 2}]
+			}]}`,
+		},
+		{
+			name: "list",
+			body: `{"method": "Callbacks.List", "params": []}`,
+			wantBody: `{"id": null, "error": null, "result": [{
+				"id": "d1", "responseId": "charge", "requestId": "r1", "method": "POST", "url": "http://localhost/hook",
+				"status": 503, "error": "", "attempts": 2, "sentAt": "2023-01-02T03:04:05Z", "latency": "150ms"
+			}]}`,
+		},
+		{
+			name:     "clear",
+			body:     `{"method": "Callbacks.Clear", "params": []}`,
+			wantBody: `{"id": null, "result": true, "error": null}`,
+		},
+		{
+			name:     "list cleared",
+			body:     `{"method": "Callbacks.List", "params": []}`,
+			wantBody: `{"id": null, "result": [], "error": null}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/rpc/1", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			var got, want interface{}
+			if err := json.NewDecoder(w.Result().Body).Decode(&got); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if err := json.Unmarshal([]byte(tt.wantBody), &want); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("response json mismatch:\n got: %#v\nwant: %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestHandlerResponsesVariants(t *testing.T) {
+	queues := storage.NewQueues()
+	handler := NewHandler(queues)
+
+	for _, tt := range [...]struct {
+		name     string
+		body     string
+		wantBody string
+	}{
+		{
+			name: "push",
+			body: `{"method": "Responses.Push", "params": [{"id": "flaky", "stub": true, "pick": "roundRobin", "seed": 7, "variants": [
+				{"weight": 9, "status": 200, "body": "ok"},
+				{"weight": 1, "status": 503, "headers": {"Retry-After": "1"}, "delay": "10ms"}
+			]}]}`,
+			wantBody: `{"id": null, "result": true, "error": null}`,
+		},
+		{
+			name:     "push invalid pick",
+			body:     `{"method": "Responses.Push", "params": [{"pick": "first", "variants": [{"status": 200}]}]}`,
+			wantBody: `{"id": null, "result": null, "error": "validation: pick \"first\" must be random or roundRobin"}`,
+		},
+		{
+			name:     "push invalid variant",
+			body:     `{"method": "Responses.Push", "params": [{"status": 200, "variants": [{"status": 600}]}]}`,
+			wantBody: `{"id": null, "result": null, "error": "validation: variant status 600 must be in [100; 600)"}`,
+		},
+		{
+			name: "list",
+			body: `{"method": "Responses.List", "params": []}`,
+			wantBody: `{"id": null, "error": null, "result": [{
+				"id": "flaky", "delay": 0, "status": 200, "headers": {}, "body": "", "isBodyBase64": false,
+				"stub": true, "pick": "roundRobin", "seed": 7, "variants": [
+					{"weight": 9, "delay": 0, "status": 200, "body": "ok", "isBodyBase64": false},
+					{"weight": 1, "delay": "10ms", "status": 503, "headers": {"Retry-After": "1"}, "body": "", "isBodyBase64": false}
+				]
+			}]}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/rpc/1", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			var got, want interface{}
+			if err := json.NewDecoder(w.Result().Body).Decode(&got); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if err := json.Unmarshal([]byte(tt.wantBody), &want); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("response json mismatch:\n got: %#v\nwant: %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestHandlerRateLimits(t *testing.T) {
+	queues := storage.NewQueues()
+	handler := NewHandler(queues)
+
+	for _, tt := range [...]struct {
+		name     string
+		body     string
+		wantBody string
+	}{
+		{
+			name: "set",
+			body: `{"method": "RateLimits.Set", "params": [{"name": "api", "match": {"path": "/api/*"}, "limit": 100, "period": "1m", "keyHeader": "X-Api-Key",
+				"headers": {"Content-Type": "application/json"}, "body": "{\"error\": \"rate limited\"}"}]}`,
+			wantBody: `{"id": null, "result": true, "error": null}`,
+		},
+		{
+			name:     "set invalid",
+			body:     `{"method": "RateLimits.Set", "params": [{"name": "api", "limit": 0, "period": "1m"}]}`,
+			wantBody: `{"id": null, "result": null, "error": "validation: rate limit 0 must be positive"}`,
+		},
+		{
+			name: "list",
+			body: `{"method": "RateLimits.List", "params": []}`,
+			wantBody: `{"id": null, "error": null, "result": [{
+				"name": "api", "match": {"path": "/api/*"}, "limit": 100, "period": "1m0s", "keyHeader": "X-Api-Key",
+				"headers": {"Content-Type": "application/json"}, "body": "{\"error\": \"rate limited\"}"
+			}]}`,
+		},
+		{
+			name:     "remove",
+			body:     `{"method": "RateLimits.Remove", "params": [{"name": "api"}]}`,
+			wantBody: `{"id": null, "result": true, "error": null}`,
+		},
+		{
+			name:     "list removed",
+			body:     `{"method": "RateLimits.List", "params": []}`,
+			wantBody: `{"id": null, "result": [], "error": null}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/rpc/1", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			var got, want interface{}
+			if err := json.NewDecoder(w.Result().Body).Decode(&got); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if err := json.Unmarshal([]byte(tt.wantBody), &want); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("response json mismatch:\n got: %#v\nwant: %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestHandlerResponsesImportOpenAPI(t *testing.T) {
+	queues := storage.NewQueues()
+	handler := NewHandler(queues)
+
+	for _, tt := range [...]struct {
+		name     string
+		body     string
+		wantBody string
+	}{
+		{
+			name: "import",
+			body: `{"method": "Responses.ImportOpenAPI", "params": [{"host": "api.local", "document": "openapi: 3.0.0\npaths:\n  /users/{id}:\n    get:\n      operationId: getUser\n      responses:\n        '200':\n          content:\n            application/json:\n              example: {id: 1}\n"}]}`,
+			wantBody: `{"id": null, "error": null, "result": [{
+				"id": "getUser", "delay": 0, "status": 200, "headers": {"Content-Type": "application/json"}, "body": "{\"id\":1}", "isBodyBase64": false,
+				"stub": true, "match": {"method": "GET", "path": "/users/*"}
+			}]}`,
+		},
+		{
+			name:     "import invalid",
+			body:     `{"method": "Responses.ImportOpenAPI", "params": [{"document": "swagger: '2.0'"}]}`,
+			wantBody: `{"id": null, "result": null, "error": "validation: openapi version is missing, only 3.x documents are supported"}`,
+		},
+		{
+			name: "import again",
+			body: `{"method": "Responses.ImportOpenAPI", "params": [{"host": "api.local", "document": "openapi: 3.0.0\npaths:\n  /users/{id}:\n    get:\n      operationId: getUser\n      responses:\n        '200':\n          content:\n            application/json:\n              example: {id: 2}\n"}]}`,
+			wantBody: `{"id": null, "error": null, "result": [{
+				"id": "getUser", "delay": 0, "status": 200, "headers": {"Content-Type": "application/json"}, "body": "{\"id\":2}", "isBodyBase64": false,
+				"stub": true, "match": {"method": "GET", "path": "/users/*"}
+			}]}`,
+		},
+		{
+			name: "list",
+			body: `{"method": "Responses.List", "params": [{"host": "api.local"}]}`,
+			wantBody: `{"id": null, "error": null, "result": [{
+				"id": "getUser", "delay": 0, "status": 200, "headers": {"Content-Type": "application/json"}, "body": "{\"id\":2}", "isBodyBase64": false,
+				"stub": true, "match": {"method": "GET", "path": "/users/*"}
+			}]}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/rpc/1", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			var got, want interface{}
+			if err := json.NewDecoder(w.Result().Body).Decode(&got); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if err := json.Unmarshal([]byte(tt.wantBody), &want); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("response json mismatch:\n got: %#v\nwant: %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestHandlerContracts(t *testing.T) {
+	queues := storage.NewQueues()
+	handler := NewHandler(queues)
+	if err := queues.Requests.PushLast(storage.Message{
+		Headers: http.Header{},
+		Request: &storage.Request{
+			ID: "req-1", Method: "GET", Url: "/pets?limit=ten", Host: "api.local",
+			Violations: []storage.Violation{{Field: "query.limit", Message: "must be integer, got string"}},
+		},
+	}); err != nil {
+		t.Fatalf("PushLast: %v", err)
+	}
+
+	for _, tt := range [...]struct {
+		name         string
+		body         string
+		wantBody     string
+		wantContract bool
+	}{
+		{
+			name:     "set invalid",
+			body:     `{"method": "Contracts.Set", "params": [{"host": "api.local", "document": "swagger: '2.0'"}]}`,
+			wantBody: `{"id": null, "result": null, "error": "validation: openapi version is missing, only 3.x documents are supported"}`,
+		},
+		{
+			name:         "set",
+			body:         `{"method": "Contracts.Set", "params": [{"host": "api.local", "document": "openapi: 3.0.0\npaths:\n  /pets:\n    get:\n      responses:\n        '200':\n          description: Pets\n"}]}`,
+			wantBody:     `{"id": null, "result": true, "error": null}`,
+			wantContract: true,
+		},
+		{
+			name: "requests",
+			body: `{"method": "Requests.List", "params": []}`,
+			wantBody: `{"id": null, "error": null, "result": [{
+				"id": "req-1", "method": "GET", "url": "/pets?limit=ten", "host": "api.local", "headers": {}, "body": "", "isBodyBase64": false, "bodyLength": 0,
+				"receivedAt": null, "remoteAddr": "", "proto": "", "contentLength": 0,
+				"violations": [{"field": "query.limit", "message": "must be integer, got string"}]
+			}]}`,
+			wantContract: true,
+		},
+		{
+			name:     "clear",
+			body:     `{"method": "Contracts.Clear", "params": [{"host": "api.local"}]}`,
+			wantBody: `{"id": null, "result": true, "error": null}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/rpc/1", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			var got, want interface{}
+			if err := json.NewDecoder(w.Result().Body).Decode(&got); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if err := json.Unmarshal([]byte(tt.wantBody), &want); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("response json mismatch:\n got: %#v\nwant: %#v", got, want)
+			}
+			if contract := queues.Host("api.local").Contract(); (contract != nil) != tt.wantContract {
+				t.Errorf("unexpected contract %#v", contract)
+			}
+		})
+	}
+}
+
+func TestHandlerHAR(t *testing.T) {
+	queues := storage.NewQueues()
+	handler := NewHandler(queues)
+	if err := queues.Host("api.local").Requests.PushLast(storage.Message{
+		Headers: http.Header{},
+		Request: &storage.Request{
+			Method: "GET", Url: "/pets", Host: "api.local", Proto: "HTTP/1.1",
+			ReceivedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			Served:     &storage.Served{Status: 200, Headers: http.Header{"Content-Type": {"application/json"}}, Body: []byte("[]"), Latency: time.Millisecond},
+		},
+	}); err != nil {
+		t.Fatalf("PushLast: %v", err)
+	}
+
+	for _, tt := range [...]struct {
+		name     string
+		body     string
+		wantBody string
+	}{
+		{
+			name: "export",
+			body: `{"method": "Requests.ExportHAR", "params": [{"host": "api.local"}]}`,
+			wantBody: `{"id": null, "error": null, "result": {"log": {
+				"version": "1.2", "creator": {"name": "mockable-server", "version": ""},
+				"entries": [{
+					"startedDateTime": "2024-01-02T03:04:05Z", "time": 1, "cache": {}, "timings": {"send": 0, "wait": 1, "receive": 0},
+					"request": {
+						"method": "GET", "url": "http://api.local/pets", "httpVersion": "HTTP/1.1",
+						"cookies": [], "headers": [], "queryString": [], "headersSize": -1, "bodySize": 0
+					},
+					"response": {
+						"status": 200, "statusText": "OK", "httpVersion": "HTTP/1.1", "cookies": [],
+						"headers": [{"name": "Content-Type", "value": "application/json"}],
+						"content": {"size": 2, "mimeType": "application/json", "text": "[]"},
+						"redirectURL": "", "headersSize": -1, "bodySize": 2
+					}
+				}]
+			}}}`,
+		},
+		{
+			name:     "export unknown",
+			body:     `{"method": "Requests.ExportHAR", "params": [{"host": "other.local"}]}`,
+			wantBody: `{"id": null, "error": null, "result": {"log": {"version": "1.2", "creator": {"name": "mockable-server", "version": ""}, "entries": []}}}`,
+		},
+		{
+			name:     "import invalid",
+			body:     `{"method": "Responses.ImportHAR", "params": [{"document": "{}"}]}`,
+			wantBody: `{"id": null, "result": null, "error": "validation: HAR log version is missing"}`,
+		},
+		{
+			name: "import",
+			body: `{"method": "Responses.ImportHAR", "params": [{"host": "api.local", "stub": true, "document": "{\"log\": {\"version\": \"1.2\", \"entries\": [{\"request\": {\"method\": \"GET\", \"url\": \"https://api.local/pets?page=2\"}, \"response\": {\"status\": 200, \"headers\": [{\"name\": \"Content-Type\", \"value\": \"application/json\"}], \"content\": {\"text\": \"[]\"}}}]}}"}]}`,
+			wantBody: `{"id": null, "error": null, "result": [{
+				"id": "generated", "delay": 0, "status": 200, "headers": {"Content-Type": "application/json"}, "body": "[]", "isBodyBase64": false,
+				"stub": true, "match": {"method": "GET", "path": "/pets", "query": {"page": "2"}}
+			}]}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/rpc/1", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			var got, want interface{}
+			if err := json.NewDecoder(w.Result().Body).Decode(&got); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if err := json.Unmarshal([]byte(tt.wantBody), &want); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if result, ok := got.(map[string]interface{})["result"].([]interface{}); ok {
+				for _, res := range result {
+					res.(map[string]interface{})["id"] = "generated"
+				}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("response json mismatch:\n got: %#v\nwant: %#v", got, want)
+			}
+		})
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/har?host=api.local", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	got := w.Result()
+	if got.StatusCode != http.StatusOK || got.Header.Get("Content-Disposition") != `attachment; filename="requests.har"` {
+		t.Errorf("unexpected response %v %v", got.StatusCode, got.Header)
+	}
+	var log har.HAR
+	if err := json.NewDecoder(got.Body).Decode(&log); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if len(log.Log.Entries) != 1 || log.Log.Entries[0].Request.URL != "http://api.local/pets" {
+		t.Errorf("unexpected HAR %#v", log)
+	}
+}
+
+func TestHandlerSnippets(t *testing.T) {
+	queues := storage.NewQueues()
+	handler := NewHandler(queues)
+	for _, msg := range []storage.Message{
+		{Headers: http.Header{}, Request: &storage.Request{ID: "req-1", Method: "GET", Url: "/pets", Host: "api.local"}},
+		{Headers: http.Header{}, Body: "name=Rex", Request: &storage.Request{ID: "req-2", Method: "POST", Url: "/pets", Host: "api.local"}},
+	} {
+		if err := queues.Host("api.local").Requests.PushLast(msg); err != nil {
+			t.Fatalf("PushLast: %v", err)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/rpc/1", strings.NewReader(`{"method": "Requests.Find", "params": [{"host": "api.local", "snippets": true, "limit": 1}]}`))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	var got struct {
+		Result []Request `json:"result"`
+	}
+	if err := json.NewDecoder(w.Result().Body).Decode(&got); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	want := &Snippets{
+		Curl: "curl http://api.local/pets",
+		Go:   "req, err := http.NewRequest(\"GET\", \"http://api.local/pets\", nil)\nif err != nil {\n\tpanic(err)\n}\nres, err := http.DefaultClient.Do(req)\nif err != nil {\n\tpanic(err)\n}\ndefer res.Body.Close()\n",
+	}
+	if len(got.Result) != 1 || !reflect.DeepEqual(got.Result[0].Snippets, want) {
+		t.Errorf("unexpected requests %#v", got.Result)
+	}
+
+	for _, tt := range [...]struct {
+		url        string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			url:        "/snippets?host=api.local",
+			wantStatus: http.StatusOK,
+			wantBody:   "# req-1 GET /pets\ncurl http://api.local/pets\n\n# req-2 POST /pets\ncurl http://api.local/pets \\\n  --data-binary name=Rex\n\n",
+		},
+		{
+			url:        "/snippets?host=api.local&format=go&id=req-2",
+			wantStatus: http.StatusOK,
+			wantBody:   "// req-2 POST /pets\nreq, err := http.NewRequest(\"POST\", \"http://api.local/pets\", strings.NewReader(`name=Rex`))\nif err != nil {\n\tpanic(err)\n}\nres, err := http.DefaultClient.Do(req)\nif err != nil {\n\tpanic(err)\n}\ndefer res.Body.Close()\n\n",
+		},
+		{
+			url:        "/snippets?format=wget",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "format \"wget\" must be curl or go\n",
+		},
+	} {
+		r := httptest.NewRequest(http.MethodGet, tt.url, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		got := w.Result()
+		gotBody, _ := io.ReadAll(got.Body)
+		if got.StatusCode != tt.wantStatus || string(gotBody) != tt.wantBody {
+			t.Errorf("%s: unexpected response %v\n%s", tt.url, got.StatusCode, gotBody)
+		}
+	}
+}
+
+func TestHandlerResponsesSOAPFault(t *testing.T) {
+	queues := storage.NewQueues()
+	handler := NewHandler(queues)
+
+	envelope, _ := json.Marshal(storage.SOAPFault{Version: storage.SOAP12, Code: "soap:Sender", String: "Unknown user"}.Envelope())
+	for _, tt := range [...]struct {
+		name     string
+		body     string
+		wantBody string
+	}{
+		{
+			name: "push",
+			body: `{"method": "Responses.Push", "params": [{"id": "fault", "match": {"soapAction": "urn:GetUser", "xpath": [{"path": "//GetUser/id", "equals": "0"}]},
+				"soapFault": {"version": "1.2", "code": "soap:Sender", "string": "Unknown user"}}]}`,
+			wantBody: `{"id": null, "result": true, "error": null}`,
+		},
+		{
+			name:     "push with body",
+			body:     `{"method": "Responses.Push", "params": [{"body": "oops", "soapFault": {"string": "Unknown user"}}]}`,
+			wantBody: `{"id": null, "result": null, "error": "validation: body must be empty with soapFault"}`,
+		},
+		{
+			name:     "push invalid fault",
+			body:     `{"method": "Responses.Push", "params": [{"soapFault": {"version": "1.3", "string": "Unknown user"}}]}`,
+			wantBody: `{"id": null, "result": null, "error": "validation: soap version \"1.3\" must be 1.1 or 1.2"}`,
+		},
+		{
+			name:     "push invalid xpath",
+			body:     `{"method": "Responses.Push", "params": [{"status": 200, "match": {"xpath": [{"path": "//u:id"}]}}]}`,
+			wantBody: `{"id": null, "result": null, "error": "validation: match xpath \"//u:id\": prefix \"u\" of \"u:id\" is not bound in xmlNamespaces"}`,
+		},
+		{
+			name: "list",
+			body: `{"method": "Responses.List", "params": []}`,
+			wantBody: `{"id": null, "error": null, "result": [{
+				"id": "fault", "delay": 0, "status": 500, "headers": {"Content-Type": "application/soap+xml; charset=utf-8"},
+				"body": ` + string(envelope) + `, "isBodyBase64": false,
+				"match": {"soapAction": "urn:GetUser", "xpath": [{"path": "//GetUser/id", "equals": "0"}]}
+			}]}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/rpc/1", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			var got, want interface{}
+			if err := json.NewDecoder(w.Result().Body).Decode(&got); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if err := json.Unmarshal([]byte(tt.wantBody), &want); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("response json mismatch:\n got: %#v\nwant: %#v", got, want)
+			}
+		})
+	}
+}
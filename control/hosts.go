@@ -0,0 +1,29 @@
+package control
+
+import (
+	"github.com/spuf/mockable-server/storage"
+)
+
+type Hosts struct {
+	queues *storage.Queues
+}
+
+func NewHosts(queues *storage.Queues) *Hosts {
+	return &Hosts{queues: queues}
+}
+
+func (h *Hosts) List(arg Scope, reply *[]string) error {
+	if session, ok := h.queues.LookupSession(arg.Session); ok {
+		*reply = append(*reply, session.Hosts()...)
+	}
+
+	return nil
+}
+
+func (h *Hosts) Remove(arg Scope, reply *bool) error {
+	if session, ok := h.queues.LookupSession(arg.Session); ok {
+		*reply = session.RemoveHost(arg.Host)
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package control
+
+import (
+	"fmt"
+
+	"github.com/spuf/mockable-server/storage"
+)
+
+type Limit struct {
+	Capacity int    `json:"capacity"`
+	Overflow string `json:"overflow"`
+}
+
+type LimitArgs struct {
+	Queue string `json:"queue"`
+	Limit
+}
+
+type QueueStats struct {
+	Length int `json:"length"`
+	Limit
+	Evicted  int `json:"evicted"`
+	Rejected int `json:"rejected"`
+	Expired  int `json:"expired"`
+}
+
+var queueNames = [...]string{storage.QueueResponses, storage.QueueRequests}
+
+type Limits struct {
+	queues *storage.Queues
+}
+
+func NewLimits(queues *storage.Queues) *Limits {
+	return &Limits{queues: queues}
+}
+
+func (l *Limits) Get(_ struct{}, reply *map[string]Limit) error {
+	*reply = make(map[string]Limit, len(queueNames))
+	for _, queue := range queueNames {
+		limit, err := l.queues.Limit(queue)
+		if err != nil {
+			return err
+		}
+		(*reply)[queue] = Limit{Capacity: limit.Capacity, Overflow: limit.Overflow}
+	}
+
+	return nil
+}
+
+func (l *Limits) Set(arg LimitArgs, reply *bool) error {
+	limit := storage.Limit{Capacity: arg.Capacity, Overflow: arg.Overflow}
+	if err := l.queues.SetLimit(arg.Queue, limit); err != nil {
+		return fmt.Errorf("%w: %v", ErrValidation, err)
+	}
+	*reply = true
+
+	return nil
+}
+
+type Stats struct {
+	queues *storage.Queues
+}
+
+func NewStats(queues *storage.Queues) *Stats {
+	return &Stats{queues: queues}
+}
+
+func (s *Stats) Get(arg Scope, reply *map[string]QueueStats) error {
+	queues, ok := arg.lookup(s.queues)
+	if !ok {
+		// Queues of unknown scope are empty, their limits are shared with all other ones.
+		*reply = make(map[string]QueueStats, 2)
+		for _, queue := range [...]string{storage.QueueResponses, storage.QueueRequests} {
+			limit, err := s.queues.Limit(queue)
+			if err != nil {
+				return err
+			}
+			(*reply)[queue] = QueueStats{Limit: Limit{Capacity: limit.Capacity, Overflow: limit.Overflow}}
+		}
+
+		return nil
+	}
+
+	*reply = map[string]QueueStats{
+		storage.QueueResponses: queueStatsFromStore(queues.Responses),
+		storage.QueueRequests:  queueStatsFromStore(queues.Requests),
+	}
+
+	return nil
+}
+
+func queueStatsFromStore(store storage.Store) QueueStats {
+	stats := store.Stats()
+
+	return QueueStats{
+		Length:   stats.Length,
+		Limit:    Limit{Capacity: stats.Limit.Capacity, Overflow: stats.Limit.Overflow},
+		Evicted:  stats.Evicted,
+		Rejected: stats.Rejected,
+		Expired:  stats.Expired,
+	}
+}
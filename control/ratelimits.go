@@ -0,0 +1,101 @@
+package control
+
+import (
+	"fmt"
+
+	"github.com/spuf/mockable-server/storage"
+)
+
+// RateLimits answer requests exceeding them instead of serving pending responses.
+type RateLimits struct {
+	queues *storage.Queues
+}
+
+func NewRateLimits(queues *storage.Queues) *RateLimits {
+	return &RateLimits{queues: queues}
+}
+
+type RateLimit struct {
+	Name string `json:"name"`
+	// Match restricts requests the limit applies to, it applies to any request if nil.
+	Match  *storage.Matcher `json:"match,omitempty"`
+	Limit  int              `json:"limit"`
+	Period DelayDuration    `json:"period"`
+	// KeyHeader makes separate limit for each value of the request header, e.g. API key.
+	KeyHeader string `json:"keyHeader,omitempty"`
+	// Status, Headers, and Body of response to requests exceeding the limit, Status is 429 if zero.
+	Status  int     `json:"status,omitempty"`
+	Headers Headers `json:"headers,omitempty"`
+	Body    string  `json:"body,omitempty"`
+}
+
+type RateLimitArgs struct {
+	Scope
+	RateLimit
+}
+
+// List returns rate limits in order they are checked.
+func (l *RateLimits) List(arg Scope, reply *[]RateLimit) error {
+	queues, ok := arg.lookup(l.queues)
+	if !ok {
+		return nil
+	}
+
+	for _, limit := range queues.RateLimits() {
+		*reply = append(*reply, RateLimit{
+			Name:      limit.Name,
+			Match:     limit.Match,
+			Limit:     limit.Limit,
+			Period:    DelayDuration{limit.Period},
+			KeyHeader: limit.KeyHeader,
+			Status:    limit.Status,
+			Headers:   fromHttpHeaders(limit.Headers),
+			Body:      limit.Body,
+		})
+	}
+
+	return nil
+}
+
+// Set adds the rate limit, or replaces one with the same name starting it over.
+func (l *RateLimits) Set(arg RateLimitArgs, reply *bool) error {
+	if arg.Match != nil {
+		if err := validateMatcher(*arg.Match); err != nil {
+			return err
+		}
+	}
+	limit := storage.RateLimit{
+		Name:      arg.Name,
+		Match:     arg.Match,
+		Limit:     arg.Limit,
+		Period:    arg.Period.Duration,
+		KeyHeader: arg.KeyHeader,
+		Status:    arg.Status,
+		Headers:   arg.Headers.ToHttpHeaders(),
+		Body:      arg.Body,
+	}
+	if err := limit.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrValidation, err)
+	}
+
+	queues, err := arg.queues(l.queues)
+	if err != nil {
+		return err
+	}
+	if err := queues.SetRateLimit(limit); err != nil {
+		return err
+	}
+	*reply = true
+
+	return nil
+}
+
+// Remove removes the named rate limit, or all of them if name is empty.
+func (l *RateLimits) Remove(arg RateLimitArgs, reply *bool) error {
+	if queues, ok := arg.lookup(l.queues); ok {
+		queues.RemoveRateLimit(arg.Name)
+	}
+	*reply = true
+
+	return nil
+}
@@ -1,45 +1,213 @@
 package control
 
 import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/spuf/mockable-server/har"
 	"github.com/spuf/mockable-server/storage"
 )
 
 type Requests struct {
-	store storage.Store
+	queues *storage.Queues
+}
+
+func NewRequests(queues *storage.Queues) *Requests {
+	return &Requests{queues: queues}
 }
 
-func NewRequests(store storage.Store) *Requests {
-	return &Requests{store: store}
+type ListArgs struct {
+	Scope
+	// Decode adds parsed representation of body to returned requests.
+	Decode bool `json:"decode"`
+	// Snippets adds curl command and Go code sending the same request to returned requests.
+	Snippets bool `json:"snippets"`
 }
 
-func (r *Requests) List(_ struct{}, reply *[]Request) error {
-	list := r.store.List()
+// extend adds representations of the request asked by the args.
+func (a ListArgs) extend(request *Request, msg storage.Message) {
+	if a.Decode {
+		request.Decoded = decodeBody(msg.Headers, msg.Body)
+	}
+	if a.Snippets {
+		request.Snippets = snippetsFromMessage(msg)
+	}
+}
+
+func (r *Requests) List(arg ListArgs, reply *[]Request) error {
+	queues, ok := arg.lookup(r.queues)
+	if !ok {
+		return nil
+	}
+
+	list := queues.Requests.List()
 	for _, msg := range list {
 		request, err := requestFromMessage(msg)
 		if err != nil {
 			return err
 		}
+		arg.extend(request, msg)
 		*reply = append(*reply, *request)
 	}
 
 	return nil
 }
 
-func (r *Requests) Pop(_ struct{}, reply *interface{}) error {
-	if msg := r.store.PopFirst(); msg != nil {
+func (r *Requests) Pop(arg ListArgs, reply *interface{}) error {
+	queues, ok := arg.lookup(r.queues)
+	if !ok {
+		return nil
+	}
+
+	if msg := queues.Requests.PopFirst(); msg != nil {
 		request, err := requestFromMessage(*msg)
 		if err != nil {
 			return err
 		}
+		arg.extend(request, *msg)
 		*reply = *request
 	}
 
 	return nil
 }
 
-func (r *Requests) Clear(_ struct{}, reply *bool) error {
-	r.store.Clear()
+// harCreator is creator of exported HAR logs.
+var harCreator = har.Creator{Name: "mockable-server"}
+
+// ExportHAR returns captured requests with responses served for them as HAR 1.2 log.
+func (r *Requests) ExportHAR(arg Scope, reply *har.HAR) error {
+	var requests []storage.Message
+	if queues, ok := arg.lookup(r.queues); ok {
+		requests = queues.Requests.List()
+	}
+	*reply = har.Export(requests, harCreator)
+
+	return nil
+}
+
+func (r *Requests) Clear(arg Scope, reply *bool) error {
+	if queues, ok := arg.lookup(r.queues); ok {
+		queues.Requests.Clear()
+	}
 	*reply = true
 
 	return nil
 }
+
+// UnmatchedRequest is captured request no pending response was served for.
+type UnmatchedRequest struct {
+	Request
+	Candidates []storage.Candidate `json:"candidates"`
+}
+
+func (r *Requests) Unmatched(arg Scope, reply *[]UnmatchedRequest) error {
+	queues, ok := arg.lookup(r.queues)
+	if !ok {
+		return nil
+	}
+
+	for _, msg := range queues.Requests.List() {
+		if msg.Request == nil || msg.Request.Unmatched == nil {
+			continue
+		}
+		request, err := unmatchedFromMessage(msg)
+		if err != nil {
+			return err
+		}
+		*reply = append(*reply, *request)
+	}
+
+	return nil
+}
+
+func (r *Requests) Verify(arg VerifyArgs, reply *VerifyResult) error {
+	if err := validateMatcher(arg.Match); err != nil {
+		return err
+	}
+	least, most, err := arg.Count.bounds()
+	if err != nil {
+		return err
+	}
+
+	var list []storage.Message
+	if queues, ok := arg.lookup(r.queues); ok {
+		list = queues.Requests.List()
+	}
+
+	count := 0
+	for _, msg := range list {
+		if arg.Match.Matches(msg) {
+			count++
+		}
+	}
+
+	*reply = VerifyResult{
+		Pass:       count >= least && (most < 0 || count <= most),
+		Count:      count,
+		Expected:   describeBounds(least, most),
+		NearMisses: []NearMiss{},
+	}
+	if count < least {
+		reply.NearMisses, err = nearMisses(list, arg.Match, maxNearMisses)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type GetArgs struct {
+	ListArgs
+	ID string `json:"id"`
+}
+
+// Exchange is captured request with response served for it.
+type Exchange struct {
+	Request Request `json:"request"`
+	// Response is null if no response was served.
+	Response *Response `json:"response"`
+}
+
+// Get returns exchange of captured request by its ID, or null if it is not found.
+func (r *Requests) Get(arg GetArgs, reply *interface{}) error {
+	if arg.ID == "" {
+		return fmt.Errorf("%w: id must not be empty", ErrValidation)
+	}
+	queues, ok := arg.lookup(r.queues)
+	if !ok {
+		return nil
+	}
+
+	for _, msg := range queues.Requests.List() {
+		if !msg.IsRequest() || msg.Request.ID != arg.ID {
+			continue
+		}
+
+		request, err := requestFromMessage(msg)
+		if err != nil {
+			return err
+		}
+		arg.extend(request, msg)
+		exchange := Exchange{Request: *request}
+		if served := msg.Request.Served; served != nil {
+			response := Response{
+				ID:      served.ResponseID,
+				Delay:   DelayDuration{served.Delay},
+				Status:  served.Status,
+				Headers: fromHttpHeaders(served.Headers),
+				Body:    string(served.Body),
+			}
+			if isBinary(served.Headers, response.Body) {
+				response.Body = base64.StdEncoding.EncodeToString(served.Body)
+				response.IsBodyBase64 = true
+			}
+			exchange.Response = &response
+		}
+		*reply = exchange
+
+		return nil
+	}
+
+	return nil
+}
@@ -1,55 +1,167 @@
 package control
 
 import (
-	"encoding/base64"
 	"fmt"
+	"time"
+
+	"github.com/spuf/mockable-server/har"
+	"github.com/spuf/mockable-server/openapi"
 	"github.com/spuf/mockable-server/storage"
 )
 
 type Responses struct {
-	store storage.Store
+	queues *storage.Queues
+}
+
+func NewResponses(queues *storage.Queues) *Responses {
+	return &Responses{queues: queues}
+}
+
+type PushArgs struct {
+	Scope
+	Response
+	// TTL is how long the response waits to be served before it is removed, forever if zero.
+	TTL DelayDuration `json:"ttl"`
+}
+
+type DefaultArgs struct {
+	Scope
+	Response
 }
 
-func NewResponses(store storage.Store) *Responses {
-	return &Responses{store: store}
+type OpenAPIArgs struct {
+	Scope
+	// Document is OpenAPI 3 document in JSON or YAML.
+	Document string `json:"document"`
 }
 
-func (r *Responses) List(_ struct{}, reply *[]Response) error {
-	list := r.store.List()
+func (r *Responses) List(arg Scope, reply *[]Response) error {
+	queues, ok := arg.lookup(r.queues)
+	if !ok {
+		return nil
+	}
+
+	list := queues.Responses.List()
 	for _, msg := range list {
-		response := Response{
-			Delay:   DelayDuration{msg.Delay},
-			Status:  msg.Response.Status,
-			Headers: fromHttpHeaders(msg.Headers),
-			Body:    msg.Body,
-		}
-		*reply = append(*reply, response)
+		*reply = append(*reply, responseFromMessage(msg))
 	}
 
 	return nil
 }
 
-func (r *Responses) Push(arg Response, reply *bool) error {
-	if arg.Status < 100 || arg.Status >= 600 {
-		return fmt.Errorf("%w: status %d must be in [100; 600)", ErrValidation, arg.Status)
+func (r *Responses) Push(arg PushArgs, reply *bool) error {
+	msg, err := arg.Response.toMessage()
+	if err != nil {
+		return err
+	}
+	if arg.TTL.Duration > 0 {
+		msg.ExpiresAt = time.Now().Add(arg.TTL.Duration)
 	}
+	queues, err := arg.queues(r.queues)
+	if err != nil {
+		return err
+	}
+	if err := queues.Responses.PushLast(msg); err != nil {
+		return err
+	}
+
+	*reply = true
+
+	return nil
+}
+
+// ImportOpenAPI pushes stubs generated for operations of the document replacing pending ones with the same IDs, and returns them.
+func (r *Responses) ImportOpenAPI(arg OpenAPIArgs, reply *[]Response) error {
+	stubs, err := openapi.Stubs([]byte(arg.Document))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrValidation, err)
+	}
+	queues, err := arg.queues(r.queues)
+	if err != nil {
+		return err
+	}
+	// Stubs imported before, e.g. restored from journal, are replaced rather than duplicated.
+	ids := make(map[string]bool, len(stubs))
+	for _, msg := range stubs {
+		ids[msg.Response.ID] = true
+	}
+	queues.Responses.Remove(func(msg storage.Message) bool {
+		return ids[msg.Response.ID]
+	})
+	for _, msg := range stubs {
+		if err := queues.Responses.PushLast(msg); err != nil {
+			return err
+		}
+		*reply = append(*reply, responseFromMessage(msg))
+	}
+
+	return nil
+}
+
+type HARArgs struct {
+	Scope
+	// Document is HAR log in JSON.
+	Document string `json:"document"`
+	// Stub keeps imported responses in the queue after they are served.
+	Stub bool `json:"stub"`
+}
 
-	body := arg.Body
-	if arg.IsBodyBase64 {
-		decodedBody, err := base64.StdEncoding.DecodeString(arg.Body)
-		if err != nil {
-			return fmt.Errorf("failed to decode body from base64: %w", err)
+// ImportHAR pushes responses to entries of HAR log matching method and URL of their requests, and returns them.
+func (r *Responses) ImportHAR(arg HARArgs, reply *[]Response) error {
+	responses, err := har.Import([]byte(arg.Document), arg.Stub)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrValidation, err)
+	}
+	queues, err := arg.queues(r.queues)
+	if err != nil {
+		return err
+	}
+	for _, msg := range responses {
+		if err := queues.Responses.PushLast(msg); err != nil {
+			return err
 		}
-		body = string(decodedBody)
+		*reply = append(*reply, responseFromMessage(msg))
 	}
 
-	msg := storage.Message{
-		Delay:    arg.Delay.Duration,
-		Headers:  arg.Headers.ToHttpHeaders(),
-		Body:     body,
-		Response: &storage.Response{Status: arg.Status},
+	return nil
+}
+
+func (r *Responses) Clear(arg Scope, reply *bool) error {
+	if queues, ok := arg.lookup(r.queues); ok {
+		queues.Responses.Clear()
 	}
-	if err := r.store.PushLast(msg); err != nil {
+	*reply = true
+
+	return nil
+}
+
+// GetDefault returns response served when no pending response matches, or null.
+func (r *Responses) GetDefault(arg Scope, reply *interface{}) error {
+	queues, ok := arg.lookup(r.queues)
+	if !ok {
+		return nil
+	}
+
+	if msg := queues.Default(); msg != nil {
+		*reply = responseFromMessage(*msg)
+	}
+
+	return nil
+}
+
+func (r *Responses) SetDefault(arg DefaultArgs, reply *bool) error {
+	if arg.Match != nil || arg.Scenario != nil {
+		return fmt.Errorf("%w: default response must not have match or scenario", ErrValidation)
+	}
+	msg, err := arg.Response.toMessage()
+	if err != nil {
+		return err
+	}
+	queues, err := arg.queues(r.queues)
+	if err != nil {
+		return err
+	}
+	if err := queues.SetDefault(&msg); err != nil {
 		return err
 	}
 
@@ -58,8 +170,12 @@ func (r *Responses) Push(arg Response, reply *bool) error {
 	return nil
 }
 
-func (r *Responses) Clear(_ struct{}, reply *bool) error {
-	r.store.Clear()
+func (r *Responses) ClearDefault(arg Scope, reply *bool) error {
+	if queues, ok := arg.lookup(r.queues); ok {
+		if err := queues.SetDefault(nil); err != nil {
+			return err
+		}
+	}
 	*reply = true
 
 	return nil
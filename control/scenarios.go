@@ -0,0 +1,67 @@
+package control
+
+import (
+	"fmt"
+
+	"github.com/spuf/mockable-server/storage"
+)
+
+type Scenarios struct {
+	queues *storage.Queues
+}
+
+func NewScenarios(queues *storage.Queues) *Scenarios {
+	return &Scenarios{queues: queues}
+}
+
+type Scenario struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+type ScenarioArgs struct {
+	Scope
+	Scenario
+}
+
+// List returns states of scenarios, which were set or are referenced by pending responses.
+func (s *Scenarios) List(arg Scope, reply *[]Scenario) error {
+	queues, ok := arg.lookup(s.queues)
+	if !ok {
+		return nil
+	}
+
+	for _, name := range queues.ScenarioNames() {
+		*reply = append(*reply, Scenario{Name: name, State: queues.ScenarioState(name)})
+	}
+
+	return nil
+}
+
+func (s *Scenarios) Set(arg ScenarioArgs, reply *bool) error {
+	if arg.Name == "" || arg.State == "" {
+		return fmt.Errorf("%w: scenario name and state must not be empty", ErrValidation)
+	}
+	queues, err := arg.queues(s.queues)
+	if err != nil {
+		return err
+	}
+	queues.SetScenarioState(arg.Name, arg.State)
+	*reply = true
+
+	return nil
+}
+
+// Reset returns the named scenario, or all scenarios if name is empty, to the started state.
+func (s *Scenarios) Reset(arg ScenarioArgs, reply *bool) error {
+	if queues, ok := arg.lookup(s.queues); ok {
+		if arg.Name == "" {
+			queues.ResetScenarios()
+		} else {
+			queues.SetScenarioState(arg.Name, storage.ScenarioStarted)
+		}
+	}
+	*reply = true
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package control
+
+import (
+	"fmt"
+
+	"github.com/spuf/mockable-server/storage"
+)
+
+// Scope selects queues the method works with. Empty session and host refer to default queues.
+type Scope struct {
+	Session string `json:"session"`
+	Host    string `json:"host"`
+}
+
+func (s Scope) queues(root *storage.Queues) (*storage.Queues, error) {
+	session, ok := root.LookupSession(s.Session)
+	if !ok {
+		return nil, fmt.Errorf("%w: session %s not found", ErrValidation, s.Session)
+	}
+
+	return session.Host(s.Host), nil
+}
+
+func (s Scope) lookup(root *storage.Queues) (*storage.Queues, bool) {
+	session, ok := root.LookupSession(s.Session)
+	if !ok {
+		return nil, false
+	}
+
+	return session.LookupHost(s.Host)
+}
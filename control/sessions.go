@@ -0,0 +1,31 @@
+package control
+
+import (
+	"github.com/spuf/mockable-server/storage"
+)
+
+type Sessions struct {
+	queues *storage.Queues
+}
+
+func NewSessions(queues *storage.Queues) *Sessions {
+	return &Sessions{queues: queues}
+}
+
+func (s *Sessions) Create(_ struct{}, reply *string) error {
+	*reply = s.queues.CreateSession()
+
+	return nil
+}
+
+func (s *Sessions) List(_ struct{}, reply *[]string) error {
+	*reply = append(*reply, s.queues.Sessions()...)
+
+	return nil
+}
+
+func (s *Sessions) Close(arg Scope, reply *bool) error {
+	*reply = s.queues.RemoveSession(arg.Session)
+
+	return nil
+}
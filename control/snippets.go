@@ -0,0 +1,126 @@
+package control
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spuf/mockable-server/storage"
+)
+
+const (
+	// SnippetCurl is shell command sending the request with curl.
+	SnippetCurl = "curl"
+	// SnippetGo is Go code sending the request with net/http.
+	SnippetGo = "go"
+)
+
+// Snippets are code sending the same request, e.g. to reproduce it in bug report.
+type Snippets struct {
+	Curl string `json:"curl"`
+	Go   string `json:"go"`
+}
+
+func snippetsFromMessage(msg storage.Message) *Snippets {
+	return &Snippets{Curl: curlCommand(msg), Go: goCode(msg)}
+}
+
+// snippetHeaders are request headers in order of snippets, Content-Length is computed by the client.
+func snippetHeaders(headers http.Header) []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		if name != "Content-Length" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// curlCommand returns curl command sending the request, binary body is piped to it decoded from base64.
+func curlCommand(msg storage.Message) string {
+	request := *msg.Request
+	command := "curl"
+	switch {
+	case request.Method == http.MethodHead:
+		command += " --head"
+	case request.Method == http.MethodGet && msg.Body == "":
+	case request.Method == http.MethodPost && msg.Body != "":
+	default:
+		command += " -X " + shellQuote(request.Method)
+	}
+	url := request.AbsoluteURL()
+	if strings.ContainsAny(url, "[]{}") {
+		// Brackets of IPv6 host and braces are curl URL globbing otherwise.
+		command += " --globoff"
+	}
+	lines := []string{command + " " + shellQuote(url)}
+
+	for _, name := range snippetHeaders(msg.Headers) {
+		for _, value := range msg.Headers[name] {
+			lines = append(lines, "-H "+shellQuote(name+": "+value))
+		}
+	}
+
+	pipe := ""
+	if msg.Body != "" {
+		if isBinary(msg.Headers, msg.Body) || strings.ContainsRune(msg.Body, 0) {
+			pipe = "printf %s " + shellQuote(base64.StdEncoding.EncodeToString([]byte(msg.Body))) + " | base64 --decode | "
+			lines = append(lines, "--data-binary @-")
+		} else {
+			lines = append(lines, "--data-binary "+shellQuote(msg.Body))
+		}
+	}
+
+	return pipe + strings.Join(lines, " \\\n  ")
+}
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote quotes the argument for POSIX shell unless it consists of safe characters only.
+func shellQuote(arg string) string {
+	if shellSafe.MatchString(arg) {
+		return arg
+	}
+
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// goCode returns Go code sending the request with http.DefaultClient.
+func goCode(msg storage.Message) string {
+	request := *msg.Request
+	body := "nil"
+	if msg.Body != "" {
+		body = "strings.NewReader(" + goQuote(msg.Body) + ")"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "req, err := http.NewRequest(%s, %s, %s)\n", strconv.Quote(request.Method), strconv.Quote(request.AbsoluteURL()), body)
+	b.WriteString("if err != nil {\n\tpanic(err)\n}\n")
+	for _, name := range snippetHeaders(msg.Headers) {
+		for i, value := range msg.Headers[name] {
+			method := "Set"
+			if i > 0 {
+				method = "Add"
+			}
+			fmt.Fprintf(&b, "req.Header.%s(%s, %s)\n", method, strconv.Quote(name), strconv.Quote(value))
+		}
+	}
+	b.WriteString("res, err := http.DefaultClient.Do(req)\nif err != nil {\n\tpanic(err)\n}\ndefer res.Body.Close()\n")
+
+	return b.String()
+}
+
+// goQuote returns raw string literal if it keeps the value readable, otherwise interpreted one.
+func goQuote(value string) string {
+	if strconv.CanBackquote(value) {
+		return "`" + value + "`"
+	}
+
+	return strconv.Quote(value)
+}
@@ -0,0 +1,86 @@
+package control
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/spuf/mockable-server/storage"
+)
+
+func TestSnippets(t *testing.T) {
+	for _, tt := range [...]struct {
+		name     string
+		msg      storage.Message
+		wantCurl string
+		wantGo   string
+	}{
+		{
+			name: "get",
+			msg: storage.Message{
+				Headers: http.Header{"Accept": {"*/*"}},
+				Request: &storage.Request{Method: "GET", Url: "/pets?kind=dog&limit=1", Host: "api.local"},
+			},
+			wantCurl: "curl 'http://api.local/pets?kind=dog&limit=1' \\\n  -H 'Accept: */*'",
+			wantGo: "req, err := http.NewRequest(\"GET\", \"http://api.local/pets?kind=dog&limit=1\", nil)\n" +
+				"if err != nil {\n\tpanic(err)\n}\n" +
+				"req.Header.Set(\"Accept\", \"*/*\")\n" +
+				"res, err := http.DefaultClient.Do(req)\nif err != nil {\n\tpanic(err)\n}\ndefer res.Body.Close()\n",
+		},
+		{
+			name: "text body",
+			msg: storage.Message{
+				Headers: http.Header{"Content-Type": {"application/json"}, "Content-Length": {"21"}, "X-Tag": {"a", "it's"}},
+				Body:    `{"name": "O'Brien"}` + "\n",
+				Request: &storage.Request{Method: "PUT", Url: "/pets/1", Host: "::1", TLS: &storage.TLS{}},
+			},
+			wantCurl: "curl -X PUT --globoff 'https://[::1]/pets/1' \\\n" +
+				"  -H 'Content-Type: application/json' \\\n" +
+				"  -H 'X-Tag: a' \\\n" +
+				"  -H 'X-Tag: it'\\''s' \\\n" +
+				"  --data-binary '{\"name\": \"O'\\''Brien\"}\n'",
+			wantGo: "req, err := http.NewRequest(\"PUT\", \"https://[::1]/pets/1\", strings.NewReader(\"{\\\"name\\\": \\\"O'Brien\\\"}\\n\"))\n" +
+				"if err != nil {\n\tpanic(err)\n}\n" +
+				"req.Header.Set(\"Content-Type\", \"application/json\")\n" +
+				"req.Header.Set(\"X-Tag\", \"a\")\n" +
+				"req.Header.Add(\"X-Tag\", \"it's\")\n" +
+				"res, err := http.DefaultClient.Do(req)\nif err != nil {\n\tpanic(err)\n}\ndefer res.Body.Close()\n",
+		},
+		{
+			name: "binary body",
+			msg: storage.Message{
+				Headers: http.Header{"Content-Type": {"application/octet-stream"}},
+				Body:    "\x00\xff",
+				Request: &storage.Request{Method: "POST", Url: "/upload", Host: "api.local"},
+			},
+			wantCurl: "printf %s AP8= | base64 --decode | curl http://api.local/upload \\\n" +
+				"  -H 'Content-Type: application/octet-stream' \\\n" +
+				"  --data-binary @-",
+			wantGo: "req, err := http.NewRequest(\"POST\", \"http://api.local/upload\", strings.NewReader(\"\\x00\\xff\"))\n" +
+				"if err != nil {\n\tpanic(err)\n}\n" +
+				"req.Header.Set(\"Content-Type\", \"application/octet-stream\")\n" +
+				"res, err := http.DefaultClient.Do(req)\nif err != nil {\n\tpanic(err)\n}\ndefer res.Body.Close()\n",
+		},
+		{
+			name: "head with raw string body",
+			msg: storage.Message{
+				Headers: http.Header{},
+				Body:    `a="b"`,
+				Request: &storage.Request{Method: "HEAD", Url: "/", Host: "api.local"},
+			},
+			wantCurl: "curl --head http://api.local/ \\\n  --data-binary 'a=\"b\"'",
+			wantGo: "req, err := http.NewRequest(\"HEAD\", \"http://api.local/\", strings.NewReader(`a=\"b\"`))\n" +
+				"if err != nil {\n\tpanic(err)\n}\n" +
+				"res, err := http.DefaultClient.Do(req)\nif err != nil {\n\tpanic(err)\n}\ndefer res.Body.Close()\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := snippetsFromMessage(tt.msg)
+			if got.Curl != tt.wantCurl {
+				t.Errorf("curl mismatch:\n got: %s\nwant: %s", got.Curl, tt.wantCurl)
+			}
+			if got.Go != tt.wantGo {
+				t.Errorf("go mismatch:\n got: %s\nwant: %s", got.Go, tt.wantGo)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package control
+
+import (
+	"github.com/spuf/mockable-server/openapi"
+	"github.com/spuf/mockable-server/storage"
+)
+
+type State struct {
+	queues *storage.Queues
+}
+
+func NewState(queues *storage.Queues) *State {
+	return &State{queues: queues}
+}
+
+func (s *State) Export(_ struct{}, reply *storage.Snapshot) error {
+	*reply = s.queues.Export()
+
+	return nil
+}
+
+func (s *State) Import(arg storage.Snapshot, reply *bool) error {
+	if err := s.queues.Import(arg, openapi.ParseContract); err != nil {
+		return err
+	}
+	*reply = true
+
+	return nil
+}
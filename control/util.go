@@ -1,10 +1,15 @@
 package control
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"mime"
 	"net/http"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/spuf/mockable-server/storage"
 )
@@ -12,26 +17,134 @@ import (
 var ErrValidation = errors.New("validation")
 
 type Response struct {
+	// ID identifies the response in captured requests it is served for, it is generated if empty.
+	ID           string        `json:"id,omitempty"`
 	Delay        DelayDuration `json:"delay"`
 	Status       int           `json:"status"`
 	Headers      Headers       `json:"headers"`
 	Body         string        `json:"body"`
 	IsBodyBase64 bool          `json:"isBodyBase64"`
+	// Match restricts requests the response is served for, it is served for the next request if nil.
+	Match *storage.Matcher `json:"match,omitempty"`
+	// Stub stays in the queue after it is served.
+	Stub     bool                  `json:"stub,omitempty"`
+	Scenario *storage.ScenarioStep `json:"scenario,omitempty"`
+	// Callbacks are sent by mock server after the response is served, see Callbacks.List for their deliveries.
+	Callbacks []Callback `json:"callbacks,omitempty"`
+	// Variants replace status, body, and delay, and add headers, one of them is picked for each request.
+	// Status may be omitted then, the first variant one is used.
+	Variants []Variant `json:"variants,omitempty"`
+	// Pick is "random" in proportion to weights of variants if empty, or "roundRobin".
+	Pick string `json:"pick,omitempty"`
+	// Seed makes random picks reproducible.
+	Seed *int64 `json:"seed,omitempty"`
+	// SOAPFault is rendered into body, status is 500 and Content-Type is of its SOAP version if omitted.
+	SOAPFault *storage.SOAPFault `json:"soapFault,omitempty"`
 }
 
-type Request struct {
-	Method  string  `json:"method"`
+type Variant struct {
+	// Weight is relative chance of the variant to be picked randomly, 1 if zero.
+	Weight       int           `json:"weight,omitempty"`
+	Delay        DelayDuration `json:"delay"`
+	Status       int           `json:"status"`
+	Headers      Headers       `json:"headers,omitempty"`
+	Body         string        `json:"body"`
+	IsBodyBase64 bool          `json:"isBodyBase64"`
+}
+
+type Callback struct {
+	// Method is POST if empty.
+	Method  string  `json:"method,omitempty"`
 	Url     string  `json:"url"`
-	Headers Headers `json:"headers"`
-	Body    string  `json:"body"`
+	Headers Headers `json:"headers,omitempty"`
+	// Body is Go template with .Request and .Response, e.g. {{json .Request.Body}}.
+	Body    string        `json:"body,omitempty"`
+	Delay   DelayDuration `json:"delay"`
+	Retries int           `json:"retries,omitempty"`
 }
 
-type Headers map[string]string
+type Request struct {
+	ID           string  `json:"id"`
+	Method       string  `json:"method"`
+	Url          string  `json:"url"`
+	Host         string  `json:"host"`
+	Headers      Headers `json:"headers"`
+	Body         string  `json:"body"`
+	IsBodyBase64 bool    `json:"isBodyBase64"`
+	// BodyLength is length of raw body in bytes.
+	BodyLength int `json:"bodyLength"`
+
+	ReceivedAt *time.Time   `json:"receivedAt"`
+	RemoteAddr string       `json:"remoteAddr"`
+	Proto      string       `json:"proto"`
+	TLS        *storage.TLS `json:"tls,omitempty"`
+	// ContentLength is the declared length of body, -1 if unknown.
+	ContentLength    int64    `json:"contentLength"`
+	TransferEncoding []string `json:"transferEncoding,omitempty"`
+	Trailers         Headers  `json:"trailers,omitempty"`
+	Served           *Served  `json:"served,omitempty"`
+	// Violations of contract the request was checked against.
+	Violations []storage.Violation `json:"violations,omitempty"`
+	// Decoded is set only if requested.
+	Decoded *Decoded `json:"decoded,omitempty"`
+	// Snippets are set only if requested.
+	Snippets *Snippets `json:"snippets,omitempty"`
+}
+
+// Served describes queued or default response served for the request.
+type Served struct {
+	ResponseID string        `json:"responseId"`
+	Status     int           `json:"status"`
+	Latency    DelayDuration `json:"latency"`
+}
+
+// Headers keep all values of repeated headers.
+// In JSON single value is a string and multiple values are an array of strings, both forms are accepted.
+type Headers map[string][]string
+
+func (h Headers) MarshalJSON() ([]byte, error) {
+	values := make(map[string]interface{}, len(h))
+	for name, v := range h {
+		if len(v) == 1 {
+			values[name] = v[0]
+		} else {
+			values[name] = v
+		}
+	}
+
+	return json.Marshal(values)
+}
+
+func (h *Headers) UnmarshalJSON(data []byte) error {
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+
+	headers := make(Headers, len(values))
+	for name, raw := range values {
+		var value string
+		if err := json.Unmarshal(raw, &value); err == nil {
+			headers[name] = []string{value}
+			continue
+		}
+		var list []string
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return fmt.Errorf("header %s must be string or array of strings", name)
+		}
+		headers[name] = list
+	}
+	*h = headers
+
+	return nil
+}
 
 func (h *Headers) ToHttpHeaders() http.Header {
 	res := make(http.Header, len(*h))
-	for name, value := range *h {
-		res.Set(name, value)
+	for name, values := range *h {
+		for _, value := range values {
+			res.Add(name, value)
+		}
 	}
 
 	return res
@@ -40,22 +153,236 @@ func (h *Headers) ToHttpHeaders() http.Header {
 func fromHttpHeaders(h http.Header) Headers {
 	headers := make(Headers, len(h))
 	for name, values := range h {
-		headers[name] = strings.Join(values, "; ")
+		headers[name] = append([]string(nil), values...)
 	}
 
 	return headers
 }
 
+func (r Response) toMessage() (storage.Message, error) {
+	if r.Status == 0 && len(r.Variants) > 0 {
+		r.Status = r.Variants[0].Status
+	}
+	if r.Status == 0 && r.SOAPFault != nil {
+		r.Status = http.StatusInternalServerError
+	}
+	if r.Status < 100 || r.Status >= 600 {
+		return storage.Message{}, fmt.Errorf("%w: status %d must be in [100; 600)", ErrValidation, r.Status)
+	}
+	if r.Match != nil {
+		if err := validateMatcher(*r.Match); err != nil {
+			return storage.Message{}, err
+		}
+	}
+	if r.Scenario != nil {
+		if err := r.Scenario.Validate(); err != nil {
+			return storage.Message{}, fmt.Errorf("%w: %v", ErrValidation, err)
+		}
+	}
+
+	var callbacks []storage.Callback
+	for _, c := range r.Callbacks {
+		callback := storage.Callback{
+			Method:  c.Method,
+			URL:     c.Url,
+			Headers: c.Headers.ToHttpHeaders(),
+			Body:    c.Body,
+			Delay:   c.Delay.Duration,
+			Retries: c.Retries,
+		}
+		if err := callback.Validate(); err != nil {
+			return storage.Message{}, fmt.Errorf("%w: %v", ErrValidation, err)
+		}
+		callbacks = append(callbacks, callback)
+	}
+
+	var variants []storage.Variant
+	for _, v := range r.Variants {
+		variant := storage.Variant{
+			Weight:  v.Weight,
+			Status:  v.Status,
+			Delay:   v.Delay.Duration,
+			Headers: v.Headers.ToHttpHeaders(),
+			Body:    []byte(v.Body),
+		}
+		if v.IsBodyBase64 {
+			decodedBody, err := base64.StdEncoding.DecodeString(v.Body)
+			if err != nil {
+				return storage.Message{}, fmt.Errorf("failed to decode variant body from base64: %w", err)
+			}
+			variant.Body = decodedBody
+		}
+		if err := variant.Validate(); err != nil {
+			return storage.Message{}, fmt.Errorf("%w: %v", ErrValidation, err)
+		}
+		variants = append(variants, variant)
+	}
+	if r.Pick != "" && r.Pick != storage.PickRandom && r.Pick != storage.PickRoundRobin {
+		return storage.Message{}, fmt.Errorf("%w: pick %q must be %s or %s", ErrValidation, r.Pick, storage.PickRandom, storage.PickRoundRobin)
+	}
+
+	body := r.Body
+	if r.IsBodyBase64 {
+		decodedBody, err := base64.StdEncoding.DecodeString(r.Body)
+		if err != nil {
+			return storage.Message{}, fmt.Errorf("failed to decode body from base64: %w", err)
+		}
+		body = string(decodedBody)
+	}
+	headers := r.Headers.ToHttpHeaders()
+	if fault := r.SOAPFault; fault != nil {
+		if err := fault.Validate(); err != nil {
+			return storage.Message{}, fmt.Errorf("%w: %v", ErrValidation, err)
+		}
+		if body != "" {
+			return storage.Message{}, fmt.Errorf("%w: body must be empty with soapFault", ErrValidation)
+		}
+		body = fault.Envelope()
+		if headers.Get("Content-Type") == "" {
+			headers.Set("Content-Type", fault.ContentType())
+		}
+	}
+
+	id := r.ID
+	if id == "" {
+		id = storage.NewID()
+	}
+
+	return storage.Message{
+		Delay:   r.Delay.Duration,
+		Headers: headers,
+		Body:    body,
+		Response: &storage.Response{
+			ID:        id,
+			Status:    r.Status,
+			Match:     r.Match,
+			Stub:      r.Stub,
+			Scenario:  r.Scenario,
+			Callbacks: callbacks,
+			Variants:  variants,
+			Pick:      r.Pick,
+			Seed:      r.Seed,
+		},
+	}, nil
+}
+
+func responseFromMessage(msg storage.Message) Response {
+	res := Response{
+		ID:       msg.Response.ID,
+		Delay:    DelayDuration{msg.Delay},
+		Status:   msg.Response.Status,
+		Headers:  fromHttpHeaders(msg.Headers),
+		Body:     msg.Body,
+		Match:    msg.Response.Match,
+		Stub:     msg.Response.Stub,
+		Scenario: msg.Response.Scenario,
+		Pick:     msg.Response.Pick,
+		Seed:     msg.Response.Seed,
+	}
+	for _, variant := range msg.Response.Variants {
+		v := Variant{
+			Weight:  variant.Weight,
+			Delay:   DelayDuration{variant.Delay},
+			Status:  variant.Status,
+			Headers: fromHttpHeaders(variant.Headers),
+			Body:    string(variant.Body),
+		}
+		if isBinary(variant.Headers, v.Body) {
+			v.Body = base64.StdEncoding.EncodeToString(variant.Body)
+			v.IsBodyBase64 = true
+		}
+		res.Variants = append(res.Variants, v)
+	}
+	for _, callback := range msg.Response.Callbacks {
+		res.Callbacks = append(res.Callbacks, Callback{
+			Method:  callback.Method,
+			Url:     callback.URL,
+			Headers: fromHttpHeaders(callback.Headers),
+			Body:    callback.Body,
+			Delay:   DelayDuration{callback.Delay},
+			Retries: callback.Retries,
+		})
+	}
+
+	return res
+}
+
 func requestFromMessage(msg storage.Message) (*Request, error) {
 	if !msg.IsRequest() {
 		return nil, fmt.Errorf("%#v is not request", msg)
 	}
 
 	request := Request{
-		Method:  msg.Request.Method,
-		Url:     msg.Request.Url,
-		Headers: fromHttpHeaders(msg.Headers),
-		Body:    msg.Body,
+		ID:               msg.Request.ID,
+		Method:           msg.Request.Method,
+		Url:              msg.Request.Url,
+		Host:             msg.Request.Host,
+		Headers:          fromHttpHeaders(msg.Headers),
+		Body:             msg.Body,
+		BodyLength:       len(msg.Body),
+		RemoteAddr:       msg.Request.RemoteAddr,
+		Proto:            msg.Request.Proto,
+		TLS:              msg.Request.TLS,
+		ContentLength:    msg.Request.ContentLength,
+		TransferEncoding: msg.Request.TransferEncoding,
+		Violations:       msg.Request.Violations,
+	}
+	if isBinary(msg.Headers, msg.Body) {
+		request.Body = base64.StdEncoding.EncodeToString([]byte(msg.Body))
+		request.IsBodyBase64 = true
+	}
+	if receivedAt := msg.Request.ReceivedAt; !receivedAt.IsZero() {
+		request.ReceivedAt = &receivedAt
+	}
+	if len(msg.Request.Trailer) > 0 {
+		request.Trailers = fromHttpHeaders(msg.Request.Trailer)
+	}
+	if served := msg.Request.Served; served != nil {
+		request.Served = &Served{
+			ResponseID: served.ResponseID,
+			Status:     served.Status,
+			Latency:    DelayDuration{served.Latency},
+		}
 	}
 	return &request, nil
 }
+
+// binaryMediaTypes are prefixes of content types whose body is not text.
+var binaryMediaTypes = [...]string{
+	"image/", "audio/", "video/", "font/",
+	"application/octet-stream", "application/protobuf", "application/x-protobuf", "application/grpc",
+	"application/zip", "application/gzip", "application/x-gzip", "application/pdf",
+	"application/msgpack", "application/x-msgpack", "application/cbor",
+}
+
+// isBinary reports whether body can not be returned as JSON string intact.
+func isBinary(headers http.Header, body string) bool {
+	if !utf8.ValidString(body) {
+		return true
+	}
+	if encoding := headers.Get("Content-Encoding"); encoding != "" && !strings.EqualFold(encoding, "identity") {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(headers.Get("Content-Type"))
+	for _, prefix := range binaryMediaTypes {
+		if strings.HasPrefix(mediaType, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func unmatchedFromMessage(msg storage.Message) (*UnmatchedRequest, error) {
+	request, err := requestFromMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	unmatched := UnmatchedRequest{Request: *request, Candidates: []storage.Candidate{}}
+	if msg.Request.Unmatched != nil {
+		unmatched.Candidates = msg.Request.Unmatched.Candidates
+	}
+
+	return &unmatched, nil
+}
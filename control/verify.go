@@ -0,0 +1,119 @@
+package control
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spuf/mockable-server/storage"
+)
+
+// maxNearMisses limits closest non-matching requests explaining failed verification.
+const maxNearMisses = 3
+
+type VerifyArgs struct {
+	Scope
+	Match storage.Matcher `json:"match"`
+	Count Count           `json:"count"`
+}
+
+// Count expects number of matching requests, at least one if empty.
+type Count struct {
+	Exactly *int `json:"exactly"`
+	AtLeast *int `json:"atLeast"`
+	AtMost  *int `json:"atMost"`
+	Never   bool `json:"never"`
+}
+
+type VerifyResult struct {
+	Pass       bool       `json:"pass"`
+	Count      int        `json:"count"`
+	Expected   string     `json:"expected"`
+	NearMisses []NearMiss `json:"nearMisses"`
+}
+
+type NearMiss struct {
+	Request    Request            `json:"request"`
+	Mismatches []storage.Mismatch `json:"mismatches"`
+}
+
+// bounds returns inclusive range of expected count, the upper bound is negative if unbounded.
+func (c Count) bounds() (int, int, error) {
+	switch {
+	case c.Never:
+		if c.Exactly != nil || c.AtLeast != nil || c.AtMost != nil {
+			return 0, 0, fmt.Errorf("%w: never must not be combined with other counts", ErrValidation)
+		}
+		return 0, 0, nil
+	case c.Exactly != nil:
+		if c.AtLeast != nil || c.AtMost != nil {
+			return 0, 0, fmt.Errorf("%w: exactly must not be combined with other counts", ErrValidation)
+		}
+		if *c.Exactly < 0 {
+			return 0, 0, fmt.Errorf("%w: exactly %d must not be negative", ErrValidation, *c.Exactly)
+		}
+		return *c.Exactly, *c.Exactly, nil
+	}
+
+	least, most := 1, -1
+	if c.AtLeast != nil {
+		least = *c.AtLeast
+	} else if c.AtMost != nil {
+		least = 0
+	}
+	if c.AtMost != nil {
+		most = *c.AtMost
+	}
+	if least < 0 || (most >= 0 && most < least) {
+		return 0, 0, fmt.Errorf("%w: count range [%d; %d] is invalid", ErrValidation, least, most)
+	}
+
+	return least, most, nil
+}
+
+func describeBounds(least, most int) string {
+	switch {
+	case most == 0:
+		return "never"
+	case least == most:
+		return fmt.Sprintf("exactly %d", least)
+	case most < 0:
+		return fmt.Sprintf("at least %d", least)
+	case least == 0:
+		return fmt.Sprintf("at most %d", most)
+	default:
+		return fmt.Sprintf("at least %d and at most %d", least, most)
+	}
+}
+
+// nearMisses returns requests with the fewest mismatches first.
+func nearMisses(list []storage.Message, matcher storage.Matcher, limit int) ([]NearMiss, error) {
+	misses := []NearMiss{}
+	for _, msg := range list {
+		mismatches := matcher.Explain(msg)
+		if len(mismatches) == 0 {
+			continue
+		}
+		request, err := requestFromMessage(msg)
+		if err != nil {
+			return nil, err
+		}
+		misses = append(misses, NearMiss{Request: *request, Mismatches: mismatches})
+	}
+
+	sort.SliceStable(misses, func(i, j int) bool {
+		return len(misses[i].Mismatches) < len(misses[j].Mismatches)
+	})
+	if len(misses) > limit {
+		misses = misses[:limit]
+	}
+
+	return misses, nil
+}
+
+func validateMatcher(matcher storage.Matcher) error {
+	if err := matcher.Validate(); err != nil {
+		return fmt.Errorf("%w: match %v", ErrValidation, err)
+	}
+
+	return nil
+}
@@ -20,6 +20,8 @@ type tt struct {
 	request     http.Request
 	want        http.Response
 	compareJson bool
+	// ignoreResultKeys are removed from JSON-RPC result object before comparison, their values vary.
+	ignoreResultKeys []string
 }
 
 func newTextTest(name string, method, url, body string, wantStatus int, wantBody string) tt {
@@ -38,7 +40,7 @@ func newTextTest(name string, method, url, body string, wantStatus int, wantBody
 	}
 }
 
-func newJsonRpcTest(name string, url, body string, wantBody string) tt {
+func newJsonRpcTest(name string, url, body string, wantBody string, ignoreResultKeys ...string) tt {
 	request, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
 	if err != nil {
 		panic(err)
@@ -50,10 +52,24 @@ func newJsonRpcTest(name string, url, body string, wantBody string) tt {
 			StatusCode: http.StatusOK,
 			Body:       ioutil.NopCloser(strings.NewReader(wantBody)),
 		},
-		compareJson: true,
+		compareJson:      true,
+		ignoreResultKeys: ignoreResultKeys,
 	}
 }
 
+// deleteKey removes dot separated key from decoded JSON object.
+func deleteKey(v interface{}, key string) {
+	object, ok := v.(map[string]interface{})
+	if !ok {
+		return
+	}
+	if name, rest, ok := strings.Cut(key, "."); ok {
+		deleteKey(object[name], rest)
+		return
+	}
+	delete(object, key)
+}
+
 func TestE2E(t *testing.T) {
 	getUrlEnv := func(key string) *url.URL {
 		val, ok := os.LookupEnv(key)
@@ -117,6 +133,7 @@ func TestE2E(t *testing.T) {
 		newJsonRpcTest("push response", controlJsonRpc, `{
 			"method": "Responses.Push",
 			"params": [{
+				"id": "e2e",
 				"status": 201,
 				"headers": {
 					"Content-Type": "text/plain",
@@ -139,11 +156,17 @@ func TestE2E(t *testing.T) {
 			"result": {
 				"method": "GET",
 				"url": "/",
+				"host": "mockable-server",
 				"headers": {"Accept-Encoding": "gzip","User-Agent": "Go-http-client/1.1"},
-				"body": ""
+				"body": "",
+				"isBodyBase64": false,
+				"bodyLength": 0,
+				"proto": "HTTP/1.1",
+				"contentLength": 0,
+				"served": {"responseId": "e2e", "status": 201}
 			},
 			"error": null
-		}`),
+		}`, "id", "receivedAt", "remoteAddr", "served.latency"),
 		newJsonRpcTest("list requests empty", controlJsonRpc, `{
 			"method": "Requests.List",
 			"params": []
@@ -192,6 +215,12 @@ func TestE2E(t *testing.T) {
 					t.Fatalf("test body is invalid json: %v\n%v", wantBody, err)
 				}
 
+				if result, ok := gotBodyObject.(map[string]interface{}); ok {
+					for _, key := range tt.ignoreResultKeys {
+						deleteKey(result["result"], key)
+					}
+				}
+
 				if !reflect.DeepEqual(gotBodyObject, wandBodyObject) {
 					t.Errorf("response json mismatch:\n got: %#v\nwant: %#v", gotBodyObject, wandBodyObject)
 				}
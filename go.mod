@@ -1,3 +1,8 @@
 module github.com/spuf/mockable-server
 
 go 1.20
+
+require (
+	github.com/andybalholm/brotli v1.1.1
+	gopkg.in/yaml.v3 v3.0.1
+)
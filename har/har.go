@@ -0,0 +1,286 @@
+// Package har converts captured requests to HAR 1.2 log, and HAR entries to responses.
+package har
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/spuf/mockable-server/storage"
+)
+
+// Version of HAR format.
+const Version = "1.2"
+
+// HAR is HTTP Archive, see http://www.softwareishard.com/blog/har-12-spec/.
+type HAR struct {
+	Log Log `json:"log"`
+}
+
+type Log struct {
+	Version string  `json:"version"`
+	Creator Creator `json:"creator"`
+	Entries []Entry `json:"entries"`
+}
+
+type Creator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type Entry struct {
+	StartedDateTime string `json:"startedDateTime"`
+	// Time is total time of the request in milliseconds.
+	Time     float64  `json:"time"`
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+	Cache    struct{} `json:"cache"`
+	Timings  Timings  `json:"timings"`
+}
+
+type Request struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []NameValue `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	QueryString []NameValue `json:"queryString"`
+	PostData    *PostData   `json:"postData,omitempty"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+type Response struct {
+	// Status is 0 if no response was received.
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []NameValue `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	Content     Content     `json:"content"`
+	RedirectURL string      `json:"redirectURL"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+type NameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type PostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	// Encoding is base64 if Text is encoded, it is empty for text.
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type Content struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	// Encoding is base64 if Text is encoded, it is empty for text.
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// Timings are in milliseconds, time spent by mock server is in Wait.
+type Timings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// Export returns HAR log of captured requests with responses served for them.
+func Export(requests []storage.Message, creator Creator) HAR {
+	entries := make([]Entry, 0, len(requests))
+	for _, msg := range requests {
+		if msg.IsRequest() {
+			entries = append(entries, entry(msg))
+		}
+	}
+
+	return HAR{Log: Log{Version: Version, Creator: creator, Entries: entries}}
+}
+
+func entry(msg storage.Message) Entry {
+	request := *msg.Request
+	e := Entry{
+		StartedDateTime: request.ReceivedAt.Format(time.RFC3339Nano),
+		Request: Request{
+			Method:      request.Method,
+			URL:         request.AbsoluteURL(),
+			HTTPVersion: request.Proto,
+			Cookies:     cookies((&http.Request{Header: msg.Headers}).Cookies()),
+			Headers:     nameValues(msg.Headers),
+			QueryString: nameValues(request.Query()),
+			HeadersSize: -1,
+			BodySize:    len(msg.Body),
+		},
+		Response: Response{
+			HTTPVersion: request.Proto,
+			Cookies:     []NameValue{},
+			Headers:     []NameValue{},
+			Content:     Content{MimeType: "x-unknown"},
+			HeadersSize: -1,
+		},
+	}
+	if msg.Body != "" {
+		e.Request.PostData = &PostData{MimeType: msg.Headers.Get("Content-Type"), Text: msg.Body}
+		if !utf8.ValidString(msg.Body) {
+			e.Request.PostData.Text = base64.StdEncoding.EncodeToString([]byte(msg.Body))
+			e.Request.PostData.Encoding = "base64"
+		}
+	}
+
+	if served := request.Served; served != nil {
+		body := string(served.Body)
+		e.Time = milliseconds(served.Latency)
+		e.Timings.Wait = e.Time
+		e.Response.Status = served.Status
+		e.Response.StatusText = http.StatusText(served.Status)
+		e.Response.Cookies = cookies((&http.Response{Header: served.Headers}).Cookies())
+		e.Response.Headers = nameValues(served.Headers)
+		e.Response.RedirectURL = served.Headers.Get("Location")
+		e.Response.BodySize = len(body)
+		e.Response.Content = Content{Size: len(body), MimeType: served.Headers.Get("Content-Type"), Text: body}
+		if !utf8.ValidString(body) {
+			e.Response.Content.Text = base64.StdEncoding.EncodeToString(served.Body)
+			e.Response.Content.Encoding = "base64"
+		}
+	}
+
+	return e
+}
+
+// Import returns responses to entries of HAR log in its order, each one matches method, path, and query of its request.
+// Entries without response, e.g. aborted ones, are skipped.
+func Import(document []byte, stub bool) ([]storage.Message, error) {
+	var har HAR
+	if err := json.Unmarshal(document, &har); err != nil {
+		return nil, fmt.Errorf("could not parse HAR: %w", err)
+	}
+	if har.Log.Version == "" {
+		return nil, fmt.Errorf("HAR log version is missing")
+	}
+
+	var responses []storage.Message
+	for i, e := range har.Log.Entries {
+		if e.Response.Status == 0 {
+			continue
+		}
+		msg, err := response(e, stub)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d %s %s: %w", i, e.Request.Method, e.Request.URL, err)
+		}
+		responses = append(responses, msg)
+	}
+
+	return responses, nil
+}
+
+// skippedHeaders describe transfer of the recorded response rather than its content.
+var skippedHeaders = [...]string{"Connection", "Content-Encoding", "Content-Length", "Keep-Alive", "Transfer-Encoding"}
+
+func response(e Entry, stub bool) (storage.Message, error) {
+	u, err := url.Parse(e.Request.URL)
+	if err != nil {
+		return storage.Message{}, err
+	}
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	match := &storage.Matcher{Method: e.Request.Method, Path: escapeGlob(path)}
+	for name, values := range u.Query() {
+		if match.Query == nil {
+			match.Query = make(map[string]string)
+		}
+		match.Query[name] = values[0]
+	}
+
+	headers := make(http.Header)
+	for _, header := range e.Response.Headers {
+		if !strings.HasPrefix(header.Name, ":") {
+			headers.Add(header.Name, header.Value)
+		}
+	}
+	for _, name := range skippedHeaders {
+		headers.Del(name)
+	}
+	if headers.Get("Content-Type") == "" && e.Response.Content.MimeType != "" {
+		if _, _, err := mime.ParseMediaType(e.Response.Content.MimeType); err == nil {
+			headers.Set("Content-Type", e.Response.Content.MimeType)
+		}
+	}
+
+	body := e.Response.Content.Text
+	if e.Response.Content.Encoding == "base64" {
+		data, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return storage.Message{}, fmt.Errorf("content: %w", err)
+		}
+		body = string(data)
+	}
+
+	return storage.Message{
+		Headers: headers,
+		Body:    body,
+		Response: &storage.Response{
+			ID:     storage.NewID(),
+			Status: e.Response.Status,
+			Match:  match,
+			Stub:   stub,
+		},
+	}, nil
+}
+
+// nameValues returns headers or query parameters sorted by name.
+func nameValues(values map[string][]string) []NameValue {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := []NameValue{}
+	for _, name := range names {
+		for _, value := range values[name] {
+			list = append(list, NameValue{Name: name, Value: value})
+		}
+	}
+
+	return list
+}
+
+func cookies(cookies []*http.Cookie) []NameValue {
+	list := make([]NameValue, 0, len(cookies))
+	for _, cookie := range cookies {
+		list = append(list, NameValue{Name: cookie.Name, Value: cookie.Value})
+	}
+
+	return list
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// escapeGlob escapes glob characters, so the pattern matches only the path itself.
+func escapeGlob(path string) string {
+	var b strings.Builder
+	for _, c := range path {
+		if strings.ContainsRune(`*?[\`, c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,159 @@
+package har
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spuf/mockable-server/storage"
+)
+
+func TestExport(t *testing.T) {
+	receivedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	requests := []storage.Message{
+		{
+			Headers: http.Header{"Content-Type": {"application/json"}, "Cookie": {"sid=abc"}},
+			Body:    `{"name":"Rex"}`,
+			Request: &storage.Request{
+				Method: "POST", Url: "/pets?kind=dog", Host: "api.local", Proto: "HTTP/1.1", ReceivedAt: receivedAt,
+				TLS: &storage.TLS{Version: "TLS 1.3"},
+				Served: &storage.Served{
+					Status:  201,
+					Headers: http.Header{"Location": {"/pets/1"}, "Set-Cookie": {"seen=1; Path=/"}},
+					Body:    []byte("\xff"),
+					Latency: 1500 * time.Microsecond,
+				},
+			},
+		},
+		{
+			Headers: http.Header{},
+			Body:    "\x00\xff",
+			Request: &storage.Request{Method: "PUT", Url: "/unknown", Host: "api.local", RawHost: "API.local:8010", Proto: "HTTP/1.1", ReceivedAt: receivedAt},
+		},
+	}
+
+	got := Export(requests, Creator{Name: "test", Version: "1"})
+	want := HAR{Log: Log{
+		Version: "1.2",
+		Creator: Creator{Name: "test", Version: "1"},
+		Entries: []Entry{
+			{
+				StartedDateTime: "2024-01-02T03:04:05Z",
+				Time:            1.5,
+				Request: Request{
+					Method: "POST", URL: "https://api.local/pets?kind=dog", HTTPVersion: "HTTP/1.1",
+					Cookies:     []NameValue{{Name: "sid", Value: "abc"}},
+					Headers:     []NameValue{{Name: "Content-Type", Value: "application/json"}, {Name: "Cookie", Value: "sid=abc"}},
+					QueryString: []NameValue{{Name: "kind", Value: "dog"}},
+					PostData:    &PostData{MimeType: "application/json", Text: `{"name":"Rex"}`},
+					HeadersSize: -1, BodySize: 14,
+				},
+				Response: Response{
+					Status: 201, StatusText: "Created", HTTPVersion: "HTTP/1.1",
+					Cookies:     []NameValue{{Name: "seen", Value: "1"}},
+					Headers:     []NameValue{{Name: "Location", Value: "/pets/1"}, {Name: "Set-Cookie", Value: "seen=1; Path=/"}},
+					Content:     Content{Size: 1, Text: "/w==", Encoding: "base64"},
+					RedirectURL: "/pets/1",
+					HeadersSize: -1, BodySize: 1,
+				},
+				Timings: Timings{Wait: 1.5},
+			},
+			{
+				StartedDateTime: "2024-01-02T03:04:05Z",
+				Request: Request{
+					Method: "PUT", URL: "http://API.local:8010/unknown", HTTPVersion: "HTTP/1.1",
+					Cookies: []NameValue{}, Headers: []NameValue{}, QueryString: []NameValue{},
+					PostData:    &PostData{Text: "AP8=", Encoding: "base64"},
+					HeadersSize: -1, BodySize: 2,
+				},
+				Response: Response{
+					HTTPVersion: "HTTP/1.1", Cookies: []NameValue{}, Headers: []NameValue{},
+					Content: Content{MimeType: "x-unknown"}, HeadersSize: -1,
+				},
+			},
+		},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mismatch HAR:\n got: %#v\nwant: %#v", got, want)
+	}
+}
+
+func TestImport(t *testing.T) {
+	document := `{"log": {"version": "1.2", "creator": {"name": "browser", "version": "1"}, "entries": [
+		{
+			"request": {"method": "GET", "url": "https://api.example.com/pets/[1]?kind=dog&kind=cat", "headers": []},
+			"response": {
+				"status": 200,
+				"headers": [
+					{"name": ":status", "value": "200"},
+					{"name": "content-encoding", "value": "gzip"},
+					{"name": "content-length", "value": "12"},
+					{"name": "x-request-id", "value": "a"},
+					{"name": "x-request-id", "value": "b"}
+				],
+				"content": {"size": 12, "mimeType": "application/json", "text": "[{\"id\":1}]"}
+			}
+		},
+		{
+			"request": {"method": "GET", "url": "https://api.example.com/logo.png"},
+			"response": {"status": 200, "headers": [], "content": {"mimeType": "image/png", "text": "iVBORw==", "encoding": "base64"}}
+		},
+		{
+			"request": {"method": "GET", "url": "https://api.example.com/aborted"},
+			"response": {"status": 0, "headers": [], "content": {}}
+		}
+	]}}`
+
+	got, err := Import([]byte(document), true)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	for i := range got {
+		if len(got[i].Response.ID) != 32 {
+			t.Errorf("unexpected id %q", got[i].Response.ID)
+		}
+		got[i].Response.ID = ""
+	}
+	want := []storage.Message{
+		{
+			Headers: http.Header{"Content-Type": {"application/json"}, "X-Request-Id": {"a", "b"}},
+			Body:    `[{"id":1}]`,
+			Response: &storage.Response{Status: 200, Stub: true, Match: &storage.Matcher{
+				Method: "GET", Path: `/pets/\[1]`, Query: map[string]string{"kind": "dog"},
+			}},
+		},
+		{
+			Headers:  http.Header{"Content-Type": {"image/png"}},
+			Body:     "\x89PNG",
+			Response: &storage.Response{Status: 200, Stub: true, Match: &storage.Matcher{Method: "GET", Path: "/logo.png"}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		data, _ := json.Marshal(got)
+		t.Errorf("mismatch responses:\n got: %s\nwant: %#v", data, want)
+	}
+	if !got[0].Response.Match.Matches(storage.Message{Headers: http.Header{}, Request: &storage.Request{Method: "GET", Url: "/pets/%5B1%5D?kind=cat&kind=dog"}}) {
+		t.Errorf("response must match its request")
+	}
+}
+
+func TestImportInvalid(t *testing.T) {
+	for _, tt := range [...]struct {
+		document string
+		want     string
+	}{
+		{document: `{`, want: "could not parse HAR"},
+		{document: `{"entries": []}`, want: "HAR log version is missing"},
+		{
+			document: `{"log": {"version": "1.2", "entries": [{"request": {"method": "GET", "url": "/"}, "response": {"status": 200, "content": {"text": "!", "encoding": "base64"}}}]}}`,
+			want:     "entry 0 GET /: content: illegal base64 data",
+		},
+	} {
+		if _, err := Import([]byte(tt.document), false); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: unexpected error %v", tt.document, err)
+		}
+	}
+}
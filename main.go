@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
@@ -11,18 +12,36 @@ import (
 	"os/signal"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/spuf/mockable-server/control"
 	"github.com/spuf/mockable-server/middleware"
 	"github.com/spuf/mockable-server/mock"
+	"github.com/spuf/mockable-server/openapi"
 	"github.com/spuf/mockable-server/storage"
 )
 
 var (
-	Application = "mockable-server"
-	Version     string
-	mockAddr    string
-	controlAddr string
+	Application     = "mockable-server"
+	Version         string
+	mockAddr        string
+	controlAddr     string
+	unknownHost     string
+	sessionHeader   string
+	requestIDHeader string
+	sessionIdle     time.Duration
+	retention       time.Duration
+	importState     string
+	dataDir         string
+	defaultRes      string
+	openAPI         string
+	contract        string
+	strict          bool
+	contractStrict  bool
+	limits          = map[string]*storage.Limit{
+		storage.QueueRequests:  {Overflow: storage.OverflowDropOldest},
+		storage.QueueResponses: {Overflow: storage.OverflowReject},
+	}
 )
 
 func main() {
@@ -33,6 +52,23 @@ func main() {
 
 	flag.StringVar(&mockAddr, "mock-addr", ":8010", "Mock server address")
 	flag.StringVar(&controlAddr, "control-addr", ":8020", "Control server address")
+	flag.StringVar(&unknownHost, "unknown-host", mock.UnknownHostDefault, fmt.Sprintf("Fallback for requests to hosts without queues: %s or %s", mock.UnknownHostDefault, mock.UnknownHostReject))
+	flag.StringVar(&sessionHeader, "session-header", "X-Mock-Session", "Request header selecting session")
+	flag.StringVar(&requestIDHeader, "request-id-header", "", "Response header with ID of captured request, e.g. X-Mock-Request-Id, not sent if empty")
+	for _, queue := range [...]string{storage.QueueRequests, storage.QueueResponses} {
+		limit := limits[queue]
+		flag.IntVar(&limit.Capacity, queue+"-limit", 0, fmt.Sprintf("Capacity of %s queue, 0 is unlimited", queue))
+		flag.StringVar(&limit.Overflow, queue+"-overflow", limit.Overflow, fmt.Sprintf("Handling of %s exceeding capacity: %s, %s, or %s", queue, storage.OverflowDropOldest, storage.OverflowDropNewest, storage.OverflowReject))
+	}
+	flag.StringVar(&dataDir, "data-dir", "", "Directory to persist queues in, they are kept in memory only if empty")
+	flag.StringVar(&importState, "import-state", "", "Load queues from file downloaded from control /state")
+	flag.DurationVar(&retention, "requests-retention", 0, "Captured requests older than this are removed, 0 keeps them forever")
+	flag.StringVar(&defaultRes, "default-response", "", `Response served when no pending one matches as JSON like {"status": 200, "body": "OK"}, HTTP 501 if empty`)
+	flag.StringVar(&openAPI, "openapi", "", "Push stubs generated from OpenAPI 3 document file in JSON or YAML")
+	flag.StringVar(&contract, "contract", "", "Check requests against OpenAPI 3 document file in JSON or YAML, violations are listed with captured requests")
+	flag.BoolVar(&strict, "strict", false, "Record unmatched requests as failures listed by Failures.List")
+	flag.BoolVar(&contractStrict, "contract-strict", false, "Answer requests violating contract with 400 and record them as failures")
+	flag.DurationVar(&sessionIdle, "session-idle", 10*time.Minute, "Sessions unused for this long are removed, 0 keeps them forever")
 
 	flag.VisitAll(func(f *flag.Flag) {
 		envName := strings.ReplaceAll(strings.ToUpper(f.Name), "-", "_")
@@ -46,6 +82,11 @@ func main() {
 	})
 	flag.Parse()
 
+	if unknownHost != mock.UnknownHostDefault && unknownHost != mock.UnknownHostReject {
+		fmt.Fprintf(os.Stderr, "invalid unknown-host value: %s\n", unknownHost)
+		os.Exit(2)
+	}
+
 	logFlags := log.LstdFlags | log.Lmsgprefix
 	if Version == "" {
 		logFlags = logFlags | log.Lshortfile
@@ -54,6 +95,51 @@ func main() {
 	mockLogger := log.New(os.Stdout, "[mock] ", logFlags)
 
 	queues := storage.NewQueues()
+	if dataDir != "" {
+		var err error
+		queues, err = storage.OpenQueues(dataDir, controlLogger)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer queues.Close()
+		controlLogger.Printf("Queues are persisted to %s", dataDir)
+	}
+	for queue, limit := range limits {
+		if err := queues.SetLimit(queue, *limit); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid %s limit: %v\n", queue, err)
+			os.Exit(2)
+		}
+	}
+	if importState != "" {
+		if err := loadState(queues, importState); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		controlLogger.Printf("State imported from %s", importState)
+	}
+	if defaultRes != "" {
+		if err := setDefault(queues, defaultRes); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid default-response: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	if openAPI != "" {
+		count, err := importOpenAPI(queues, openAPI)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		controlLogger.Printf("Pushed %d stubs from %s", count, openAPI)
+	}
+	if contract != "" {
+		if err := setContract(queues, contract); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		controlLogger.Printf("Requests are checked against %s", contract)
+	}
+
 	servers := [...]*http.Server{
 		{
 			Addr: controlAddr,
@@ -66,7 +152,14 @@ func main() {
 			Addr: mockAddr,
 			Handler: middleware.NewServerHandler(fmt.Sprintf("%s %s", Application, Version),
 				middleware.NewLoggerHandler(mockLogger,
-					mock.NewHandler(queues))),
+					mock.NewHandler(queues, mock.Config{
+						UnknownHost:       unknownHost,
+						SessionHeader:     sessionHeader,
+						RequestsRetention: retention,
+						RequestIDHeader:   requestIDHeader,
+						Strict:            strict,
+						ContractStrict:    contractStrict,
+					}))),
 			ErrorLog: mockLogger,
 		},
 	}
@@ -74,6 +167,8 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	go janitor(ctx, queues, sessionIdle, controlLogger)
+
 	serverErrors := make(chan error, len(servers))
 	quitSignal := make(chan os.Signal, 1)
 	signal.Notify(quitSignal, os.Interrupt)
@@ -119,3 +214,83 @@ func main() {
 		close(serverErrors)
 	}
 }
+
+// janitor removes expired messages, and sessions unused for idle unless it is zero.
+func janitor(ctx context.Context, queues *storage.Queues, idle time.Duration, logger *log.Logger) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, expiration := range queues.Expire(now) {
+				scope := strings.Join(expiration.Scope, "/")
+				if scope == "" {
+					scope = "default"
+				}
+				logger.Printf("Expired %d %s in %s queues", expiration.Count, expiration.Queue, scope)
+			}
+
+			if idle > 0 {
+				for _, id := range queues.ExpireSessions(idle) {
+					logger.Printf("Session %s expired", id)
+				}
+			}
+		}
+	}
+}
+
+func loadState(queues *storage.Queues, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var snapshot storage.Snapshot
+	if err := json.NewDecoder(f).Decode(&snapshot); err != nil {
+		return fmt.Errorf("could not decode state %s: %w", name, err)
+	}
+
+	return queues.Import(snapshot, openapi.ParseContract)
+}
+
+func setDefault(queues *storage.Queues, data string) error {
+	var arg control.DefaultArgs
+	if err := json.Unmarshal([]byte(data), &arg); err != nil {
+		return err
+	}
+
+	var ok bool
+	return control.NewResponses(queues).SetDefault(arg, &ok)
+}
+
+func importOpenAPI(queues *storage.Queues, name string) (int, error) {
+	document, err := os.ReadFile(name)
+	if err != nil {
+		return 0, err
+	}
+
+	var stubs []control.Response
+	if err := control.NewResponses(queues).ImportOpenAPI(control.OpenAPIArgs{Document: string(document)}, &stubs); err != nil {
+		return 0, fmt.Errorf("could not import OpenAPI document %s: %w", name, err)
+	}
+
+	return len(stubs), nil
+}
+
+func setContract(queues *storage.Queues, name string) error {
+	document, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	var ok bool
+	if err := control.NewContracts(queues).Set(control.OpenAPIArgs{Document: string(document)}, &ok); err != nil {
+		return fmt.Errorf("could not set contract %s: %w", name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package mock
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/spuf/mockable-server/storage"
+)
+
+const (
+	// callbackTimeout limits each attempt to send a callback.
+	callbackTimeout = 30 * time.Second
+	// callbackRetryDelay is multiplied by the attempt number to wait before retrying.
+	callbackRetryDelay = 100 * time.Millisecond
+)
+
+// callbackData is available in callback body template, e.g. {{.Request.Url}}.
+type callbackData struct {
+	Request  callbackRequest
+	Response callbackResponse
+}
+
+type callbackRequest struct {
+	ID      string
+	Method  string
+	Url     string
+	Host    string
+	Headers http.Header
+	Body    string
+}
+
+type callbackResponse struct {
+	ID      string
+	Status  int
+	Headers http.Header
+	Body    string
+}
+
+// callback sends the callback of the response served for the request, and records its delivery to queues.
+func (m *mock) callback(queues *storage.Queues, callback storage.Callback, request, response storage.Message) {
+	delivery := storage.Delivery{
+		ID:         storage.NewID(),
+		ResponseID: response.Response.ID,
+		RequestID:  request.Request.ID,
+		Method:     callback.Method,
+		URL:        callback.URL,
+	}
+	if delivery.Method == "" {
+		delivery.Method = http.MethodPost
+	}
+
+	var body bytes.Buffer
+	tmpl, err := callback.Template()
+	if err == nil {
+		err = tmpl.Execute(&body, callbackData{
+			Request: callbackRequest{
+				ID:      request.Request.ID,
+				Method:  request.Request.Method,
+				Url:     request.Request.Url,
+				Host:    request.Request.Host,
+				Headers: request.Headers,
+				Body:    request.Body,
+			},
+			Response: callbackResponse{
+				ID:      response.Response.ID,
+				Status:  response.Response.Status,
+				Headers: response.Headers,
+				Body:    response.Body,
+			},
+		})
+	}
+	if err != nil {
+		delivery.SentAt = time.Now()
+		delivery.Error = err.Error()
+		queues.Deliver(delivery)
+		return
+	}
+
+	time.Sleep(callback.Delay)
+	delivery.SentAt = time.Now()
+	for attempt := 0; attempt <= callback.Retries; attempt++ {
+		time.Sleep(time.Duration(attempt) * callbackRetryDelay)
+		delivery.Attempts++
+
+		status, err := m.send(delivery.Method, delivery.URL, callback.Headers, body.Bytes())
+		delivery.Status = status
+		delivery.Error = ""
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+		if err == nil && status < http.StatusInternalServerError {
+			break
+		}
+	}
+	delivery.Latency = time.Since(delivery.SentAt)
+	queues.Deliver(delivery)
+}
+
+// send makes HTTP request and returns status of its response.
+func (m *mock) send(method, url string, headers http.Header, body []byte) (int, error) {
+	r, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	r.Header = headers.Clone()
+	if r.Header == nil {
+		r.Header = make(http.Header)
+	}
+
+	res, err := m.client.Do(r)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if _, err := io.Copy(io.Discard, res.Body); err != nil {
+		return res.StatusCode, err
+	}
+
+	return res.StatusCode, nil
+}
@@ -2,47 +2,174 @@ package mock
 
 import (
 	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/spuf/mockable-server/storage"
 )
 
+const (
+	// UnknownHostDefault serves requests to unknown hosts from default queues.
+	UnknownHostDefault = "default"
+	// UnknownHostReject answers requests to unknown hosts with 421 Misdirected Request.
+	UnknownHostReject = "reject"
+)
+
+// SessionPathPrefix followed by session ID and slash selects the session by path, e.g. /_s/{id}/path.
+const SessionPathPrefix = "/_s/"
+
+type Config struct {
+	// UnknownHost is the fallback for requests whose Host has no queues, UnknownHostDefault if empty.
+	UnknownHost string
+	// SessionHeader is the request header with session ID, sessions are selected only by path if empty.
+	SessionHeader string
+	// RequestsRetention is how long captured requests are kept, forever if zero.
+	RequestsRetention time.Duration
+	// RequestIDHeader is the response header with ID of captured request, it is not sent if empty.
+	RequestIDHeader string
+	// Strict records every unmatched request as failure, even if it is served by default response.
+	Strict bool
+	// ContractStrict answers requests violating contract with 400 Bad Request and records them as failures.
+	ContractStrict bool
+}
+
 type mock struct {
 	queues *storage.Queues
+	config Config
+	// client sends callbacks of served responses.
+	client *http.Client
 }
 
-func NewHandler(queues *storage.Queues) http.Handler {
-	return &mock{queues: queues}
+func NewHandler(queues *storage.Queues, config Config) http.Handler {
+	return &mock{queues: queues, config: config, client: &http.Client{Timeout: callbackTimeout}}
 }
 
 func (m *mock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	receivedAt := time.Now()
+
 	var body bytes.Buffer
 	if _, err := body.ReadFrom(r.Body); err != nil {
 		panic(err)
 	}
 
+	sessionID := m.session(r)
+
 	message := storage.Message{
 		Headers: r.Header,
 		Body:    body.String(),
 		Request: &storage.Request{
-			Method: r.Method,
-			Url:    r.URL.RequestURI(),
+			ID:               storage.NewID(),
+			Method:           r.Method,
+			Url:              r.URL.RequestURI(),
+			Host:             storage.NormalizeHost(r.Host),
+			RawHost:          r.Host,
+			ReceivedAt:       receivedAt,
+			RemoteAddr:       r.RemoteAddr,
+			Proto:            r.Proto,
+			TLS:              tlsFromState(r.TLS),
+			ContentLength:    r.ContentLength,
+			TransferEncoding: r.TransferEncoding,
+			Trailer:          r.Trailer,
 		},
 	}
-	if err := m.queues.Requests.PushLast(message); err != nil {
-		panic(err)
+	if m.config.RequestsRetention > 0 {
+		message.ExpiresAt = receivedAt.Add(m.config.RequestsRetention)
+	}
+	if m.config.RequestIDHeader != "" {
+		w.Header().Set(m.config.RequestIDHeader, message.Request.ID)
 	}
 
-	res := m.queues.Responses.PopFirst()
-	if res == nil {
-		status := http.StatusNotImplemented
+	session, isKnownSession := m.queues.LookupSession(sessionID)
+	if !isKnownSession {
+		if !m.capture(w, m.queues, message) {
+			return
+		}
+
+		status := http.StatusMisdirectedRequest
+		http.Error(w, "Unknown session", status)
+		return
+	}
+
+	queues, isKnownHost := session.LookupHost(r.Host)
+	if !isKnownHost {
+		queues = session
+	}
+
+	if !isKnownHost && m.config.UnknownHost == UnknownHostReject {
+		if !m.capture(w, queues, message) {
+			return
+		}
+
+		status := http.StatusMisdirectedRequest
 		http.Error(w, http.StatusText(status), status)
 		return
 	}
 
+	if isFull(queues.Requests) {
+		// Request is rejected before any response is served for it.
+		m.capture(w, queues, message)
+		return
+	}
+
+	if contract := nearestContract(queues, session, m.queues); contract != nil {
+		message.Request.Violations = contract.Validate(message)
+	}
+	if len(message.Request.Violations) > 0 && m.config.ContractStrict {
+		m.badRequest(w, queues, message, receivedAt)
+		return
+	}
+
+	var res *storage.Message
+	var unmatched *storage.Unmatched
+	if quota, ok := tightest(throttle(message, receivedAt, queues, session, m.queues)); ok && quota.Exceeded {
+		// Pending responses are kept for requests within the limit.
+		throttled := quota.Response()
+		res = &throttled
+	} else {
+		if ok {
+			for name, values := range quota.Headers() {
+				w.Header()[name] = values
+			}
+		}
+		res, unmatched = queues.Serve(message)
+		if res == nil {
+			res = fallback(queues, session, m.queues)
+		}
+	}
+	message.Request.Unmatched = unmatched
+	if res != nil {
+		message.Request.Served = &storage.Served{
+			ResponseID: res.Response.ID,
+			Status:     res.Response.Status,
+			Delay:      res.Delay,
+			Headers:    res.Headers,
+			Body:       []byte(res.Body),
+			Latency:    time.Since(receivedAt) + res.Delay,
+		}
+	}
+	if !m.capture(w, queues, message) {
+		return
+	}
+
+	if unmatched != nil && m.config.Strict {
+		if err := queues.Fail(message); err != nil {
+			panic(err)
+		}
+	}
+	if res == nil {
+		m.notImplemented(w, *message.Request)
+		return
+	}
+
+	// Headers of the response replace ones set above, e.g. X-RateLimit-Remaining.
 	for name, values := range res.Headers {
+		w.Header().Del(name)
 		for _, value := range values {
 			w.Header().Add(name, value)
 		}
@@ -53,4 +180,204 @@ func (m *mock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if _, err := io.WriteString(w, res.Body); err != nil {
 		panic(err)
 	}
+
+	for _, callback := range res.Response.Callbacks {
+		go m.callback(queues, callback, message, *res)
+	}
+}
+
+// capture stores the request, or answers with 507 Insufficient Storage if Requests queue rejects it.
+func (m *mock) capture(w http.ResponseWriter, queues *storage.Queues, message storage.Message) bool {
+	err := queues.Requests.PushLast(message)
+	if errors.Is(err, storage.ErrQueueFull) {
+		http.Error(w, err.Error(), http.StatusInsufficientStorage)
+		return false
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	return true
+}
+
+func tlsFromState(state *tls.ConnectionState) *storage.TLS {
+	if state == nil {
+		return nil
+	}
+
+	return &storage.TLS{
+		Version:            tlsVersionName(state.Version),
+		CipherSuite:        tls.CipherSuiteName(state.CipherSuite),
+		ServerName:         state.ServerName,
+		NegotiatedProtocol: state.NegotiatedProtocol,
+	}
+}
+
+// tlsVersionName returns name of TLS version like "TLS 1.3", or its hex value if unknown.
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	default:
+		return fmt.Sprintf("0x%04X", version)
+	}
+}
+
+// fallback returns default response of the nearest queues: host, then session, then root ones.
+func fallback(scopes ...*storage.Queues) *storage.Message {
+	for _, queues := range scopes {
+		if res := queues.ServeDefault(); res != nil {
+			return res
+		}
+	}
+
+	return nil
+}
+
+// nearestContract returns contract of the nearest queues: host, then session, then root ones.
+func nearestContract(scopes ...*storage.Queues) storage.Contract {
+	for _, queues := range scopes {
+		if contract := queues.Contract(); contract != nil {
+			return contract
+		}
+	}
+
+	return nil
+}
+
+// throttle checks the request against rate limits of the nearest queues having them: host, then session, then root ones.
+func throttle(message storage.Message, now time.Time, scopes ...*storage.Queues) []storage.Quota {
+	for _, queues := range scopes {
+		if len(queues.RateLimits()) > 0 {
+			return queues.Throttle(message, now)
+		}
+	}
+
+	return nil
+}
+
+// tightest returns the exceeded quota, or the one with the least remaining requests.
+func tightest(quotas []storage.Quota) (storage.Quota, bool) {
+	if len(quotas) == 0 {
+		return storage.Quota{}, false
+	}
+
+	tightest := quotas[len(quotas)-1]
+	if tightest.Exceeded {
+		return tightest, true
+	}
+	for _, quota := range quotas {
+		if quota.Remaining < tightest.Remaining {
+			tightest = quota
+		}
+	}
+
+	return tightest, true
+}
+
+// isFull reports whether the queue rejects the next message.
+func isFull(queue storage.Store) bool {
+	stats := queue.Stats()
+
+	return stats.Limit.Overflow == storage.OverflowReject && stats.Limit.Capacity > 0 && stats.Length >= stats.Limit.Capacity
+}
+
+// notImplemented answers with 501 Not Implemented explaining why pending responses do not match the request.
+func (m *mock) notImplemented(w http.ResponseWriter, request storage.Request) {
+	status := http.StatusNotImplemented
+	body, err := json.MarshalIndent(struct {
+		Error      string              `json:"error"`
+		Method     string              `json:"method"`
+		Url        string              `json:"url"`
+		Host       string              `json:"host"`
+		Candidates []storage.Candidate `json:"candidates"`
+	}{
+		Error:      http.StatusText(status),
+		Method:     request.Method,
+		Url:        request.Url,
+		Host:       request.Host,
+		Candidates: request.Unmatched.Candidates,
+	}, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	if _, err := w.Write(append(body, '\n')); err != nil {
+		panic(err)
+	}
+}
+
+// badRequest answers with 400 Bad Request listing contract violations of the request, and records it as failure.
+func (m *mock) badRequest(w http.ResponseWriter, queues *storage.Queues, message storage.Message, receivedAt time.Time) {
+	status := http.StatusBadRequest
+	body, err := json.MarshalIndent(struct {
+		Error      string              `json:"error"`
+		Method     string              `json:"method"`
+		Url        string              `json:"url"`
+		Host       string              `json:"host"`
+		Violations []storage.Violation `json:"violations"`
+	}{
+		Error:      http.StatusText(status),
+		Method:     message.Request.Method,
+		Url:        message.Request.Url,
+		Host:       message.Request.Host,
+		Violations: message.Request.Violations,
+	}, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	body = append(body, '\n')
+
+	headers := http.Header{
+		"Content-Type":           {"application/json"},
+		"X-Content-Type-Options": {"nosniff"},
+	}
+	message.Request.Served = &storage.Served{
+		Status:  status,
+		Headers: headers,
+		Body:    body,
+		Latency: time.Since(receivedAt),
+	}
+	if !m.capture(w, queues, message) {
+		return
+	}
+	if err := queues.Fail(message); err != nil {
+		panic(err)
+	}
+
+	for name, values := range headers {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(status)
+	if _, err := w.Write(body); err != nil {
+		panic(err)
+	}
+}
+
+// session returns session ID of the request, stripping SessionPathPrefix from its URL.
+func (m *mock) session(r *http.Request) string {
+	if rest, ok := strings.CutPrefix(r.URL.Path, SessionPathPrefix); ok {
+		if id, path, ok := strings.Cut(rest, "/"); ok && id != "" {
+			r.URL.Path = "/" + path
+			if rawRest, ok := strings.CutPrefix(r.URL.RawPath, SessionPathPrefix+id); ok {
+				r.URL.RawPath = rawRest
+			}
+			return id
+		}
+	}
+
+	if m.config.SessionHeader != "" {
+		return r.Header.Get(m.config.SessionHeader)
+	}
+
+	return ""
 }
@@ -1,12 +1,15 @@
 package mock
 
 import (
+	"crypto/tls"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/spuf/mockable-server/storage"
 )
@@ -17,15 +20,26 @@ func TestHandlerNoResponse(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	queues := storage.NewQueues()
-	handler := NewHandler(queues)
+	handler := NewHandler(queues, Config{})
 	handler.ServeHTTP(w, r)
 
 	got := w.Result()
 	if got.StatusCode != 501 {
 		t.Errorf("unexpected status code: %v", got.StatusCode)
 	}
+	if got.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected content type: %v", got.Header.Get("Content-Type"))
+	}
 	gotBody, _ := io.ReadAll(got.Body)
-	if string(gotBody) != "Not Implemented\n" {
+	wantBody := `{
+  "error": "Not Implemented",
+  "method": "GET",
+  "url": "/base/../path?query",
+  "host": "example.com",
+  "candidates": []
+}
+`
+	if string(gotBody) != wantBody {
 		t.Errorf("unexpected body: %v", string(gotBody))
 	}
 
@@ -36,16 +50,48 @@ func TestHandlerNoResponse(t *testing.T) {
 		},
 		Body: "",
 		Request: &storage.Request{
-			Method: "GET",
-			Url:    "/base/../path?query",
+			Method:     "GET",
+			Url:        "/base/../path?query",
+			Host:       "example.com",
+			RawHost:    "example.com",
+			RemoteAddr: "192.0.2.1:1234",
+			Proto:      "HTTP/1.1",
+			Unmatched: &storage.Unmatched{
+				Candidates: []storage.Candidate{},
+			},
 		},
 	}
 
+	clearVolatile(t, msg)
 	if !reflect.DeepEqual(msg, want) {
 		t.Errorf("mismatch request:\n got: %#v\nwant:%#v", msg, want)
 	}
 }
 
+// clearVolatile checks and clears fields of captured request, which differ on every run.
+func clearVolatile(t *testing.T, msg *storage.Message) {
+	t.Helper()
+
+	if msg == nil || msg.Request == nil {
+		t.Fatalf("request must be captured: %#v", msg)
+	}
+	if msg.Request.ID == "" {
+		t.Errorf("request must have ID")
+	}
+	if msg.Request.ReceivedAt.IsZero() || time.Since(msg.Request.ReceivedAt) > time.Minute {
+		t.Errorf("unexpected ReceivedAt: %v", msg.Request.ReceivedAt)
+	}
+	msg.Request.ID = ""
+	msg.Request.ReceivedAt = time.Time{}
+
+	if served := msg.Request.Served; served != nil {
+		if served.Latency <= 0 {
+			t.Errorf("unexpected Latency: %v", served.Latency)
+		}
+		served.Latency = 0
+	}
+}
+
 func TestHandler(t *testing.T) {
 	r := httptest.NewRequest(http.MethodPost, "/base/../path?query", strings.NewReader("Hello"))
 	r.Header.Set("Content-Type", "text/plain")
@@ -58,13 +104,13 @@ func TestHandler(t *testing.T) {
 		},
 		Body:     "Answer",
 		Request:  nil,
-		Response: &storage.Response{Status: 201},
+		Response: &storage.Response{ID: "res-1", Status: 201},
 	}
 	if err := queues.Responses.PushLast(res); err != nil {
 		t.Fatalf("PushLast: %v", err)
 	}
 
-	handler := NewHandler(queues)
+	handler := NewHandler(queues, Config{})
 	handler.ServeHTTP(w, r)
 
 	got := w.Result()
@@ -87,12 +133,542 @@ func TestHandler(t *testing.T) {
 		},
 		Body: "Hello",
 		Request: &storage.Request{
-			Method: "POST",
-			Url:    "/base/../path?query",
+			Method:        "POST",
+			Url:           "/base/../path?query",
+			Host:          "example.com",
+			RawHost:       "example.com",
+			RemoteAddr:    "192.0.2.1:1234",
+			Proto:         "HTTP/1.1",
+			ContentLength: 5,
+			Served: &storage.Served{
+				ResponseID: "res-1",
+				Status:     201,
+				Headers:    http.Header{"Content-Type": {"text/plain"}},
+				Body:       []byte("Answer"),
+			},
 		},
 	}
 
+	clearVolatile(t, msg)
 	if !reflect.DeepEqual(msg, want) {
 		t.Errorf("mismatch request:\n got: %#v\nwant:%#v", msg, want)
 	}
 }
+
+func TestHandlerVirtualHosts(t *testing.T) {
+	queues := storage.NewQueues()
+	for _, host := range []string{"api.payments.local", "api.users.local"} {
+		res := storage.Message{
+			Body:     host,
+			Response: &storage.Response{Status: 200},
+		}
+		if err := queues.Host(host).Responses.PushLast(res); err != nil {
+			t.Fatalf("PushLast: %v", err)
+		}
+	}
+
+	for _, host := range []string{"api.users.local", "API.payments.local:8010"} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Host = host
+		w := httptest.NewRecorder()
+
+		handler := NewHandler(queues, Config{UnknownHost: UnknownHostReject})
+		handler.ServeHTTP(w, r)
+
+		got := w.Result()
+		gotBody, _ := io.ReadAll(got.Body)
+		if want := storage.NormalizeHost(host); string(gotBody) != want {
+			t.Errorf("unexpected body: %v, want %v", string(gotBody), want)
+		}
+
+		hostQueues, _ := queues.LookupHost(host)
+		msg := hostQueues.Requests.PopFirst()
+		if msg == nil || msg.Request.Host != storage.NormalizeHost(host) {
+			t.Errorf("unexpected request: %#v", msg)
+		}
+	}
+
+	if list := queues.Requests.List(); len(list) != 0 {
+		t.Errorf("%#v must be empty", list)
+	}
+}
+
+func TestHandlerUnknownHost(t *testing.T) {
+	for _, tt := range [...]struct {
+		unknownHost string
+		wantStatus  int
+	}{
+		{unknownHost: "", wantStatus: 201},
+		{unknownHost: UnknownHostDefault, wantStatus: 201},
+		{unknownHost: UnknownHostReject, wantStatus: 421},
+	} {
+		t.Run(tt.unknownHost, func(t *testing.T) {
+			queues := storage.NewQueues()
+			queues.Host("api.users.local")
+			if err := queues.Responses.PushLast(storage.Message{Response: &storage.Response{Status: 201}}); err != nil {
+				t.Fatalf("PushLast: %v", err)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Host = "unknown.local"
+			w := httptest.NewRecorder()
+
+			handler := NewHandler(queues, Config{UnknownHost: tt.unknownHost})
+			handler.ServeHTTP(w, r)
+
+			got := w.Result()
+			if got.StatusCode != tt.wantStatus {
+				t.Errorf("unexpected status code: %v", got.StatusCode)
+			}
+
+			msg := queues.Requests.PopFirst()
+			if msg == nil || msg.Request.Host != "unknown.local" {
+				t.Errorf("unexpected request: %#v", msg)
+			}
+		})
+	}
+}
+
+func TestHandlerSessions(t *testing.T) {
+	queues := storage.NewQueues()
+	id := queues.CreateSession()
+	session, _ := queues.LookupSession(id)
+	for i := 0; i < 2; i++ {
+		if err := session.Responses.PushLast(storage.Message{Body: id, Response: &storage.Response{Status: 200}}); err != nil {
+			t.Fatalf("PushLast: %v", err)
+		}
+	}
+
+	handler := NewHandler(queues, Config{SessionHeader: "X-Mock-Session"})
+
+	byHeader := httptest.NewRequest(http.MethodGet, "/path?query", nil)
+	byHeader.Header.Set("X-Mock-Session", id)
+	byPath := httptest.NewRequest(http.MethodGet, "/_s/"+id+"/path?query", nil)
+
+	for _, r := range []*http.Request{byHeader, byPath} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		gotBody, _ := io.ReadAll(w.Result().Body)
+		if string(gotBody) != id {
+			t.Errorf("unexpected body: %v", string(gotBody))
+		}
+
+		msg := session.Requests.PopFirst()
+		if msg == nil || msg.Request.Url != "/path?query" {
+			t.Errorf("unexpected request: %#v", msg)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/_s/unknown/path", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if got := w.Result(); got.StatusCode != 421 {
+		t.Errorf("unexpected status code: %v", got.StatusCode)
+	}
+	if msg := queues.Requests.PopFirst(); msg == nil || msg.Request.Url != "/path" {
+		t.Errorf("unexpected request: %#v", msg)
+	}
+}
+
+func TestHandlerRequestsQueueFull(t *testing.T) {
+	queues := storage.NewQueues()
+	if err := queues.SetLimit(storage.QueueRequests, storage.Limit{Capacity: 1, Overflow: storage.OverflowReject}); err != nil {
+		t.Fatalf("SetLimit: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := queues.Responses.PushLast(storage.Message{Response: &storage.Response{Status: 200}}); err != nil {
+			t.Fatalf("PushLast: %v", err)
+		}
+	}
+
+	handler := NewHandler(queues, Config{})
+	for _, wantStatus := range []int{200, 507} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if got := w.Result(); got.StatusCode != wantStatus {
+			t.Errorf("unexpected status code: %v, want %v", got.StatusCode, wantStatus)
+		}
+	}
+
+	if list := queues.Responses.List(); len(list) != 1 {
+		t.Errorf("rejected request must not consume response: %#v", list)
+	}
+}
+
+func TestHandlerResponseMatch(t *testing.T) {
+	queues := storage.NewQueues()
+	for _, res := range [...]storage.Message{
+		{Body: "users", Response: &storage.Response{Status: 200, Match: &storage.Matcher{Method: "GET", Path: "/v1/users"}}},
+		{Body: "orders", Response: &storage.Response{Status: 200, Match: &storage.Matcher{Path: "/v1/orders"}}},
+	} {
+		if err := queues.Responses.PushLast(res); err != nil {
+			t.Fatalf("PushLast: %v", err)
+		}
+	}
+	handler := NewHandler(queues, Config{})
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/user", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if got := w.Result(); got.StatusCode != 501 {
+		t.Errorf("unexpected status code: %v", got.StatusCode)
+	}
+	var got struct {
+		Candidates []storage.Candidate `json:"candidates"`
+	}
+	if err := json.NewDecoder(w.Result().Body).Decode(&got); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	want := []storage.Candidate{
+		{Index: 0, Status: 200, Match: storage.Matcher{Method: "GET", Path: "/v1/users"}, Mismatches: []storage.Mismatch{{Field: "path", Expected: "/v1/users", Actual: "/v1/user"}}},
+		{Index: 1, Status: 200, Match: storage.Matcher{Path: "/v1/orders"}, Mismatches: []storage.Mismatch{{Field: "path", Expected: "/v1/orders", Actual: "/v1/user"}}},
+	}
+	if !reflect.DeepEqual(got.Candidates, want) {
+		t.Errorf("mismatch candidates:\n got: %#v\nwant: %#v", got.Candidates, want)
+	}
+	if msg := queues.Requests.PopFirst(); msg == nil || msg.Request.Unmatched == nil || !reflect.DeepEqual(msg.Request.Unmatched.Candidates, want) {
+		t.Errorf("captured request must keep explanation: %#v", msg)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/v1/orders", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if got, _ := io.ReadAll(w.Result().Body); string(got) != "orders" {
+		t.Errorf("unexpected body: %v", string(got))
+	}
+	if msg := queues.Requests.PopFirst(); msg == nil || msg.Request.Unmatched != nil {
+		t.Errorf("served request must not be unmatched: %#v", msg)
+	}
+	if list := queues.Responses.List(); len(list) != 1 || list[0].Body != "users" {
+		t.Errorf("unexpected responses left: %#v", list)
+	}
+}
+
+func TestHandlerDefaultResponse(t *testing.T) {
+	queues := storage.NewQueues()
+	if err := queues.SetDefault(&storage.Message{Body: "root", Response: &storage.Response{Status: 200}}); err != nil {
+		t.Fatalf("SetDefault: %v", err)
+	}
+	if err := queues.Host("api.local").SetDefault(&storage.Message{Body: "host", Response: &storage.Response{Status: 503}}); err != nil {
+		t.Fatalf("SetDefault: %v", err)
+	}
+	if err := queues.Host("other.local").Responses.PushLast(storage.Message{Body: "pending", Response: &storage.Response{Status: 201}}); err != nil {
+		t.Fatalf("PushLast: %v", err)
+	}
+	handler := NewHandler(queues, Config{Strict: true})
+
+	for _, tt := range [...]struct {
+		host       string
+		wantStatus int
+		wantBody   string
+	}{
+		{host: "api.local", wantStatus: 503, wantBody: "host"},
+		{host: "other.local", wantStatus: 201, wantBody: "pending"},
+		{host: "other.local", wantStatus: 200, wantBody: "root"},
+		{host: "unknown.local", wantStatus: 200, wantBody: "root"},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/health", nil)
+		r.Host = tt.host
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		got := w.Result()
+		gotBody, _ := io.ReadAll(got.Body)
+		if got.StatusCode != tt.wantStatus || string(gotBody) != tt.wantBody {
+			t.Errorf("%s: unexpected response %v %q, want %v %q", tt.host, got.StatusCode, gotBody, tt.wantStatus, tt.wantBody)
+		}
+	}
+
+	for host, want := range map[string]int{"api.local": 1, "other.local": 1, "": 1} {
+		queues, _ := queues.LookupHost(host)
+		if got := queues.Failures(); len(got) != want {
+			t.Errorf("%q: unexpected failures %#v", host, got)
+		}
+	}
+}
+
+func TestHandlerRequestIDHeader(t *testing.T) {
+	queues := storage.NewQueues()
+	handler := NewHandler(queues, Config{RequestIDHeader: "X-Mock-Request-Id"})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	msg := queues.Requests.PopFirst()
+	if msg == nil {
+		t.Fatalf("request must be captured")
+	}
+	if got := w.Result().Header.Get("X-Mock-Request-Id"); got == "" || got != msg.Request.ID {
+		t.Errorf("unexpected request ID header %q, want %q", got, msg.Request.ID)
+	}
+}
+
+func TestHandlerCallbacks(t *testing.T) {
+	received := make(chan string, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r.Method + " " + r.URL.Path + " " + r.Header.Get("X-Signature") + " " + string(body)
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer receiver.Close()
+
+	queues := storage.NewQueues()
+	handler := NewHandler(queues, Config{})
+	if err := queues.Responses.PushLast(storage.Message{Body: "created", Response: &storage.Response{ID: "charge", Status: 201, Callbacks: []storage.Callback{
+		{URL: receiver.URL + "/hook", Headers: http.Header{"X-Signature": {"abc"}}, Body: `{"url": {{json .Request.Url}}, "body": {{json .Response.Body}}}`},
+		{Method: http.MethodPut, URL: receiver.URL + "/fail", Retries: 1},
+	}}}); err != nil {
+		t.Fatalf("PushLast: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/charges", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if got := w.Result().StatusCode; got != 201 {
+		t.Fatalf("unexpected status %d", got)
+	}
+	requestID := queues.Requests.List()[0].Request.ID
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(queues.Deliveries()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("callbacks are not delivered: %#v", queues.Deliveries())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(received)
+
+	var calls []string
+	for call := range received {
+		calls = append(calls, call)
+	}
+	wantCalls := map[string]int{
+		`POST /hook abc {"url": "/charges", "body": "created"}`: 1,
+		`PUT /fail  `: 2,
+	}
+	gotCalls := make(map[string]int)
+	for _, call := range calls {
+		gotCalls[call]++
+	}
+	if !reflect.DeepEqual(gotCalls, wantCalls) {
+		t.Errorf("unexpected callbacks %q", calls)
+	}
+
+	deliveries := make(map[string]storage.Delivery)
+	for _, delivery := range queues.Deliveries() {
+		if delivery.ID == "" || delivery.SentAt.IsZero() {
+			t.Errorf("delivery must have id and sent time: %#v", delivery)
+		}
+		if delivery.ResponseID != "charge" || delivery.RequestID != requestID {
+			t.Errorf("delivery must refer to response and request: %#v", delivery)
+		}
+		deliveries[delivery.Method] = delivery
+	}
+	if got := deliveries[http.MethodPost]; got.Status != 200 || got.Attempts != 1 || got.Error != "" {
+		t.Errorf("unexpected delivery %#v", got)
+	}
+	if got := deliveries[http.MethodPut]; got.Status != 503 || got.Attempts != 2 || got.Latency < callbackRetryDelay {
+		t.Errorf("unexpected delivery %#v", got)
+	}
+}
+
+func TestHandlerRateLimit(t *testing.T) {
+	queues := storage.NewQueues()
+	handler := NewHandler(queues, Config{})
+	if err := queues.SetRateLimit(storage.RateLimit{Name: "api", Limit: 1, Period: time.Minute, KeyHeader: "X-Api-Key", Body: "slow down"}); err != nil {
+		t.Fatalf("SetRateLimit: %v", err)
+	}
+	for _, headers := range []http.Header{{}, {"X-Ratelimit-Remaining": {"42"}}} {
+		if err := queues.Responses.PushLast(storage.Message{Headers: headers, Body: "OK", Response: &storage.Response{Status: 200}}); err != nil {
+			t.Fatalf("PushLast: %v", err)
+		}
+	}
+
+	for _, tt := range [...]struct {
+		key           string
+		wantStatus    int
+		wantBody      string
+		wantRemaining string
+		wantRetry     string
+	}{
+		{key: "a", wantStatus: 200, wantBody: "OK", wantRemaining: "0"},
+		{key: "a", wantStatus: 429, wantBody: "slow down", wantRemaining: "0", wantRetry: "60"},
+		{key: "b", wantStatus: 200, wantBody: "OK", wantRemaining: "42"},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Api-Key", tt.key)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		res := w.Result()
+		body, _ := io.ReadAll(res.Body)
+		if res.StatusCode != tt.wantStatus || string(body) != tt.wantBody {
+			t.Errorf("unexpected response %d %q, want %d %q", res.StatusCode, body, tt.wantStatus, tt.wantBody)
+		}
+		if got := res.Header.Get("X-RateLimit-Limit"); got != "1" {
+			t.Errorf("unexpected limit header %q", got)
+		}
+		if got := res.Header.Values("X-RateLimit-Remaining"); len(got) != 1 || got[0] != tt.wantRemaining {
+			t.Errorf("unexpected remaining header %q, want %q", got, tt.wantRemaining)
+		}
+		if got := res.Header.Get("Retry-After"); got != tt.wantRetry {
+			t.Errorf("unexpected retry header %q, want %q", got, tt.wantRetry)
+		}
+	}
+
+	if got := len(queues.Responses.List()); got != 0 {
+		t.Errorf("throttled request must not take pending response, %d left", got)
+	}
+	requests := queues.Requests.List()
+	if len(requests) != 3 || requests[1].Request.Served == nil || requests[1].Request.Served.Status != 429 {
+		t.Errorf("throttled request must be captured with served status: %#v", requests)
+	}
+}
+
+func TestHandlerRateLimitScopes(t *testing.T) {
+	queues := storage.NewQueues()
+	handler := NewHandler(queues, Config{})
+	if err := queues.SetRateLimit(storage.RateLimit{Name: "root", Limit: 1, Period: time.Minute}); err != nil {
+		t.Fatalf("SetRateLimit: %v", err)
+	}
+	if err := queues.SetDefault(&storage.Message{Body: "OK", Response: &storage.Response{Status: 200}}); err != nil {
+		t.Fatalf("SetDefault: %v", err)
+	}
+	if err := queues.Host("limited.local").SetRateLimit(storage.RateLimit{Name: "host", Limit: 2, Period: time.Minute}); err != nil {
+		t.Fatalf("SetRateLimit: %v", err)
+	}
+	queues.Host("api.local")
+
+	for _, tt := range [...]struct {
+		host       string
+		wantStatus int
+		wantLimit  string
+	}{
+		// Host without rate limits is limited by root ones.
+		{host: "api.local", wantStatus: 200, wantLimit: "1"},
+		{host: "api.local", wantStatus: 429, wantLimit: "1"},
+		// Rate limits of the host take precedence over root ones.
+		{host: "limited.local", wantStatus: 200, wantLimit: "2"},
+		{host: "limited.local", wantStatus: 200, wantLimit: "2"},
+		{host: "limited.local", wantStatus: 429, wantLimit: "2"},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Host = tt.host
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		res := w.Result()
+		if res.StatusCode != tt.wantStatus {
+			t.Errorf("%s: unexpected status %d, want %d", tt.host, res.StatusCode, tt.wantStatus)
+		}
+		if got := res.Header.Get("X-RateLimit-Limit"); got != tt.wantLimit {
+			t.Errorf("%s: unexpected limit header %q, want %q", tt.host, got, tt.wantLimit)
+		}
+	}
+}
+
+type contractFunc func(request storage.Message) []storage.Violation
+
+func (f contractFunc) Validate(request storage.Message) []storage.Violation {
+	return f(request)
+}
+
+func (f contractFunc) Document() string {
+	return ""
+}
+
+func TestHandlerContract(t *testing.T) {
+	requirePOST := contractFunc(func(request storage.Message) []storage.Violation {
+		if request.Request.Method != http.MethodPost {
+			return []storage.Violation{{Field: "method", Message: "must be POST"}}
+		}
+		return nil
+	})
+
+	for _, tt := range [...]struct {
+		name           string
+		config         Config
+		method         string
+		wantStatus     int
+		wantViolations []storage.Violation
+		wantFailures   int
+	}{
+		{name: "conforms", method: http.MethodPost, wantStatus: 200},
+		{name: "conforms strict", config: Config{ContractStrict: true}, method: http.MethodPost, wantStatus: 200},
+		{name: "violates", method: http.MethodGet, wantStatus: 200, wantViolations: []storage.Violation{{Field: "method", Message: "must be POST"}}},
+		{name: "violates matched strict", config: Config{Strict: true}, method: http.MethodGet, wantStatus: 200, wantViolations: []storage.Violation{{Field: "method", Message: "must be POST"}}},
+		{name: "violates strict", config: Config{ContractStrict: true}, method: http.MethodGet, wantStatus: 400, wantViolations: []storage.Violation{{Field: "method", Message: "must be POST"}}, wantFailures: 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			queues := storage.NewQueues()
+			queues.SetContract(requirePOST)
+			if err := queues.Host("api.local").Responses.PushLast(storage.Message{Body: "OK", Response: &storage.Response{Status: 200}}); err != nil {
+				t.Fatalf("PushLast: %v", err)
+			}
+			handler := NewHandler(queues, tt.config)
+
+			r := httptest.NewRequest(tt.method, "/pets", nil)
+			r.Host = "api.local"
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			got := w.Result()
+			if got.StatusCode != tt.wantStatus {
+				t.Errorf("unexpected status code: %v", got.StatusCode)
+			}
+			host, _ := queues.LookupHost("api.local")
+			requests := host.Requests.List()
+			if len(requests) != 1 {
+				t.Fatalf("unexpected requests %#v", requests)
+			}
+			if !reflect.DeepEqual(requests[0].Request.Violations, tt.wantViolations) {
+				t.Errorf("unexpected violations %#v", requests[0].Request.Violations)
+			}
+			if got := host.Failures(); len(got) != tt.wantFailures {
+				t.Errorf("unexpected failures %#v", got)
+			}
+			if tt.wantStatus != 400 {
+				return
+			}
+
+			var body struct {
+				Error      string              `json:"error"`
+				Violations []storage.Violation `json:"violations"`
+			}
+			if err := json.NewDecoder(got.Body).Decode(&body); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if body.Error != "Bad Request" || !reflect.DeepEqual(body.Violations, tt.wantViolations) {
+				t.Errorf("unexpected body %#v", body)
+			}
+			if served := requests[0].Request.Served; served == nil || served.Status != 400 {
+				t.Errorf("unexpected served %#v", served)
+			}
+			if len(host.Responses.List()) != 1 {
+				t.Errorf("pending response must be kept")
+			}
+		})
+	}
+}
+
+func TestTLSVersionName(t *testing.T) {
+	for version, want := range map[uint16]string{
+		tls.VersionTLS10: "TLS 1.0",
+		tls.VersionTLS12: "TLS 1.2",
+		tls.VersionTLS13: "TLS 1.3",
+		0x0300:           "0x0300",
+	} {
+		if got := tlsVersionName(version); got != want {
+			t.Errorf("tlsVersionName(%#x) = %q, want %q", version, got, want)
+		}
+	}
+}
@@ -0,0 +1,615 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/spuf/mockable-server/storage"
+)
+
+// Contract validates requests against OpenAPI 3 document.
+type Contract struct {
+	document string
+	g        *generator
+	basePath string
+	routes   []route
+	// patterns of string schemas compiled by their source.
+	patterns map[string]*regexp.Regexp
+}
+
+type route struct {
+	template string
+	segments []string
+	item     map[string]interface{}
+}
+
+var _ storage.Contract = (*Contract)(nil)
+
+// ParseContract builds contract from OpenAPI 3 document, it is storage.ContractParser of imported snapshots.
+func ParseContract(document string) (storage.Contract, error) {
+	contract, err := NewContract([]byte(document))
+	if err != nil {
+		return nil, err
+	}
+
+	return contract, nil
+}
+
+// NewContract parses OpenAPI 3 document in JSON or YAML.
+func NewContract(document []byte) (*Contract, error) {
+	g, err := parseDocument(document)
+	if err != nil {
+		return nil, err
+	}
+	basePath, err := g.basePath()
+	if err != nil {
+		return nil, err
+	}
+
+	patterns := make(map[string]*regexp.Regexp)
+	if err := compilePatterns(g.root, patterns); err != nil {
+		return nil, err
+	}
+
+	c := &Contract{document: string(document), g: g, basePath: basePath, patterns: patterns}
+	paths, _ := g.root["paths"].(map[string]interface{})
+	for _, template := range sortedPaths(paths) {
+		item, _ := g.resolve(paths[template]).(map[string]interface{})
+		c.routes = append(c.routes, route{template: template, segments: strings.Split(template, "/"), item: item})
+	}
+
+	return c, nil
+}
+
+// compilePatterns compiles pattern of every schema in the value, skipping example values which are not schemas.
+func compilePatterns(value interface{}, patterns map[string]*regexp.Regexp) error {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		if pattern, ok := value["pattern"].(string); ok {
+			if _, ok := patterns[pattern]; !ok {
+				re, err := regexp.Compile(pattern)
+				if err != nil {
+					return fmt.Errorf("pattern %s is invalid: %w", pattern, err)
+				}
+				patterns[pattern] = re
+			}
+		}
+		for name, item := range value {
+			switch name {
+			case "example", "examples", "default", "enum", "const":
+				continue
+			case "properties":
+				// Names of properties may be keywords, so only their schemas are walked.
+				properties, _ := item.(map[string]interface{})
+				for _, property := range properties {
+					if err := compilePatterns(property, patterns); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if err := compilePatterns(item, patterns); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range value {
+			if err := compilePatterns(item, patterns); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Document returns the document the contract is parsed from.
+func (c *Contract) Document() string {
+	return c.document
+}
+
+// Validate checks path, method, parameters, and JSON body of the request against its operation.
+func (c *Contract) Validate(request storage.Message) []storage.Violation {
+	requestPath := request.Request.Path()
+	rest, ok := strings.CutPrefix(requestPath, c.basePath)
+	if !ok {
+		return []storage.Violation{{Field: "path", Message: fmt.Sprintf("path %s is not under server base path %s", requestPath, c.basePath)}}
+	}
+	route, pathParams := c.match(rest)
+	if route == nil {
+		return []storage.Violation{{Field: "path", Message: fmt.Sprintf("path %s is not declared", requestPath)}}
+	}
+	method := strings.ToLower(request.Request.Method)
+	operation, ok := route.item[method].(map[string]interface{})
+	if !ok {
+		return []storage.Violation{{Field: "method", Message: fmt.Sprintf("method %s is not declared for path %s", request.Request.Method, route.template)}}
+	}
+
+	v := &validator{g: c.g, patterns: c.patterns}
+	var query url.Values
+	if u, err := url.Parse(request.Request.Url); err == nil {
+		query = u.Query()
+	}
+	cookies := (&http.Request{Header: request.Headers}).Cookies()
+
+	for _, parameter := range c.parameters(route.item, operation) {
+		name, _ := parameter["name"].(string)
+		in, _ := parameter["in"].(string)
+		required, _ := parameter["required"].(bool)
+
+		var values []string
+		field := in + "." + name
+		switch in {
+		case "path":
+			values = []string{pathParams[name]}
+		case "query":
+			values = query[name]
+		case "header":
+			values = request.Headers.Values(name)
+		case "cookie":
+			for _, cookie := range cookies {
+				if cookie.Name == name {
+					values = append(values, cookie.Value)
+				}
+			}
+		default:
+			continue
+		}
+		if len(values) == 0 {
+			if required || in == "path" {
+				v.violate(field, "is required")
+			}
+			continue
+		}
+		v.parameter(field, values, parameter["schema"])
+	}
+
+	if body, ok := c.g.resolve(operation["requestBody"]).(map[string]interface{}); ok {
+		v.body(request, body)
+	}
+
+	return v.violations
+}
+
+// match returns route of the path and values of its path parameters.
+func (c *Contract) match(requestPath string) (*route, map[string]string) {
+	segments := strings.Split(requestPath, "/")
+	for i := range c.routes {
+		r := &c.routes[i]
+		if len(r.segments) != len(segments) {
+			continue
+		}
+
+		params := make(map[string]string)
+		matched := true
+		for j, segment := range r.segments {
+			switch {
+			case strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") && strings.Count(segment, "{") == 1:
+				if segments[j] == "" {
+					matched = false
+				} else {
+					params[segment[1:len(segment)-1]], _ = url.PathUnescape(segments[j])
+				}
+			case strings.Contains(segment, "{"):
+				matched, _ = path.Match(pathGlob(segment), segments[j])
+			default:
+				matched = segment == segments[j]
+			}
+			if !matched {
+				break
+			}
+		}
+		if matched {
+			return r, params
+		}
+	}
+
+	return nil, nil
+}
+
+// parameters returns parameters of the operation, they override path item ones with the same name and location.
+func (c *Contract) parameters(item, operation map[string]interface{}) []map[string]interface{} {
+	var parameters []map[string]interface{}
+	index := make(map[string]int)
+	for _, list := range [...]interface{}{item["parameters"], operation["parameters"]} {
+		list, _ := list.([]interface{})
+		for _, parameter := range list {
+			parameter, ok := c.g.resolve(parameter).(map[string]interface{})
+			if !ok {
+				continue
+			}
+			key := fmt.Sprint(parameter["in"], ".", parameter["name"])
+			if i, ok := index[key]; ok {
+				parameters[i] = parameter
+				continue
+			}
+			index[key] = len(parameters)
+			parameters = append(parameters, parameter)
+		}
+	}
+
+	return parameters
+}
+
+type validator struct {
+	g          *generator
+	patterns   map[string]*regexp.Regexp
+	violations []storage.Violation
+}
+
+func (v *validator) violate(field, format string, args ...interface{}) {
+	v.violations = append(v.violations, storage.Violation{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// parameter checks string values of parameter converted to types of its schema.
+func (v *validator) parameter(field string, values []string, schema interface{}) {
+	s, _ := v.g.resolve(schema).(map[string]interface{})
+	if schemaType(s) != "array" {
+		v.schema(field, coerce(values[0], s), schema, 0)
+		return
+	}
+
+	if len(values) == 1 {
+		values = strings.Split(values[0], ",")
+	}
+	items, _ := v.g.resolve(s["items"]).(map[string]interface{})
+	list := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		list = append(list, coerce(value, items))
+	}
+	v.schema(field, list, schema, 0)
+}
+
+// coerce converts parameter value to type of the schema, it is kept as is if it can not be converted.
+func coerce(value string, s map[string]interface{}) interface{} {
+	switch schemaType(s) {
+	case "integer", "number":
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+
+	return value
+}
+
+// body checks presence, media type, and JSON content of request body.
+func (v *validator) body(request storage.Message, requestBody map[string]interface{}) {
+	required, _ := requestBody["required"].(bool)
+	if request.Body == "" {
+		if required {
+			v.violate("body", "is required")
+		}
+		return
+	}
+
+	content, _ := requestBody["content"].(map[string]interface{})
+	if len(content) == 0 {
+		return
+	}
+	contentType := request.Headers.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		v.violate("header.Content-Type", "media type %q is invalid", contentType)
+		return
+	}
+
+	var media interface{}
+	declared := make([]string, 0, len(content))
+	for name, m := range content {
+		declared = append(declared, name)
+		if name == mediaType {
+			media = m
+		}
+	}
+	sort.Strings(declared)
+	if media == nil {
+		for _, name := range declared {
+			if ok, _ := path.Match(name, mediaType); ok {
+				media = content[name]
+				break
+			}
+		}
+	}
+	if media == nil {
+		v.violate("header.Content-Type", "media type %s is not declared, must be one of %s", mediaType, strings.Join(declared, ", "))
+		return
+	}
+	if !strings.Contains(mediaType, "json") {
+		return
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(request.Body), &value); err != nil {
+		v.violate("body", "is not valid JSON: %v", err)
+		return
+	}
+	m, _ := v.g.resolve(media).(map[string]interface{})
+	v.schema("body", value, m["schema"], 0)
+}
+
+// schema checks the value against JSON schema.
+func (v *validator) schema(field string, value interface{}, schema interface{}, depth int) {
+	s, ok := v.g.resolve(schema).(map[string]interface{})
+	if !ok || depth > 4*maxDepth {
+		return
+	}
+	if nullable, _ := s["nullable"].(bool); nullable && value == nil {
+		return
+	}
+
+	for _, sub := range list(s["allOf"]) {
+		v.schema(field, value, sub, depth+1)
+	}
+	for _, keyword := range [...]string{"anyOf", "oneOf"} {
+		subs := list(s[keyword])
+		if len(subs) == 0 {
+			continue
+		}
+		matches := 0
+		for _, sub := range subs {
+			probe := &validator{g: v.g, patterns: v.patterns}
+			probe.schema(field, value, sub, depth+1)
+			if len(probe.violations) == 0 {
+				matches++
+			}
+		}
+		if keyword == "anyOf" && matches == 0 {
+			v.violate(field, "must match at least one schema of anyOf")
+		}
+		if keyword == "oneOf" && matches != 1 {
+			v.violate(field, "must match exactly one schema of oneOf, matches %d", matches)
+		}
+	}
+
+	if value == nil {
+		if !allowsNull(s) {
+			v.violate(field, "must not be null")
+		}
+		return
+	}
+	if types := schemaTypes(s); len(types) > 0 && !matchesType(value, types) {
+		v.violate(field, "must be %s, got %s", strings.Join(types, " or "), jsonType(value))
+		return
+	}
+	if enum := list(s["enum"]); len(enum) > 0 && !containsValue(enum, value) {
+		v.violate(field, "must be one of %s", jsonString(enum))
+	}
+	if constant, ok := s["const"]; ok && !containsValue([]interface{}{constant}, value) {
+		v.violate(field, "must be %s", jsonString(constant))
+	}
+
+	switch value := value.(type) {
+	case string:
+		v.string(field, value, s)
+	case float64:
+		v.number(field, value, s)
+	case []interface{}:
+		if minItems, ok := s["minItems"].(float64); ok && float64(len(value)) < minItems {
+			v.violate(field, "must have at least %v items", minItems)
+		}
+		if maxItems, ok := s["maxItems"].(float64); ok && float64(len(value)) > maxItems {
+			v.violate(field, "must have at most %v items", maxItems)
+		}
+		for i, item := range value {
+			v.schema(fmt.Sprintf("%s[%d]", field, i), item, s["items"], depth+1)
+		}
+	case map[string]interface{}:
+		v.object(field, value, s, depth)
+	}
+}
+
+func (v *validator) string(field, value string, s map[string]interface{}) {
+	length := float64(utf8.RuneCountInString(value))
+	if minLength, ok := s["minLength"].(float64); ok && length < minLength {
+		v.violate(field, "must be at least %v characters long", minLength)
+	}
+	if maxLength, ok := s["maxLength"].(float64); ok && length > maxLength {
+		v.violate(field, "must be at most %v characters long", maxLength)
+	}
+	if pattern, ok := s["pattern"].(string); ok {
+		if re, ok := v.patterns[pattern]; ok && !re.MatchString(value) {
+			v.violate(field, "must match pattern %s", pattern)
+		}
+	}
+
+	valid := true
+	switch format, _ := s["format"].(string); format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, value)
+		valid = err == nil
+	case "date":
+		_, err := time.Parse("2006-01-02", value)
+		valid = err == nil
+	case "uuid":
+		valid = uuidPattern.MatchString(value)
+	case "email":
+		valid = emailPattern.MatchString(value)
+	}
+	if !valid {
+		v.violate(field, "must be %s", s["format"])
+	}
+}
+
+var (
+	uuidPattern  = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+$`)
+)
+
+func (v *validator) number(field string, value float64, s map[string]interface{}) {
+	if minimum, ok := s["minimum"].(float64); ok {
+		if exclusive, _ := s["exclusiveMinimum"].(bool); exclusive && value <= minimum {
+			v.violate(field, "must be greater than %v", minimum)
+		} else if value < minimum {
+			v.violate(field, "must be at least %v", minimum)
+		}
+	}
+	if minimum, ok := s["exclusiveMinimum"].(float64); ok && value <= minimum {
+		v.violate(field, "must be greater than %v", minimum)
+	}
+	if maximum, ok := s["maximum"].(float64); ok {
+		if exclusive, _ := s["exclusiveMaximum"].(bool); exclusive && value >= maximum {
+			v.violate(field, "must be less than %v", maximum)
+		} else if value > maximum {
+			v.violate(field, "must be at most %v", maximum)
+		}
+	}
+	if maximum, ok := s["exclusiveMaximum"].(float64); ok && value >= maximum {
+		v.violate(field, "must be less than %v", maximum)
+	}
+	if multipleOf, ok := s["multipleOf"].(float64); ok && multipleOf > 0 {
+		if q := value / multipleOf; math.Abs(q-math.Round(q)) > 1e-9 {
+			v.violate(field, "must be multiple of %v", multipleOf)
+		}
+	}
+}
+
+// object checks required and declared properties, read-only ones are not required in requests.
+func (v *validator) object(field string, value map[string]interface{}, s map[string]interface{}, depth int) {
+	properties, _ := s["properties"].(map[string]interface{})
+	for _, name := range list(s["required"]) {
+		name := fmt.Sprint(name)
+		if _, ok := value[name]; ok {
+			continue
+		}
+		property, _ := v.g.resolve(properties[name]).(map[string]interface{})
+		if readOnly, _ := property["readOnly"].(bool); !readOnly {
+			v.violate(field+"."+name, "is required")
+		}
+	}
+
+	names := make([]string, 0, len(value))
+	for name := range value {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if property, ok := properties[name]; ok {
+			v.schema(field+"."+name, value[name], property, depth+1)
+			continue
+		}
+		switch additional := s["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				v.violate(field+"."+name, "is not declared")
+			}
+		case map[string]interface{}:
+			v.schema(field+"."+name, value[name], additional, depth+1)
+		}
+	}
+}
+
+func list(value interface{}) []interface{} {
+	values, _ := value.([]interface{})
+
+	return values
+}
+
+// schemaTypes returns types of the schema, OpenAPI 3.1 allows list of them.
+func schemaTypes(s map[string]interface{}) []string {
+	switch t := s["type"].(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		types := make([]string, 0, len(t))
+		for _, v := range t {
+			types = append(types, fmt.Sprint(v))
+		}
+		return types
+	}
+
+	return nil
+}
+
+func allowsNull(s map[string]interface{}) bool {
+	types := schemaTypes(s)
+	for _, t := range types {
+		if t == "null" {
+			return true
+		}
+	}
+
+	return len(types) == 0
+}
+
+func matchesType(value interface{}, types []string) bool {
+	for _, t := range types {
+		switch value := value.(type) {
+		case string:
+			if t == "string" {
+				return true
+			}
+		case float64:
+			if t == "number" || (t == "integer" && value == math.Trunc(value)) {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case []interface{}:
+			if t == "array" {
+				return true
+			}
+		case map[string]interface{}:
+			if t == "object" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func jsonType(value interface{}) string {
+	switch value := value.(type) {
+	case string:
+		return "string"
+	case float64:
+		if value == math.Trunc(value) {
+			return "integer"
+		}
+		return "number"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return "null"
+	}
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if jsonString(v) == jsonString(value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func jsonString(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return string(data)
+}
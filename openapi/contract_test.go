@@ -0,0 +1,270 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spuf/mockable-server/storage"
+)
+
+const contract = `openapi: 3.0.3
+servers:
+  - url: https://api.example.com/v1
+paths:
+  /pets/mine:
+    get:
+      responses:
+        '200':
+          description: Pets
+  /pets/{petId}:
+    parameters:
+      - name: petId
+        in: path
+        required: true
+        schema:
+          type: integer
+    get:
+      parameters:
+        - name: X-Api-Key
+          in: header
+          required: true
+          schema:
+            type: string
+            minLength: 8
+      responses:
+        '200':
+          description: A pet
+  /pets:
+    get:
+      parameters:
+        - name: limit
+          in: query
+          schema:
+            type: integer
+            minimum: 1
+            maximum: 100
+        - name: kind
+          in: query
+          schema:
+            type: array
+            items:
+              type: string
+              enum: [dog, cat]
+      responses:
+        '200':
+          description: Pets
+    post:
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/Pet'
+      responses:
+        '201':
+          description: Created
+components:
+  schemas:
+    Pet:
+      type: object
+      required: [id, name, kind]
+      additionalProperties: false
+      properties:
+        id:
+          type: integer
+          readOnly: true
+        name:
+          type: string
+          pattern: '^[A-Z]'
+        kind:
+          type: string
+          enum: [dog, cat]
+        born:
+          type: string
+          format: date
+        tags:
+          type: array
+          maxItems: 2
+          items:
+            type: string
+        owner:
+          nullable: true
+          oneOf:
+            - type: object
+              required: [email]
+              properties:
+                email:
+                  type: string
+                  format: email
+            - type: string
+`
+
+func TestContractValidate(t *testing.T) {
+	c, err := NewContract([]byte(contract))
+	if err != nil {
+		t.Fatalf("NewContract: %v", err)
+	}
+
+	for _, tt := range [...]struct {
+		name    string
+		method  string
+		url     string
+		headers http.Header
+		body    string
+		want    []storage.Violation
+	}{
+		{name: "conforms", method: "GET", url: "/v1/pets?limit=10&kind=dog,cat"},
+		{name: "static path", method: "GET", url: "/v1/pets/mine"},
+		{
+			name: "base path", method: "GET", url: "/pets",
+			want: []storage.Violation{{Field: "path", Message: "path /pets is not under server base path /v1"}},
+		},
+		{
+			name: "undeclared path", method: "GET", url: "/v1/owners",
+			want: []storage.Violation{{Field: "path", Message: "path /v1/owners is not declared"}},
+		},
+		{
+			name: "undeclared method", method: "PUT", url: "/v1/pets",
+			want: []storage.Violation{{Field: "method", Message: "method PUT is not declared for path /pets"}},
+		},
+		{
+			name: "query", method: "GET", url: "/v1/pets?limit=ten&kind=dog&kind=fish",
+			want: []storage.Violation{
+				{Field: "query.limit", Message: "must be integer, got string"},
+				{Field: "query.kind[1]", Message: `must be one of ["dog","cat"]`},
+			},
+		},
+		{
+			name: "query bounds", method: "GET", url: "/v1/pets?limit=0",
+			want: []storage.Violation{{Field: "query.limit", Message: "must be at least 1"}},
+		},
+		{
+			name: "path and header", method: "GET", url: "/v1/pets/rex",
+			want: []storage.Violation{
+				{Field: "path.petId", Message: "must be integer, got string"},
+				{Field: "header.X-Api-Key", Message: "is required"},
+			},
+		},
+		{
+			name: "header", method: "GET", url: "/v1/pets/1", headers: http.Header{"X-Api-Key": {"short"}},
+			want: []storage.Violation{{Field: "header.X-Api-Key", Message: "must be at least 8 characters long"}},
+		},
+		{
+			name: "body conforms", method: "POST", url: "/v1/pets", headers: http.Header{"Content-Type": {"application/json; charset=utf-8"}},
+			body: `{"name": "Rex", "kind": "dog", "born": "2020-01-02", "owner": {"email": "a@example.com"}}`,
+		},
+		{
+			name: "body required", method: "POST", url: "/v1/pets",
+			want: []storage.Violation{{Field: "body", Message: "is required"}},
+		},
+		{
+			name: "body media type", method: "POST", url: "/v1/pets", headers: http.Header{"Content-Type": {"text/plain"}}, body: "Rex",
+			want: []storage.Violation{{Field: "header.Content-Type", Message: "media type text/plain is not declared, must be one of application/json"}},
+		},
+		{
+			name: "body invalid JSON", method: "POST", url: "/v1/pets", headers: http.Header{"Content-Type": {"application/json"}}, body: "{",
+			want: []storage.Violation{{Field: "body", Message: "is not valid JSON: unexpected end of JSON input"}},
+		},
+		{
+			name: "body schema", method: "POST", url: "/v1/pets", headers: http.Header{"Content-Type": {"application/json"}},
+			body: `{"name": "rex", "born": "yesterday", "tags": ["a", 1, "c"], "owner": {}, "color": "red"}`,
+			want: []storage.Violation{
+				{Field: "body.kind", Message: "is required"},
+				{Field: "body.born", Message: "must be date"},
+				{Field: "body.color", Message: "is not declared"},
+				{Field: "body.name", Message: "must match pattern ^[A-Z]"},
+				{Field: "body.owner", Message: "must match exactly one schema of oneOf, matches 0"},
+				{Field: "body.tags", Message: "must have at most 2 items"},
+				{Field: "body.tags[1]", Message: "must be string, got integer"},
+			},
+		},
+		{
+			name: "body nullable", method: "POST", url: "/v1/pets", headers: http.Header{"Content-Type": {"application/json"}},
+			body: `{"name": null, "kind": "cat", "owner": null}`,
+			want: []storage.Violation{{Field: "body.name", Message: "must not be null"}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := tt.headers
+			if headers == nil {
+				headers = make(http.Header)
+			}
+			got := c.Validate(storage.Message{
+				Headers: headers,
+				Body:    tt.body,
+				Request: &storage.Request{Method: tt.method, Url: tt.url},
+			})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mismatch violations:\n got: %#v\nwant: %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewContractInvalid(t *testing.T) {
+	for _, tt := range [...]struct {
+		document string
+		want     string
+	}{
+		{document: `swagger: "2.0"`, want: "only 3.x documents are supported"},
+		{
+			document: `{"openapi": "3.0.3", "components": {"schemas": {"Name": {"type": "string", "pattern": "[a-"}}}}`,
+			want:     "pattern [a- is invalid: error parsing regexp",
+		},
+	} {
+		_, err := NewContract([]byte(tt.document))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("unexpected error %v, want %q", err, tt.want)
+		}
+	}
+}
+
+func TestContractPatterns(t *testing.T) {
+	c, err := NewContract([]byte(`{
+		"openapi": "3.0.3",
+		"paths": {"/tags": {"post": {
+			"requestBody": {"content": {"application/json": {"schema": {
+				"type": "object",
+				"properties": {"example": {"type": "string", "pattern": "^#"}},
+				"example": {"pattern": "[a-"}
+			}}}},
+			"responses": {"201": {"description": "Created"}}
+		}}}
+	}`))
+	if err != nil {
+		t.Fatalf("NewContract: %v", err)
+	}
+
+	got := c.Validate(storage.Message{
+		Headers: http.Header{"Content-Type": {"application/json"}},
+		Body:    `{"example": "go"}`,
+		Request: &storage.Request{Method: "POST", Url: "/tags"},
+	})
+	want := []storage.Violation{{Field: "body.example", Message: "must match pattern ^#"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mismatch violations:\n got: %#v\nwant: %#v", got, want)
+	}
+}
+
+func TestContractSnapshot(t *testing.T) {
+	queues := storage.NewQueues()
+	c, err := NewContract([]byte(contract))
+	if err != nil {
+		t.Fatalf("NewContract: %v", err)
+	}
+	queues.SetContract(c)
+
+	imported := storage.NewQueues()
+	if err := imported.Import(queues.Export(), ParseContract); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	got := imported.Contract()
+	if got == nil || got.Document() != contract {
+		t.Fatalf("contract must be imported: %#v", got)
+	}
+	if violations := got.Validate(storage.Message{Request: &storage.Request{Method: "GET", Url: "/unknown"}}); len(violations) == 0 {
+		t.Errorf("imported contract must validate requests")
+	}
+}
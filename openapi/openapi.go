@@ -0,0 +1,436 @@
+// Package openapi generates stub responses from OpenAPI 3 documents.
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spuf/mockable-server/storage"
+)
+
+// methods are operations of path item in order stubs are generated.
+var methods = [...]string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// maxDepth limits nesting of schemas synthesizing body, e.g. of recursive ones.
+const maxDepth = 8
+
+// Stubs returns stub responses for operations of OpenAPI 3 document in JSON or YAML.
+// Each operation is answered with its first successful response, which body is its example or synthesized from its schema.
+// Paths with fewer templated segments come first, so /users/me is matched before /users/{id}.
+func Stubs(document []byte) ([]storage.Message, error) {
+	g, err := parseDocument(document)
+	if err != nil {
+		return nil, err
+	}
+	basePath, err := g.basePath()
+	if err != nil {
+		return nil, err
+	}
+
+	paths, _ := g.root["paths"].(map[string]interface{})
+	var stubs []storage.Message
+	for _, name := range sortedPaths(paths) {
+		item, _ := g.resolve(paths[name]).(map[string]interface{})
+		for _, method := range methods {
+			operation, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			stub, err := g.stub(operation, strings.ToUpper(method)+" "+name)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", strings.ToUpper(method), name, err)
+			}
+			stub.Response.Match = &storage.Matcher{Method: strings.ToUpper(method), Path: pathGlob(basePath + name)}
+			stubs = append(stubs, stub)
+		}
+	}
+
+	return stubs, nil
+}
+
+type generator struct {
+	root map[string]interface{}
+}
+
+// parseDocument parses OpenAPI 3 document in JSON or YAML.
+func parseDocument(document []byte) (*generator, error) {
+	var doc interface{}
+	if trimmed := bytes.TrimSpace(document); bytes.HasPrefix(trimmed, []byte("{")) {
+		if err := json.Unmarshal(trimmed, &doc); err != nil {
+			return nil, fmt.Errorf("could not parse document as JSON: %w", err)
+		}
+	} else {
+		var err error
+		if doc, err = parseYAML(document); err != nil {
+			return nil, fmt.Errorf("could not parse document as YAML: %w", err)
+		}
+	}
+
+	root, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("document must be an object")
+	}
+	version, ok := root["openapi"]
+	if !ok {
+		return nil, fmt.Errorf("openapi version is missing, only 3.x documents are supported")
+	}
+	if !strings.HasPrefix(fmt.Sprint(version), "3") {
+		return nil, fmt.Errorf("openapi version %v is not supported, must be 3.x", version)
+	}
+
+	return &generator{root: root}, nil
+}
+
+// sortedPaths returns path templates with fewer templated segments first.
+func sortedPaths(paths map[string]interface{}) []string {
+	names := make([]string, 0, len(paths))
+	for name := range paths {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ti, tj := strings.Count(names[i], "{"), strings.Count(names[j], "{")
+		if ti != tj {
+			return ti < tj
+		}
+		return names[i] < names[j]
+	})
+
+	return names
+}
+
+// basePath returns path of the first server URL, its variables are replaced with their defaults.
+func (g *generator) basePath() (string, error) {
+	servers, _ := g.root["servers"].([]interface{})
+	if len(servers) == 0 {
+		return "", nil
+	}
+	server, _ := servers[0].(map[string]interface{})
+	serverURL, _ := server["url"].(string)
+	variables, _ := server["variables"].(map[string]interface{})
+	for name, variable := range variables {
+		variable, _ := variable.(map[string]interface{})
+		serverURL = strings.ReplaceAll(serverURL, "{"+name+"}", fmt.Sprint(variable["default"]))
+	}
+
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return "", fmt.Errorf("server url %q: %w", serverURL, err)
+	}
+
+	return strings.TrimRight(u.Path, "/"), nil
+}
+
+// resolve follows local $ref of the value, e.g. #/components/schemas/User.
+func (g *generator) resolve(value interface{}) interface{} {
+	for i := 0; i < maxDepth; i++ {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return value
+		}
+		ref, ok := object["$ref"].(string)
+		if !ok {
+			return value
+		}
+		pointer, ok := strings.CutPrefix(ref, "#/")
+		if !ok {
+			return nil
+		}
+
+		value = g.root
+		for _, token := range strings.Split(pointer, "/") {
+			token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+			if unescaped, err := url.PathUnescape(token); err == nil {
+				token = unescaped
+			}
+			object, _ := value.(map[string]interface{})
+			value = object[token]
+		}
+	}
+
+	return value
+}
+
+// stub returns response to the operation, its ID is operationId, or defaultID if there is none.
+func (g *generator) stub(operation map[string]interface{}, defaultID string) (storage.Message, error) {
+	responses, _ := operation["responses"].(map[string]interface{})
+	code, status := successCode(responses)
+	response, _ := g.resolve(responses[code]).(map[string]interface{})
+
+	headers := make(http.Header)
+	body := ""
+	if content, ok := response["content"].(map[string]interface{}); ok && len(content) > 0 {
+		mediaType := preferredMediaType(content)
+		media, _ := g.resolve(content[mediaType]).(map[string]interface{})
+		value := g.mediaExample(media)
+		if value != nil {
+			var err error
+			if body, err = encodeBody(mediaType, value); err != nil {
+				return storage.Message{}, err
+			}
+		}
+		if !strings.Contains(mediaType, "*") {
+			headers.Set("Content-Type", mediaType)
+		}
+	}
+
+	declared, _ := response["headers"].(map[string]interface{})
+	for name, header := range declared {
+		header, _ := g.resolve(header).(map[string]interface{})
+		value := header["example"]
+		if value == nil {
+			value = g.example(header["schema"], 0)
+		}
+		if value != nil && !strings.EqualFold(name, "Content-Type") {
+			headers.Set(name, fmt.Sprint(value))
+		}
+	}
+
+	id, _ := operation["operationId"].(string)
+	if id == "" {
+		id = defaultID
+	}
+
+	return storage.Message{
+		Headers:  headers,
+		Body:     body,
+		Response: &storage.Response{ID: id, Status: status, Stub: true},
+	}, nil
+}
+
+// successCode returns the lowest 2xx response code, or default one, or the lowest declared one.
+func successCode(responses map[string]interface{}) (string, int) {
+	codes := make([]string, 0, len(responses))
+	for code := range responses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	for _, code := range codes {
+		if status, err := strconv.Atoi(code); err == nil && status >= 200 && status < 300 {
+			return code, status
+		}
+	}
+	for _, code := range codes {
+		if strings.EqualFold(code, "2XX") || code == "default" {
+			return code, http.StatusOK
+		}
+	}
+	for _, code := range codes {
+		if status, err := strconv.Atoi(code); err == nil && status >= 100 && status < 600 {
+			return code, status
+		}
+	}
+
+	return "", http.StatusOK
+}
+
+// preferredMediaType returns JSON media type of the content if any, otherwise the first one.
+func preferredMediaType(content map[string]interface{}) string {
+	mediaTypes := make([]string, 0, len(content))
+	for mediaType := range content {
+		mediaTypes = append(mediaTypes, mediaType)
+	}
+	sort.Strings(mediaTypes)
+
+	if _, ok := content["application/json"]; ok {
+		return "application/json"
+	}
+	for _, mediaType := range mediaTypes {
+		if strings.Contains(mediaType, "json") {
+			return mediaType
+		}
+	}
+
+	return mediaTypes[0]
+}
+
+func (g *generator) mediaExample(media map[string]interface{}) interface{} {
+	if example, ok := media["example"]; ok {
+		return example
+	}
+	if examples, ok := media["examples"].(map[string]interface{}); ok && len(examples) > 0 {
+		names := make([]string, 0, len(examples))
+		for name := range examples {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		example, _ := g.resolve(examples[names[0]]).(map[string]interface{})
+		if value, ok := example["value"]; ok {
+			return value
+		}
+	}
+
+	return g.example(media["schema"], 0)
+}
+
+// example returns example of the schema, or value synthesized from its type.
+func (g *generator) example(schema interface{}, depth int) interface{} {
+	s, ok := g.resolve(schema).(map[string]interface{})
+	if !ok || depth > maxDepth {
+		return nil
+	}
+
+	for _, keyword := range [...]string{"example", "default", "const"} {
+		if value, ok := s[keyword]; ok {
+			return value
+		}
+	}
+	for _, keyword := range [...]string{"examples", "enum", "oneOf", "anyOf"} {
+		values, _ := s[keyword].([]interface{})
+		if len(values) == 0 {
+			continue
+		}
+		if keyword == "oneOf" || keyword == "anyOf" {
+			return g.example(values[0], depth+1)
+		}
+		return values[0]
+	}
+	if all, ok := s["allOf"].([]interface{}); ok {
+		merged := make(map[string]interface{})
+		for _, sub := range all {
+			value, ok := g.example(sub, depth+1).(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for name, v := range value {
+				merged[name] = v
+			}
+		}
+		if properties := g.properties(s, depth); properties != nil {
+			for name, v := range properties {
+				merged[name] = v
+			}
+		}
+		return merged
+	}
+
+	switch schemaType(s) {
+	case "object":
+		properties := g.properties(s, depth)
+		if properties == nil {
+			properties = make(map[string]interface{})
+		}
+		return properties
+	case "array":
+		if item := g.example(s["items"], depth+1); item != nil {
+			return []interface{}{item}
+		}
+		return []interface{}{}
+	case "string":
+		return stringExample(fmt.Sprint(s["format"]))
+	case "integer", "number":
+		if minimum, ok := s["minimum"].(float64); ok {
+			return minimum
+		}
+		return 0.0
+	case "boolean":
+		return false
+	default:
+		return nil
+	}
+}
+
+func (g *generator) properties(s map[string]interface{}, depth int) map[string]interface{} {
+	properties, ok := s["properties"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	values := make(map[string]interface{}, len(properties))
+	for name, property := range properties {
+		values[name] = g.example(property, depth+1)
+	}
+
+	return values
+}
+
+// schemaType returns type of the schema, the first non-null one of OpenAPI 3.1 type list, or one implied by its keywords.
+func schemaType(s map[string]interface{}) string {
+	switch t := s["type"].(type) {
+	case string:
+		return t
+	case []interface{}:
+		for _, v := range t {
+			if v != "null" {
+				return fmt.Sprint(v)
+			}
+		}
+		return "null"
+	}
+
+	if _, ok := s["properties"]; ok {
+		return "object"
+	}
+	if _, ok := s["items"]; ok {
+		return "array"
+	}
+
+	return ""
+}
+
+func stringExample(format string) string {
+	switch format {
+	case "date-time":
+		return "2024-01-01T00:00:00Z"
+	case "date":
+		return "2024-01-01"
+	case "time":
+		return "00:00:00Z"
+	case "uuid":
+		return "00000000-0000-0000-0000-000000000000"
+	case "email":
+		return "user@example.com"
+	case "uri", "url":
+		return "https://example.com"
+	case "hostname":
+		return "example.com"
+	case "ipv4":
+		return "192.0.2.1"
+	case "ipv6":
+		return "2001:db8::1"
+	default:
+		return "string"
+	}
+}
+
+// encodeBody encodes example as JSON, unless it is string of non-JSON media type.
+func encodeBody(mediaType string, value interface{}) (string, error) {
+	if s, ok := value.(string); ok && !strings.Contains(mediaType, "json") {
+		return s, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("could not encode example: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// pathGlob converts path template like /users/{id} to glob pattern /users/*, escaping glob characters of the rest.
+func pathGlob(template string) string {
+	var b strings.Builder
+	for len(template) > 0 {
+		if start := strings.IndexByte(template, '{'); start == 0 {
+			if end := strings.IndexByte(template, '}'); end > 0 {
+				b.WriteByte('*')
+				template = template[end+1:]
+				continue
+			}
+		}
+		c := template[0]
+		if strings.IndexByte(`*?[\`, c) >= 0 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+		template = template[1:]
+	}
+
+	return b.String()
+}
@@ -0,0 +1,185 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/spuf/mockable-server/storage"
+)
+
+const petstore = `openapi: 3.0.3
+info:
+  title: Petstore
+  version: 1.0.0
+servers:
+  - url: https://{host}/{basePath}
+    variables:
+      host:
+        default: api.example.com
+      basePath:
+        default: v1
+paths:
+  /pets/{petId}:
+    get:
+      operationId: getPet
+      responses:
+        '200':
+          description: A pet
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Pet'
+        '404':
+          description: Not found
+  /pets/mine:
+    get:
+      operationId: getMyPets
+      responses:
+        default:
+          description: Pets
+          headers:
+            X-Total:
+              schema:
+                type: integer
+                minimum: 1
+          content:
+            application/json:
+              examples:
+                two:
+                  value: [{id: 1}, {id: 2}]
+  /pets:
+    post:
+      operationId: createPet
+      responses:
+        '201':
+          $ref: '#/components/responses/Created'
+    delete:
+      responses:
+        '204':
+          description: Deleted
+components:
+  responses:
+    Created:
+      description: Created
+      content:
+        text/plain:
+          example: created
+  schemas:
+    Pet:
+      type: object
+      required: [id]
+      properties:
+        id:
+          type: integer
+          format: int64
+        name:
+          type: string
+          example: Rex
+        kind:
+          type: string
+          enum: [dog, cat]
+        born:
+          type: string
+          format: date
+        tags:
+          type: array
+          items:
+            type: string
+        parent:
+          $ref: '#/components/schemas/Pet'
+`
+
+func TestStubs(t *testing.T) {
+	stubs, err := Stubs([]byte(petstore))
+	if err != nil {
+		t.Fatalf("Stubs: %v", err)
+	}
+
+	type stub struct {
+		ID      string
+		Method  string
+		Path    string
+		Status  int
+		Headers http.Header
+		Body    string
+	}
+	var got []stub
+	for _, msg := range stubs {
+		if !msg.Response.Stub {
+			t.Errorf("response %s must be stub", msg.Response.ID)
+		}
+		got = append(got, stub{
+			ID:      msg.Response.ID,
+			Method:  msg.Response.Match.Method,
+			Path:    msg.Response.Match.Path,
+			Status:  msg.Response.Status,
+			Headers: msg.Headers,
+			Body:    msg.Body,
+		})
+	}
+
+	want := []stub{
+		{ID: "createPet", Method: "POST", Path: "/v1/pets", Status: 201, Headers: http.Header{"Content-Type": {"text/plain"}}, Body: "created"},
+		{ID: "DELETE /pets", Method: "DELETE", Path: "/v1/pets", Status: 204, Headers: http.Header{}},
+		{ID: "getMyPets", Method: "GET", Path: "/v1/pets/mine", Status: 200, Headers: http.Header{"Content-Type": {"application/json"}, "X-Total": {"1"}}, Body: `[{"id":1},{"id":2}]`},
+	}
+	if !reflect.DeepEqual(got[:3], want) {
+		t.Errorf("mismatch stubs:\n got: %#v\nwant: %#v", got[:3], want)
+	}
+
+	pet := got[3]
+	if pet.ID != "getPet" || pet.Method != "GET" || pet.Path != "/v1/pets/*" || pet.Status != 200 {
+		t.Errorf("unexpected stub %#v", pet)
+	}
+	matcher := stubs[3].Response.Match
+	if !matcher.Matches(storage.Message{Request: &storage.Request{Method: "GET", Url: "/v1/pets/42"}}) {
+		t.Errorf("path template must match concrete path")
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(pet.Body), &body); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	parent, ok := body["parent"].(map[string]interface{})
+	if !ok || parent["name"] != "Rex" {
+		t.Errorf("recursive schema must be synthesized up to limited depth: %s", pet.Body)
+	}
+	delete(body, "parent")
+	wantBody := map[string]interface{}{"id": 0.0, "name": "Rex", "kind": "dog", "born": "2024-01-01", "tags": []interface{}{"string"}}
+	if !reflect.DeepEqual(body, wantBody) {
+		t.Errorf("mismatch body:\n got: %#v\nwant: %#v", body, wantBody)
+	}
+}
+
+func TestStubsInvalid(t *testing.T) {
+	for _, tt := range [...]struct {
+		name     string
+		document string
+	}{
+		{name: "swagger", document: `{"swagger": "2.0", "paths": {}}`},
+		{name: "not object", document: `- openapi: 3.0.0`},
+		{name: "invalid json", document: `{"openapi": "3.0.0",`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Stubs([]byte(tt.document)); err == nil {
+				t.Errorf("document must be invalid")
+			}
+		})
+	}
+}
+
+func TestPathGlob(t *testing.T) {
+	for template, want := range map[string]string{
+		"/users/{id}":             "/users/*",
+		"/files/{name}.{ext}":     "/files/*.*",
+		"/v1/items/{id}/tags":     "/v1/items/*/tags",
+		"/search*":                `/search\*`,
+		"/users":                  "/users",
+		"/users/{id}/friends/{n}": "/users/*/friends/*",
+	} {
+		if got := pathGlob(template); got != want {
+			t.Errorf("pathGlob(%q) = %q, want %q", template, got, want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package openapi
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// parseYAML parses the first YAML document into values like encoding/json does: maps, slices, strings, float64, bool, and nil.
+// Anchors, merge keys, and tags are resolved by YAML decoder, timestamps are kept as written, and keys must be scalars.
+func parseYAML(data []byte) (interface{}, error) {
+	var doc yamlValue
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	return doc.value, nil
+}
+
+// yamlValue is decoded YAML node converted to its encoding/json counterpart.
+type yamlValue struct {
+	value interface{}
+}
+
+func (v *yamlValue) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.AliasNode:
+		return v.UnmarshalYAML(node.Alias)
+
+	case yaml.MappingNode:
+		var items map[string]yamlValue
+		if err := node.Decode(&items); err != nil {
+			return err
+		}
+		object := make(map[string]interface{}, len(items))
+		for key, item := range items {
+			object[key] = item.value
+		}
+		v.value = object
+
+	case yaml.SequenceNode:
+		var items []yamlValue
+		if err := node.Decode(&items); err != nil {
+			return err
+		}
+		array := make([]interface{}, len(items))
+		for i, item := range items {
+			array[i] = item.value
+		}
+		v.value = array
+
+	default:
+		if node.ShortTag() == "!!timestamp" {
+			v.value = node.Value
+			return nil
+		}
+		var scalar interface{}
+		if err := node.Decode(&scalar); err != nil {
+			return err
+		}
+		switch number := scalar.(type) {
+		case int:
+			scalar = float64(number)
+		case int64:
+			scalar = float64(number)
+		case uint64:
+			scalar = float64(number)
+		}
+		v.value = scalar
+	}
+
+	return nil
+}
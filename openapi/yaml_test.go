@@ -0,0 +1,114 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	for _, tt := range [...]struct {
+		name    string
+		yaml    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "mapping",
+			yaml: "a: 1\nb: text # comment\nc: 'it''s'\nd: \"tab\\t\\u00e9\"\ne:\nf: ~\n200: true\n",
+			want: `{"a": 1, "b": "text", "c": "it's", "d": "tab\té", "e": null, "f": null, "200": true}`,
+		},
+		{
+			name: "nested",
+			yaml: "---\npaths:\n  /users/{id}:\n    get:\n      tags:\n      - users\n      - admin\n    # comment\n  /health: {}\n",
+			want: `{"paths": {"/users/{id}": {"get": {"tags": ["users", "admin"]}}, "/health": {}}}`,
+		},
+		{
+			name: "sequence of mappings",
+			yaml: "servers:\n  - url: http://localhost/v1\n    description: local\n  -   url: https://example.com\nlist:\n- - a\n  - b\n-\n  c\n",
+			want: `{"servers": [{"url": "http://localhost/v1", "description": "local"}, {"url": "https://example.com"}], "list": [["a", "b"], "c"]}`,
+		},
+		{
+			name: "flow",
+			yaml: "required: [id, \"name\", 3.5]\nexample: {id: 1, tags: [a, b], empty: {}, url: http://x:8080/y}\nmulti: [\n  a,\n  b\n]\n",
+			want: `{"required": ["id", "name", 3.5], "example": {"id": 1, "tags": ["a", "b"], "empty": {}, "url": "http://x:8080/y"}, "multi": ["a", "b"]}`,
+		},
+		{
+			name: "block scalars",
+			yaml: "literal: |\n  line 1\n    indented\n\n  line 3\nfolded: >-\n  one\n  two\n\n  three\nkeep: |+\n  text\n\nnext: 1\n",
+			want: `{"literal": "line 1\n  indented\n\nline 3\n", "folded": "one two\nthree", "keep": "text\n\n", "next": 1}`,
+		},
+		{
+			name: "plain multi-line",
+			yaml: "description: first\n  second\nversion: 3.0.3\n",
+			want: `{"description": "first second", "version": "3.0.3"}`,
+		},
+		{
+			name: "anchors",
+			yaml: "base: &base\n  type: string\ncopy: *base\nlist: [*base]\n",
+			want: `{"base": {"type": "string"}, "copy": {"type": "string"}, "list": [{"type": "string"}]}`,
+		},
+		{
+			name: "hash in text",
+			yaml: "a: \"x # y\"\nb: x#y\nc: 'it''s # ok' # comment\n",
+			want: `{"a": "x # y", "b": "x#y", "c": "it's # ok"}`,
+		},
+		{
+			name: "merge keys",
+			yaml: "base: &base\n  type: integer\n  minimum: 1\nid:\n  <<: *base\n  minimum: 0\n",
+			want: `{"base": {"type": "integer", "minimum": 1}, "id": {"type": "integer", "minimum": 0}}`,
+		},
+		{
+			name: "tags",
+			yaml: "version: !!str 1.0\ncount: !!int \"3\"\ndate: 2024-01-02\nat: !!timestamp 2024-01-02T03:04:05Z\nsize: 0x10\n",
+			want: `{"version": "1.0", "count": 3, "date": "2024-01-02", "at": "2024-01-02T03:04:05Z", "size": 16}`,
+		},
+		{
+			name: "multiple documents",
+			yaml: "a: 1\n---\nb: 2\n",
+			want: `{"a": 1}`,
+		},
+		{
+			name:    "complex key",
+			yaml:    "? [a, b]\n: 1\n",
+			wantErr: true,
+		},
+		{
+			name:    "duplicate key",
+			yaml:    "a: 1\na: 2\n",
+			wantErr: true,
+		},
+		{
+			name:    "bad indentation",
+			yaml:    "a:\n    b: 1\n  c: 2\n",
+			wantErr: true,
+		},
+		{
+			name:    "unknown alias",
+			yaml:    "a: *missing\n",
+			wantErr: true,
+		},
+		{
+			name:    "unclosed flow",
+			yaml:    "a: [1, 2\n",
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseYAML([]byte(tt.yaml))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseYAML() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			var want interface{}
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("mismatch:\n got: %#v\nwant: %#v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"text/template"
+	"time"
+)
+
+// Callback is HTTP request sent by mock server after the response is served.
+type Callback struct {
+	// Method is POST if empty.
+	Method  string      `json:"method,omitempty"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	// Body is text/template executed with the served request and response.
+	Body string `json:"body,omitempty"`
+	// Delay is time from serving the response to sending the callback.
+	Delay time.Duration `json:"delay,omitempty"`
+	// Retries is how many times sending is repeated after error or 5xx status.
+	Retries int `json:"retries,omitempty"`
+}
+
+func (c Callback) Validate() error {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("callback url: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("callback url %q must be absolute http or https one", c.URL)
+	}
+	if c.Delay < 0 {
+		return fmt.Errorf("callback delay must not be negative")
+	}
+	if c.Retries < 0 {
+		return fmt.Errorf("callback retries must not be negative")
+	}
+	if _, err := c.Template(); err != nil {
+		return fmt.Errorf("callback body: %w", err)
+	}
+
+	return nil
+}
+
+// Template parses the body, which may use json function to encode values, e.g. {{json .Request.Body}}.
+func (c Callback) Template() (*template.Template, error) {
+	return template.New("callback").Funcs(template.FuncMap{"json": jsonString}).Parse(c.Body)
+}
+
+// Delivery is outcome of sending a callback.
+type Delivery struct {
+	ID         string `json:"id"`
+	ResponseID string `json:"responseId,omitempty"`
+	RequestID  string `json:"requestId,omitempty"`
+	Method     string `json:"method"`
+	URL        string `json:"url"`
+	// Status is of the last attempt, it is zero if no response was received.
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// Attempts is count of sent requests, retries are the ones after the first.
+	Attempts int       `json:"attempts"`
+	SentAt   time.Time `json:"sentAt"`
+	// Latency is time from sending the first request to receiving the last response.
+	Latency time.Duration `json:"latency"`
+}
+
+// Deliver records outcome of a callback, status of its last attempt or error preventing it from being sent.
+func (q *Queues) Deliver(delivery Delivery) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.deliveries = append(q.deliveries, delivery)
+	q.deliveries = q.deliveries[q.limits.excess(len(q.deliveries)):]
+}
+
+// Deliveries returns deliveries recorded by Deliver.
+func (q *Queues) Deliveries() []Delivery {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return append([]Delivery(nil), q.deliveries...)
+}
+
+func (q *Queues) ClearDeliveries() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.deliveries = nil
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCallbackValidate(t *testing.T) {
+	for _, tt := range [...]struct {
+		name     string
+		callback Callback
+		wantErr  bool
+	}{
+		{name: "valid", callback: Callback{URL: "http://localhost:8080/hook", Body: `{"id": {{json .Request.ID}}}`, Retries: 2}},
+		{name: "relative url", callback: Callback{URL: "/hook"}, wantErr: true},
+		{name: "unsupported scheme", callback: Callback{URL: "ftp://localhost/hook"}, wantErr: true},
+		{name: "negative delay", callback: Callback{URL: "http://localhost", Delay: -1}, wantErr: true},
+		{name: "negative retries", callback: Callback{URL: "http://localhost", Retries: -1}, wantErr: true},
+		{name: "invalid template", callback: Callback{URL: "http://localhost", Body: "{{.Request"}, wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.callback.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestQueuesDeliveries(t *testing.T) {
+	queues := NewQueues()
+	if err := queues.SetLimit(QueueRequests, Limit{Capacity: 2, Overflow: OverflowReject}); err != nil {
+		t.Fatalf("SetLimit: %v", err)
+	}
+
+	var want []Delivery
+	for _, id := range []string{"1", "2", "3"} {
+		delivery := Delivery{ID: id, Method: "POST", URL: "http://localhost/hook", Status: 200, Attempts: 1}
+		queues.Deliver(delivery)
+		want = append(want, delivery)
+	}
+
+	if got := queues.Deliveries(); !reflect.DeepEqual(got, want[1:]) {
+		t.Errorf("mismatch deliveries:\n got: %#v\nwant: %#v", got, want[1:])
+	}
+	queues.ClearDeliveries()
+	if got := queues.Deliveries(); len(got) != 0 {
+		t.Errorf("deliveries must be cleared: %#v", got)
+	}
+}
@@ -0,0 +1,36 @@
+package storage
+
+// Contract checks requests, e.g. against OpenAPI document.
+type Contract interface {
+	// Validate returns how the request violates the contract, it is empty if the request conforms.
+	Validate(request Message) []Violation
+	// Document returns source the contract is built from, it is kept in snapshots.
+	Document() string
+}
+
+// ContractParser builds contract from its document, e.g. of imported snapshot.
+type ContractParser func(document string) (Contract, error)
+
+// Violation explains how the request breaks the contract.
+type Violation struct {
+	// Field is part of the request like path, query.limit, header.X-Api-Key, or body.items[0].id.
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Contract returns contract requests to the queues are checked against, it is nil if not set.
+// It is kept in snapshots, but not in journal.
+func (q *Queues) Contract() Contract {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.contract
+}
+
+// SetContract sets contract requests to the queues are checked against, nil unsets it.
+func (q *Queues) SetContract(contract Contract) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.contract = contract
+}
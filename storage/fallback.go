@@ -0,0 +1,70 @@
+package storage
+
+import "fmt"
+
+// Default returns response served when no pending response matches, it is nil if not set.
+func (q *Queues) Default() *Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.fallback == nil {
+		return nil
+	}
+	msg := *q.fallback
+
+	return &msg
+}
+
+// SetDefault sets response served when no pending response matches, nil unsets it.
+func (q *Queues) SetDefault(msg *Message) error {
+	record := journalRecord{Scope: q.scope, Op: opDefault}
+	if msg != nil {
+		if err := responseValidator(*msg); err != nil {
+			return err
+		}
+		if msg.Response.Match != nil || msg.Response.Scenario != nil {
+			return fmt.Errorf("default response must not have match or scenario")
+		}
+		copied := *msg
+		msg = &copied
+		record.Messages = []Message{copied}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.mustJournal(record)
+	q.fallback = msg
+
+	return nil
+}
+
+// Fail records request as failure, e.g. unmatched one in strict mode or one violating contract.
+func (q *Queues) Fail(request Message) error {
+	if err := requestValidator(request); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.failures = append(q.failures, request)
+	q.failures = q.failures[q.limits.excess(len(q.failures)):]
+
+	return nil
+}
+
+// Failures returns requests recorded by Fail.
+func (q *Queues) Failures() []Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return append([]Message(nil), q.failures...)
+}
+
+func (q *Queues) ClearFailures() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.failures = nil
+}
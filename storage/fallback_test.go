@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQueuesDefault(t *testing.T) {
+	queues := NewQueues()
+	if got := queues.Default(); got != nil {
+		t.Errorf("default must not be set: %#v", got)
+	}
+	if err := queues.SetDefault(&Message{Request: &Request{Method: "GET"}}); err == nil {
+		t.Errorf("request must not be default")
+	}
+	if err := queues.SetDefault(&Message{Response: &Response{Status: 200, Match: &Matcher{}}}); err == nil {
+		t.Errorf("default must not have match")
+	}
+
+	res := Message{Body: "OK", Response: &Response{Status: 200}}
+	if err := queues.SetDefault(&res); err != nil {
+		t.Fatalf("SetDefault: %v", err)
+	}
+	if got := queues.Default(); !reflect.DeepEqual(got, &res) {
+		t.Errorf("mismatch default:\n got: %#v\nwant: %#v", got, &res)
+	}
+	if err := queues.SetDefault(nil); err != nil {
+		t.Fatalf("SetDefault: %v", err)
+	}
+	if got := queues.Default(); got != nil {
+		t.Errorf("default must be unset: %#v", got)
+	}
+}
+
+func TestQueuesFailures(t *testing.T) {
+	queues := NewQueues()
+	if err := queues.SetLimit(QueueRequests, Limit{Capacity: 2, Overflow: OverflowReject}); err != nil {
+		t.Fatalf("SetLimit: %v", err)
+	}
+
+	if err := queues.Fail(Message{Response: &Response{Status: 200}}); err == nil {
+		t.Errorf("response must not be failure")
+	}
+	var want []Message
+	for _, url := range []string{"/1", "/2", "/3"} {
+		msg := Message{Request: &Request{Method: "GET", Url: url}}
+		if err := queues.Fail(msg); err != nil {
+			t.Fatalf("Fail: %v", err)
+		}
+		want = append(want, msg)
+	}
+
+	if got := queues.Failures(); !reflect.DeepEqual(got, want[1:]) {
+		t.Errorf("mismatch failures:\n got: %#v\nwant: %#v", got, want[1:])
+	}
+	queues.ClearFailures()
+	if got := queues.Failures(); len(got) != 0 {
+		t.Errorf("failures must be cleared: %#v", got)
+	}
+}
@@ -0,0 +1,547 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	opCreate   = "create"
+	opRemove   = "remove"
+	opReset    = "reset"
+	opPush     = "push"
+	opPop      = "pop"
+	opClear    = "clear"
+	opReplace  = "replace"
+	opDelete   = "delete"
+	opDefault  = "default"
+	opScenario = "scenario"
+	// opRateLimits replaces all rate limits.
+	opRateLimits = "rateLimits"
+
+	journalName = "journal.jsonl"
+)
+
+type journalRecord struct {
+	Scope    []string  `json:"scope,omitempty"`
+	Queue    string    `json:"queue,omitempty"`
+	Op       string    `json:"op"`
+	Messages []Message `json:"messages,omitempty"`
+	Indices  []int     `json:"indices,omitempty"`
+	// Name and State of scenario, all scenarios are reset if Name is empty.
+	Name       string      `json:"name,omitempty"`
+	State      string      `json:"state,omitempty"`
+	RateLimits []RateLimit `json:"rateLimits,omitempty"`
+}
+
+// Journal is append-only file of queues changes.
+// When it grows twice since the last compaction, it is rewritten with the current content of queues.
+type Journal struct {
+	mu        sync.Mutex
+	name      string
+	file      *os.File
+	size      int64
+	compactAt int64
+	// minCompactSize prevents compaction of small journals.
+	minCompactSize int64
+	errorLog       *log.Logger
+}
+
+// OpenQueues restores queues from journal in dir and persists their further changes to it.
+// Compaction failures are reported to errorLog, or to the standard logger if nil.
+func OpenQueues(dir string, errorLog *log.Logger) (*Queues, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	journal := &Journal{
+		name:           filepath.Join(dir, journalName),
+		minCompactSize: 1 << 20,
+		errorLog:       errorLog,
+	}
+	queues, err := journal.replay()
+	if err != nil {
+		return nil, err
+	}
+	if err := journal.compact(queues); err != nil {
+		return nil, err
+	}
+	queues.attach(journal)
+
+	return queues, nil
+}
+
+// Close closes journal of queues opened by OpenQueues.
+func (q *Queues) Close() error {
+	if q.journal == nil {
+		return nil
+	}
+
+	return q.journal.Close()
+}
+
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file = nil
+
+	return err
+}
+
+func (j *Journal) write(record journalRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		return fmt.Errorf("journal %s is closed", j.name)
+	}
+	n, err := j.file.Write(data)
+	j.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("could not write journal %s: %w", j.name, err)
+	}
+
+	if j.size >= j.compactAt {
+		// The record is already written, so failed compaction only postpones the next one.
+		queues, err := j.replay()
+		if err == nil {
+			err = j.compact(queues)
+		}
+		if err != nil {
+			j.compactAt = j.size + j.minCompactSize
+			j.logf("could not compact journal %s: %v", j.name, err)
+		}
+	}
+
+	return nil
+}
+
+func (j *Journal) logf(format string, args ...interface{}) {
+	if j.errorLog != nil {
+		j.errorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+// replay reads journal into in-memory queues.
+func (j *Journal) replay() (*Queues, error) {
+	queues := NewQueues()
+
+	f, err := os.Open(j.name)
+	if errors.Is(err, os.ErrNotExist) {
+		return queues, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		data, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// Unterminated line is a record torn by crash, it was never applied.
+			return queues, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var record journalRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return nil, fmt.Errorf("journal %s line %d: %w", j.name, line, err)
+		}
+		if err := queues.apply(record); err != nil {
+			return nil, fmt.Errorf("journal %s line %d: %w", j.name, line, err)
+		}
+	}
+}
+
+// compact atomically replaces journal with records of queues content.
+func (j *Journal) compact(queues *Queues) error {
+	tmpName := j.name + ".tmp"
+	f, err := os.Create(tmpName)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpName)
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	err = queues.walk(func(node *Queues) error {
+		if len(node.scope) > 0 {
+			if err := enc.Encode(journalRecord{Scope: node.scope, Op: opCreate}); err != nil {
+				return err
+			}
+		}
+		for _, queue := range [...]string{QueueResponses, QueueRequests} {
+			list := node.store(queue).List()
+			if len(list) == 0 {
+				continue
+			}
+			if err := enc.Encode(journalRecord{Scope: node.scope, Queue: queue, Op: opReplace, Messages: list}); err != nil {
+				return err
+			}
+		}
+		if fallback := node.Default(); fallback != nil {
+			if err := enc.Encode(journalRecord{Scope: node.scope, Op: opDefault, Messages: []Message{*fallback}}); err != nil {
+				return err
+			}
+		}
+		scenarios := node.Scenarios()
+		names := make([]string, 0, len(scenarios))
+		for name := range scenarios {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := enc.Encode(journalRecord{Scope: node.scope, Op: opScenario, Name: name, State: scenarios[name]}); err != nil {
+				return err
+			}
+		}
+		if limits := node.RateLimits(); len(limits) > 0 {
+			if err := enc.Encode(journalRecord{Scope: node.scope, Op: opRateLimits, RateLimits: limits}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, j.name); err != nil {
+		return err
+	}
+
+	if j.file != nil {
+		if err := j.file.Close(); err != nil {
+			return err
+		}
+	}
+	j.file, err = os.OpenFile(j.name, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := j.file.Stat()
+	if err != nil {
+		return err
+	}
+	j.size = info.Size()
+	j.compactAt = 2 * j.size
+	if j.compactAt < j.minCompactSize {
+		j.compactAt = j.minCompactSize
+	}
+
+	return nil
+}
+
+// attach makes in-memory queues persist their changes to journal.
+func (q *Queues) attach(journal *Journal) {
+	_ = q.walk(func(node *Queues) error {
+		node.journal = journal
+		node.Responses = &journalStore{journal: journal, scope: node.scope, queue: QueueResponses, store: node.Responses.(*store)}
+		node.Requests = &journalStore{journal: journal, scope: node.scope, queue: QueueRequests, store: node.Requests.(*store)}
+		return nil
+	})
+}
+
+// apply replays journal record on in-memory queues.
+func (q *Queues) apply(record journalRecord) error {
+	scope := record.Scope
+	if len(scope)%2 != 0 {
+		return fmt.Errorf("scope %v must consist of kind and name pairs", scope)
+	}
+
+	switch record.Op {
+	case opCreate, opRemove:
+		if len(scope) == 0 {
+			return fmt.Errorf("root queues can not be %sd", record.Op)
+		}
+		parent, err := q.resolve(scope[:len(scope)-2])
+		if err != nil {
+			return err
+		}
+		kind, name := scope[len(scope)-2], scope[len(scope)-1]
+		children := parent.children(kind)
+		if children == nil {
+			return fmt.Errorf("unknown scope kind %s", kind)
+		}
+
+		parent.mu.Lock()
+		defer parent.mu.Unlock()
+
+		if record.Op == opRemove {
+			delete(*children, name)
+			return nil
+		}
+		if *children == nil {
+			*children = make(map[string]*Queues)
+		}
+		(*children)[name] = newQueues(nil, parent.limits, scope)
+
+	case opReset:
+		node, err := q.resolve(scope)
+		if err != nil {
+			return err
+		}
+		node.Responses.Clear()
+		node.Requests.Clear()
+
+		node.mu.Lock()
+		defer node.mu.Unlock()
+
+		node.hosts = nil
+		node.sessions = nil
+		node.fallback = nil
+		node.scenarios = nil
+		node.rateLimits = nil
+		node.buckets = nil
+
+	case opScenario:
+		node, err := q.resolve(scope)
+		if err != nil {
+			return err
+		}
+		if record.Name == "" {
+			node.ResetScenarios()
+		} else {
+			node.SetScenarioState(record.Name, record.State)
+		}
+
+	case opRateLimits:
+		node, err := q.resolve(scope)
+		if err != nil {
+			return err
+		}
+		return node.SetRateLimits(record.RateLimits)
+
+	case opDefault:
+		node, err := q.resolve(scope)
+		if err != nil {
+			return err
+		}
+		switch len(record.Messages) {
+		case 0:
+			return node.SetDefault(nil)
+		case 1:
+			return node.SetDefault(&record.Messages[0])
+		default:
+			return fmt.Errorf("default must contain at most one message")
+		}
+
+	default:
+		node, err := q.resolve(scope)
+		if err != nil {
+			return err
+		}
+		s := node.store(record.Queue)
+		if s == nil {
+			return fmt.Errorf("unknown queue %s", record.Queue)
+		}
+
+		switch record.Op {
+		case opPush:
+			if len(record.Messages) != 1 {
+				return fmt.Errorf("push must contain one message")
+			}
+			return s.PushLast(record.Messages[0])
+		case opPop:
+			s.PopFirst()
+		case opClear:
+			s.Clear()
+		case opReplace:
+			return s.Replace(record.Messages)
+		case opDelete:
+			indices := make(map[int]bool, len(record.Indices))
+			for _, i := range record.Indices {
+				indices[i] = true
+			}
+			i := -1
+			s.Remove(func(Message) bool {
+				i++
+				return indices[i]
+			})
+		default:
+			return fmt.Errorf("unknown op %s", record.Op)
+		}
+	}
+
+	return nil
+}
+
+// resolve returns nested queues by scope, creating missing ones.
+func (q *Queues) resolve(scope []string) (*Queues, error) {
+	node := q
+	for i := 0; i < len(scope); i += 2 {
+		children := node.children(scope[i])
+		if children == nil {
+			return nil, fmt.Errorf("unknown scope kind %s", scope[i])
+		}
+
+		node.mu.Lock()
+		child, ok := (*children)[scope[i+1]]
+		if !ok {
+			if *children == nil {
+				*children = make(map[string]*Queues)
+			}
+			child = newQueues(nil, node.limits, scope[:i+2])
+			(*children)[scope[i+1]] = child
+		}
+		node.mu.Unlock()
+
+		node = child
+	}
+
+	return node, nil
+}
+
+// journalStore keeps messages in memory and persists every change to journal before applying it.
+type journalStore struct {
+	mu      sync.Mutex
+	journal *Journal
+	scope   []string
+	queue   string
+	store   *store
+}
+
+func (s *journalStore) record(op string, messages ...Message) journalRecord {
+	return journalRecord{Scope: s.scope, Queue: s.queue, Op: op, Messages: messages}
+}
+
+func (s *journalStore) PushLast(message Message) error {
+	if err := s.store.validate(message); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	overflow, excess := s.store.overflow(s.store.len())
+	switch overflow {
+	case OverflowReject, OverflowDropNewest:
+		// Nothing changes, store only counts the message.
+		return s.store.PushLast(message)
+	case OverflowDropOldest:
+		for i := 0; i < excess; i++ {
+			if err := s.journal.write(s.record(opPop)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := s.journal.write(s.record(opPush, message)); err != nil {
+		return err
+	}
+
+	return s.store.PushLast(message)
+}
+
+func (s *journalStore) PopFirst() *Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.store.len() == 0 {
+		return nil
+	}
+	if err := s.journal.write(s.record(opPop)); err != nil {
+		panic(err)
+	}
+
+	return s.store.PopFirst()
+}
+
+func (s *journalStore) List() []Message {
+	return s.store.List()
+}
+
+func (s *journalStore) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.journal.write(s.record(opClear)); err != nil {
+		panic(err)
+	}
+	s.store.Clear()
+}
+
+func (s *journalStore) Remove(match func(Message) bool) []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.removeAt(s.store.find(match))
+}
+
+func (s *journalStore) Expire(now time.Time) []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := s.removeAt(s.store.find(func(msg Message) bool {
+		return msg.IsExpired(now)
+	}))
+	s.store.addExpired(len(removed))
+
+	return removed
+}
+
+func (s *journalStore) removeAt(indices []int) []Message {
+	if len(indices) == 0 {
+		return nil
+	}
+	if err := s.journal.write(journalRecord{Scope: s.scope, Queue: s.queue, Op: opDelete, Indices: indices}); err != nil {
+		panic(err)
+	}
+
+	return s.store.removeAt(indices)
+}
+
+func (s *journalStore) Stats() Stats {
+	return s.store.Stats()
+}
+
+func (s *journalStore) Replace(messages []Message) error {
+	for _, message := range messages {
+		if err := s.store.validate(message); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.journal.write(s.record(opReplace, messages...)); err != nil {
+		return err
+	}
+
+	return s.store.Replace(messages)
+}
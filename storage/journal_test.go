@@ -0,0 +1,270 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func reopenQueues(t *testing.T, queues *Queues, dir string) *Queues {
+	t.Helper()
+
+	if err := queues.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	reopened, err := OpenQueues(dir, nil)
+	if err != nil {
+		t.Fatalf("OpenQueues: %v", err)
+	}
+	t.Cleanup(func() { _ = reopened.Close() })
+
+	return reopened
+}
+
+func TestJournalQueues(t *testing.T) {
+	dir := t.TempDir()
+	queues, err := OpenQueues(dir, nil)
+	if err != nil {
+		t.Fatalf("OpenQueues: %v", err)
+	}
+
+	res := Message{Body: "\x00\xff", Response: &Response{Status: 200}}
+	req := Message{Body: "Hello", Request: &Request{Method: "GET", Url: "/"}}
+
+	if err := queues.Responses.PushLast(req); err == nil {
+		t.Errorf("PushLast must return error")
+	}
+	for i := 0; i < 3; i++ {
+		if err := queues.Responses.PushLast(res); err != nil {
+			t.Fatalf("PushLast: %v", err)
+		}
+	}
+	queues.Responses.PopFirst()
+	if err := queues.Host("api.local").Requests.PushLast(req); err != nil {
+		t.Fatalf("PushLast: %v", err)
+	}
+	if err := queues.Host("removed.local").Requests.PushLast(req); err != nil {
+		t.Fatalf("PushLast: %v", err)
+	}
+	queues.RemoveHost("removed.local")
+	empty := queues.CreateSession()
+	cleared := queues.CreateSession()
+	session, _ := queues.LookupSession(cleared)
+	if err := session.Responses.PushLast(res); err != nil {
+		t.Fatalf("PushLast: %v", err)
+	}
+	session.Responses.Clear()
+	if err := queues.Requests.PushLast(Message{Body: "0", Request: &Request{}}); err != nil {
+		t.Fatalf("PushLast: %v", err)
+	}
+	if err := queues.Requests.PushLast(Message{Body: "1", ExpiresAt: time.Now().Add(-time.Second), Request: &Request{}}); err != nil {
+		t.Fatalf("PushLast: %v", err)
+	}
+	if err := queues.Requests.PushLast(Message{Body: "2", Request: &Request{}}); err != nil {
+		t.Fatalf("PushLast: %v", err)
+	}
+	if expirations := queues.Expire(time.Now()); len(expirations) != 1 {
+		t.Errorf("unexpected expirations: %#v", expirations)
+	}
+
+	want := queues.Export()
+
+	reopened := reopenQueues(t, queues, dir)
+	if got := reopened.Export(); !reflect.DeepEqual(got, want) {
+		t.Errorf("state mismatch:\n got: %#v\nwant: %#v", got, want)
+	}
+	if ids := reopened.Sessions(); len(ids) != 2 {
+		t.Errorf("sessions %s and %s must be restored: %#v", empty, cleared, ids)
+	}
+
+	if err := reopened.Import(Snapshot{Version: SnapshotVersion, QueuesSnapshot: QueuesSnapshot{
+		Requests: []Message{req},
+		Hosts:    map[string]QueuesSnapshot{"api.local": {Responses: []Message{res}}},
+	}}, nil); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	want = reopened.Export()
+
+	reopened = reopenQueues(t, reopened, dir)
+	if got := reopened.Export(); !reflect.DeepEqual(got, want) {
+		t.Errorf("state mismatch:\n got: %#v\nwant: %#v", got, want)
+	}
+}
+
+func TestJournalState(t *testing.T) {
+	res := Message{Body: "OK", Response: &Response{Status: 200}}
+	limit := RateLimit{Name: "api", Limit: 10, Period: time.Minute, Status: 503, Body: "slow down"}
+
+	for _, tt := range [...]struct {
+		name string
+		// change is applied before restarts, check verifies state after each restart and after snapshot import.
+		change func(t *testing.T, queues *Queues)
+		check  func(t *testing.T, queues *Queues)
+	}{
+		{
+			name: "default",
+			change: func(t *testing.T, queues *Queues) {
+				for _, err := range []error{
+					queues.SetDefault(&res),
+					queues.Host("api.local").SetDefault(&res),
+					queues.Host("api.local").SetDefault(nil),
+				} {
+					if err != nil {
+						t.Fatalf("SetDefault: %v", err)
+					}
+				}
+			},
+			check: func(t *testing.T, queues *Queues) {
+				if got := queues.Default(); !reflect.DeepEqual(got, &res) {
+					t.Errorf("mismatch default:\n got: %#v\nwant: %#v", got, &res)
+				}
+				if got := queues.Host("api.local").Default(); got != nil {
+					t.Errorf("host default must be unset: %#v", got)
+				}
+			},
+		},
+		{
+			name: "scenarios",
+			change: func(t *testing.T, queues *Queues) {
+				queues.SetScenarioState("order", "paid")
+				queues.SetScenarioState("cart", "full")
+				queues.SetScenarioState("cart", ScenarioStarted)
+				queues.Host("api.local").SetScenarioState("login", "done")
+			},
+			check: func(t *testing.T, queues *Queues) {
+				if got, want := queues.Scenarios(), map[string]string{"order": "paid"}; !reflect.DeepEqual(got, want) {
+					t.Errorf("mismatch scenarios:\n got: %#v\nwant: %#v", got, want)
+				}
+				if got := queues.Host("api.local").ScenarioState("login"); got != "done" {
+					t.Errorf("unexpected host scenario state: %v", got)
+				}
+			},
+		},
+		{
+			name: "scenarios reset",
+			change: func(t *testing.T, queues *Queues) {
+				queues.SetScenarioState("order", "paid")
+				queues.ResetScenarios()
+			},
+			check: func(t *testing.T, queues *Queues) {
+				if got := queues.Scenarios(); len(got) != 0 {
+					t.Errorf("scenarios must be reset: %#v", got)
+				}
+			},
+		},
+		{
+			name: "rate limits",
+			change: func(t *testing.T, queues *Queues) {
+				if err := queues.Host("api.local").SetRateLimit(limit); err != nil {
+					t.Fatalf("SetRateLimit: %v", err)
+				}
+			},
+			check: func(t *testing.T, queues *Queues) {
+				if got := queues.Host("api.local").RateLimits(); !reflect.DeepEqual(got, []RateLimit{limit}) {
+					t.Errorf("mismatch rate limits:\n got: %#v\nwant: %#v", got, []RateLimit{limit})
+				}
+			},
+		},
+		{
+			name: "rate limits removed",
+			change: func(t *testing.T, queues *Queues) {
+				if err := queues.SetRateLimit(limit); err != nil {
+					t.Fatalf("SetRateLimit: %v", err)
+				}
+				queues.RemoveRateLimit("")
+			},
+			check: func(t *testing.T, queues *Queues) {
+				if got := queues.RateLimits(); len(got) != 0 {
+					t.Errorf("rate limits must be removed: %#v", got)
+				}
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			queues, err := OpenQueues(dir, nil)
+			if err != nil {
+				t.Fatalf("OpenQueues: %v", err)
+			}
+			tt.change(t, queues)
+
+			// The first restart compacts journal, the second one replays the compacted journal.
+			for i := 0; i < 2; i++ {
+				queues = reopenQueues(t, queues, dir)
+				tt.check(t, queues)
+			}
+
+			imported := NewQueues()
+			if err := imported.Import(queues.Export(), nil); err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+			tt.check(t, imported)
+		})
+	}
+}
+
+func TestJournalTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	queues, err := OpenQueues(dir, nil)
+	if err != nil {
+		t.Fatalf("OpenQueues: %v", err)
+	}
+	if err := queues.Requests.PushLast(Message{Request: &Request{}}); err != nil {
+		t.Fatalf("PushLast: %v", err)
+	}
+	if err := queues.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, journalName), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if _, err := f.WriteString(`{"queue":"requests","op":"pu`); err != nil {
+		t.Fatalf("WriteString: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened := reopenQueues(t, NewQueues(), dir)
+	if list := reopened.Requests.List(); len(list) != 1 {
+		t.Errorf("%#v must contain one item", list)
+	}
+}
+
+func TestJournalCompaction(t *testing.T) {
+	dir := t.TempDir()
+	queues, err := OpenQueues(dir, nil)
+	if err != nil {
+		t.Fatalf("OpenQueues: %v", err)
+	}
+	queues.journal.minCompactSize = 4096
+	queues.journal.compactAt = 4096
+
+	res := Message{Body: "Hello", Response: &Response{Status: 200}}
+	for i := 0; i < 1000; i++ {
+		if err := queues.Responses.PushLast(res); err != nil {
+			t.Fatalf("PushLast: %v", err)
+		}
+		queues.Responses.PopFirst()
+	}
+	if err := queues.Responses.PushLast(res); err != nil {
+		t.Fatalf("PushLast: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, journalName))
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Size() >= 4096 {
+		t.Errorf("journal must be compacted, size: %d", info.Size())
+	}
+
+	reopened := reopenQueues(t, queues, dir)
+	if list := reopened.Responses.List(); !reflect.DeepEqual(list, []Message{res}) {
+		t.Errorf("%#v must contain one item", list)
+	}
+}
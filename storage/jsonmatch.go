@@ -0,0 +1,222 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// JSONCondition checks value found by Path in request body parsed as JSON.
+// Path may have wildcards like $.items[*].id, then any of found values must satisfy the condition.
+// Equals is compared unless it is null and other operators are set, so {"equals": null} alone requires null.
+type JSONCondition struct {
+	Path   string      `json:"path"`
+	Equals interface{} `json:"equals"`
+	// Exists requires the value to be present if true, or absent if false.
+	Exists *bool `json:"exists,omitempty"`
+	// Matches is regular expression string value must match, other values are matched as JSON.
+	Matches string `json:"matches,omitempty"`
+	// Gt, Gte, Lt, and Lte bound numeric value.
+	Gt  *float64 `json:"gt,omitempty"`
+	Gte *float64 `json:"gte,omitempty"`
+	Lt  *float64 `json:"lt,omitempty"`
+	Lte *float64 `json:"lte,omitempty"`
+}
+
+func (c JSONCondition) Validate() error {
+	if _, err := parseJSONPath(c.Path); err != nil {
+		return err
+	}
+	if c.Matches != "" {
+		if _, err := compileRegexp(c.Matches); err != nil {
+			return fmt.Errorf("json path %q matches %q: %w", c.Path, c.Matches, err)
+		}
+	}
+	if c.Exists != nil && !*c.Exists && (c.Equals != nil || c.Matches != "" || c.isNumeric()) {
+		return fmt.Errorf("json path %q must not exist, so it can not have other operators", c.Path)
+	}
+
+	return nil
+}
+
+func (c JSONCondition) isNumeric() bool {
+	return c.Gt != nil || c.Gte != nil || c.Lt != nil || c.Lte != nil
+}
+
+// checksEquals reports whether Equals is compared, null is compared only without other operators.
+func (c JSONCondition) checksEquals() bool {
+	return c.Equals != nil || (c.Exists == nil && c.Matches == "" && !c.isNumeric())
+}
+
+// expected describes the condition, e.g. "exists and > 1".
+func (c JSONCondition) expected() string {
+	var parts []string
+	if c.checksEquals() {
+		parts = append(parts, jsonString(c.Equals))
+	}
+	if c.Exists != nil && *c.Exists {
+		parts = append(parts, "exists")
+	}
+	if c.Matches != "" {
+		parts = append(parts, "~"+c.Matches)
+	}
+	for _, bound := range [...]struct {
+		op    string
+		value *float64
+	}{{">", c.Gt}, {">=", c.Gte}, {"<", c.Lt}, {"<=", c.Lte}} {
+		if bound.value != nil {
+			parts = append(parts, fmt.Sprintf("%s %v", bound.op, *bound.value))
+		}
+	}
+
+	return strings.Join(parts, " and ")
+}
+
+// explain returns mismatch of the condition in decoded JSON document, it is nil if the condition is satisfied.
+func (c JSONCondition) explain(doc interface{}) *Mismatch {
+	field := "json." + strings.TrimPrefix(strings.TrimPrefix(c.Path, "$"), ".")
+	p, err := parseJSONPath(c.Path)
+	if err != nil {
+		return &Mismatch{Field: field, Expected: c.expected(), Actual: err.Error()}
+	}
+
+	values := p.find(doc)
+	if c.Exists != nil && !*c.Exists {
+		if len(values) > 0 {
+			return &Mismatch{Field: field, Expected: "<missing>", Actual: abbreviate(jsonString(values[0]))}
+		}
+		return nil
+	}
+	if len(values) == 0 {
+		return &Mismatch{Field: field, Expected: c.expected(), Actual: "<missing>"}
+	}
+	for _, value := range values {
+		if c.satisfiedBy(value) {
+			return nil
+		}
+	}
+
+	actual := jsonString(values[0])
+	if len(values) > 1 {
+		actual = jsonString(values)
+	}
+
+	return &Mismatch{Field: field, Expected: c.expected(), Actual: abbreviate(actual)}
+}
+
+func (c JSONCondition) satisfiedBy(value interface{}) bool {
+	if c.checksEquals() && !jsonEqual(value, c.Equals) {
+		return false
+	}
+	if c.Matches != "" {
+		s, ok := value.(string)
+		if !ok {
+			s = jsonString(value)
+		}
+		re, err := compileRegexp(c.Matches)
+		if err != nil || !re.MatchString(s) {
+			return false
+		}
+	}
+	if c.isNumeric() {
+		n, ok := value.(float64)
+		if !ok {
+			return false
+		}
+		if (c.Gt != nil && !(n > *c.Gt)) || (c.Gte != nil && !(n >= *c.Gte)) ||
+			(c.Lt != nil && !(n < *c.Lt)) || (c.Lte != nil && !(n <= *c.Lte)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (m Matcher) explainJSON(body string) []Mismatch {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(body), &doc); err != nil {
+		return []Mismatch{{Field: "body", Expected: "JSON", Actual: err.Error()}}
+	}
+
+	var mismatches []Mismatch
+	for _, condition := range m.JSON {
+		if mismatch := condition.explain(doc); mismatch != nil {
+			mismatches = append(mismatches, *mismatch)
+		}
+	}
+	if m.JSONEquals != nil {
+		mismatches = append(mismatches, m.explainJSONEquals(doc)...)
+	}
+
+	return mismatches
+}
+
+// explainJSONEquals compares decoded JSON document with JSONEquals except JSONIgnore paths, the document is modified.
+func (m Matcher) explainJSONEquals(doc interface{}) []Mismatch {
+	// Normalized copy is modified, so JSONEquals stays intact.
+	expected := normalizeJSON(m.JSONEquals)
+	for _, ignored := range m.JSONIgnore {
+		p, err := parseJSONPath(ignored)
+		if err != nil {
+			continue
+		}
+		expected = p.remove(expected)
+		doc = p.remove(doc)
+	}
+
+	return jsonDiff("json", expected, doc)
+}
+
+// jsonDiff returns differences of actual decoded JSON value from expected one, regardless of key order.
+func jsonDiff(field string, expected, actual interface{}) []Mismatch {
+	switch expected := expected.(type) {
+	case map[string]interface{}:
+		actual, ok := actual.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(expected)+len(actual))
+		for key := range expected {
+			keys = append(keys, key)
+		}
+		for key := range actual {
+			if _, ok := expected[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		var mismatches []Mismatch
+		for _, key := range keys {
+			e, inExpected := expected[key]
+			a, inActual := actual[key]
+			switch {
+			case !inActual:
+				mismatches = append(mismatches, Mismatch{Field: field + "." + key, Expected: abbreviate(jsonString(e)), Actual: "<missing>"})
+			case !inExpected:
+				mismatches = append(mismatches, Mismatch{Field: field + "." + key, Expected: "<missing>", Actual: abbreviate(jsonString(a))})
+			default:
+				mismatches = append(mismatches, jsonDiff(field+"."+key, e, a)...)
+			}
+		}
+		return mismatches
+
+	case []interface{}:
+		actual, ok := actual.([]interface{})
+		if !ok || len(actual) != len(expected) {
+			break
+		}
+		var mismatches []Mismatch
+		for i := range expected {
+			mismatches = append(mismatches, jsonDiff(fmt.Sprintf("%s[%d]", field, i), expected[i], actual[i])...)
+		}
+		return mismatches
+	}
+
+	if !jsonEqual(expected, actual) {
+		return []Mismatch{{Field: field, Expected: abbreviate(jsonString(expected)), Actual: abbreviate(jsonString(actual))}}
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestMatcherExplainJSON(t *testing.T) {
+	msg := Message{
+		Headers: http.Header{"Content-Type": {"application/json"}},
+		Body: `{
+			"id": "9f1c", "createdAt": "2024-01-02T03:04:05Z", "total": 42.5, "note": null,
+			"items": [{"sku": "A-1", "qty": 2, "addedAt": 1}, {"sku": "B-2", "qty": 5, "addedAt": 2}]
+		}`,
+		Request: &Request{Method: "POST", Url: "/orders"},
+	}
+	yes, no := true, false
+	number := func(n float64) *float64 { return &n }
+
+	for _, tt := range [...]struct {
+		name    string
+		matcher Matcher
+		want    []Mismatch
+	}{
+		{
+			name: "operators",
+			matcher: Matcher{JSON: []JSONCondition{
+				{Path: "$.id", Exists: &yes},
+				{Path: "$.coupon", Exists: &no},
+				{Path: "$.note", Equals: nil},
+				{Path: "$.createdAt", Matches: `^\d{4}-\d{2}-\d{2}T`},
+				{Path: "$.total", Gt: number(40), Lte: number(42.5)},
+				{Path: "$.items[*].sku", Equals: "B-2"},
+				{Path: "$.items[*].qty", Gte: number(5)},
+				{Path: "$.items[-1].qty", Matches: `^5$`},
+			}},
+		},
+		{
+			name: "operators mismatch",
+			matcher: Matcher{JSON: []JSONCondition{
+				{Path: "$.coupon", Exists: &yes},
+				{Path: "$.id", Exists: &no},
+				{Path: "$.createdAt", Matches: `^2023-`},
+				{Path: "$.total", Gt: number(50)},
+				{Path: "$.id", Lt: number(1)},
+				{Path: "$.items[*].qty", Equals: 3.0, Gt: number(1)},
+			}},
+			want: []Mismatch{
+				{Field: "json.coupon", Expected: "exists", Actual: "<missing>"},
+				{Field: "json.id", Expected: "<missing>", Actual: `"9f1c"`},
+				{Field: "json.createdAt", Expected: "~^2023-", Actual: `"2024-01-02T03:04:05Z"`},
+				{Field: "json.total", Expected: "> 50", Actual: "42.5"},
+				{Field: "json.id", Expected: "< 1", Actual: `"9f1c"`},
+				{Field: "json.items[*].qty", Expected: "3 and > 1", Actual: "[2,5]"},
+			},
+		},
+		{
+			name: "equals ignoring order and fields",
+			matcher: Matcher{
+				JSONEquals: map[string]interface{}{
+					"total": 42.5, "note": nil,
+					"items": []interface{}{map[string]interface{}{"qty": 2, "sku": "A-1"}, map[string]interface{}{"qty": 5, "sku": "B-2"}},
+				},
+				JSONIgnore: []string{"$.id", "createdAt", "$.items[*].addedAt"},
+			},
+		},
+		{
+			name: "equals mismatch",
+			matcher: Matcher{
+				JSONEquals: map[string]interface{}{
+					"id": "9f1c", "total": 40, "coupon": "SALE",
+					"items": []interface{}{map[string]interface{}{"qty": 3, "sku": "A-1"}, map[string]interface{}{"qty": 5, "sku": "B-2"}},
+				},
+				JSONIgnore: []string{"$.items[*].addedAt"},
+			},
+			want: []Mismatch{
+				{Field: "json.coupon", Expected: `"SALE"`, Actual: "<missing>"},
+				{Field: "json.createdAt", Expected: "<missing>", Actual: `"2024-01-02T03:04:05Z"`},
+				{Field: "json.items[0].qty", Expected: "3", Actual: "2"},
+				{Field: "json.note", Expected: "<missing>", Actual: "null"},
+				{Field: "json.total", Expected: "40", Actual: "42.5"},
+			},
+		},
+		{
+			name:    "equals array length",
+			matcher: Matcher{JSONEquals: map[string]interface{}{"items": []interface{}{}}, JSONIgnore: []string{"$.id", "$.createdAt", "$.total", "$.note"}},
+			want: []Mismatch{
+				{Field: "json.items", Expected: "[]", Actual: `[{"addedAt":1,"qty":2,"sku":"A-1"},{"addedAt":2,"qty":5,"sku":"B-2"}]`},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.matcher.Validate(); err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+			before, _ := json.Marshal(tt.matcher)
+			got := tt.matcher.Explain(msg)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mismatches:\n got: %#v\nwant: %#v", got, tt.want)
+			}
+			if after, _ := json.Marshal(tt.matcher); string(after) != string(before) {
+				t.Errorf("matcher must not be modified: %s", after)
+			}
+		})
+	}
+}
+
+func TestJSONConditionValidate(t *testing.T) {
+	no := false
+	for name, condition := range map[string]JSONCondition{
+		"path":           {Path: "$.items["},
+		"matches":        {Path: "$.id", Matches: "("},
+		"absent equals":  {Path: "$.id", Exists: &no, Equals: "x"},
+		"absent matches": {Path: "$.id", Exists: &no, Matches: "x"},
+	} {
+		if err := condition.Validate(); err == nil {
+			t.Errorf("Validate %s must return error", name)
+		}
+	}
+	if err := (Matcher{JSONIgnore: []string{"$.a[b]"}}).Validate(); err == nil {
+		t.Errorf("Validate jsonIgnore must return error")
+	}
+}
@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is parsed dot notation path like $.items[0].name, keys are strings, indices are ints,
+// and wildcards like $.items[*].name are jsonWildcard.
+type jsonPath []interface{}
+
+// jsonWildcard step selects all elements of array or values of object.
+type jsonWildcard struct{}
+
+func parseJSONPath(expr string) (jsonPath, error) {
+	rest := strings.TrimPrefix(strings.TrimSpace(expr), "$")
+	var path jsonPath
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("json path %q has empty key", expr)
+			}
+			if rest[:end] == "*" {
+				path = append(path, jsonWildcard{})
+			} else {
+				path = append(path, rest[:end])
+			}
+			rest = rest[end:]
+
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("json path %q has unclosed bracket", expr)
+			}
+			key := rest[1:end]
+			rest = rest[end+1:]
+			if key == "*" {
+				path = append(path, jsonWildcard{})
+				continue
+			}
+			if unquoted, err := strconv.Unquote(strings.ReplaceAll(key, "'", `"`)); err == nil {
+				path = append(path, unquoted)
+				continue
+			}
+			index, err := strconv.Atoi(key)
+			if err != nil {
+				return nil, fmt.Errorf("json path %q has invalid index %q", expr, key)
+			}
+			path = append(path, index)
+
+		default:
+			if len(path) > 0 {
+				return nil, fmt.Errorf("json path %q is invalid at %q", expr, rest)
+			}
+			// Leading key without $. is allowed: user.id
+			rest = "." + rest
+		}
+	}
+
+	return path, nil
+}
+
+// find returns values found by the path in decoded JSON document, there is at most one unless the path has wildcards.
+func (p jsonPath) find(doc interface{}) []interface{} {
+	if len(p) == 0 {
+		return []interface{}{doc}
+	}
+
+	var values []interface{}
+	switch key := p[0].(type) {
+	case string:
+		object, _ := doc.(map[string]interface{})
+		if value, ok := object[key]; ok {
+			values = append(values, p[1:].find(value)...)
+		}
+	case int:
+		array, _ := doc.([]interface{})
+		if key < 0 {
+			key += len(array)
+		}
+		if key >= 0 && key < len(array) {
+			values = append(values, p[1:].find(array[key])...)
+		}
+	case jsonWildcard:
+		for _, value := range children(doc) {
+			values = append(values, p[1:].find(value)...)
+		}
+	}
+
+	return values
+}
+
+// remove deletes values found by the path from decoded JSON document, and returns the document.
+func (p jsonPath) remove(doc interface{}) interface{} {
+	if len(p) == 0 {
+		return nil
+	}
+
+	last := len(p) - 1
+	parents := []interface{}{doc}
+	if last > 0 {
+		parents = p[:last].find(doc)
+	}
+	for _, parent := range parents {
+		switch parent := parent.(type) {
+		case map[string]interface{}:
+			switch key := p[last].(type) {
+			case string:
+				delete(parent, key)
+			case jsonWildcard:
+				for name := range parent {
+					delete(parent, name)
+				}
+			}
+		case []interface{}:
+			// Removed elements are replaced by null, so indices of the rest stay the same.
+			switch key := p[last].(type) {
+			case int:
+				if key < 0 {
+					key += len(parent)
+				}
+				if key >= 0 && key < len(parent) {
+					parent[key] = nil
+				}
+			case jsonWildcard:
+				for i := range parent {
+					parent[i] = nil
+				}
+			}
+		}
+	}
+
+	return doc
+}
+
+// children returns elements of array, or values of object sorted by their keys.
+func children(value interface{}) []interface{} {
+	switch value := value.(type) {
+	case []interface{}:
+		return value
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		values := make([]interface{}, 0, len(keys))
+		for _, key := range keys {
+			values = append(values, value[key])
+		}
+		return values
+	default:
+		return nil
+	}
+}
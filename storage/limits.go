@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const (
+	// OverflowDropOldest evicts the first messages to make room for the pushed one.
+	OverflowDropOldest = "drop-oldest"
+	// OverflowDropNewest evicts the pushed message.
+	OverflowDropNewest = "drop-newest"
+	// OverflowReject fails push with ErrQueueFull.
+	OverflowReject = "reject"
+)
+
+var ErrQueueFull = errors.New("queue is full")
+
+// Limit bounds length of the queue, zero Capacity means unlimited.
+type Limit struct {
+	Capacity int
+	Overflow string
+}
+
+func (l Limit) Validate() error {
+	if l.Capacity < 0 {
+		return fmt.Errorf("capacity %d must not be negative", l.Capacity)
+	}
+	switch l.Overflow {
+	case OverflowDropOldest, OverflowDropNewest, OverflowReject:
+		return nil
+	default:
+		return fmt.Errorf("overflow %q must be %s, %s, or %s", l.Overflow, OverflowDropOldest, OverflowDropNewest, OverflowReject)
+	}
+}
+
+// Stats describes the queue and how many messages did not fit it or expired.
+type Stats struct {
+	Length   int
+	Limit    Limit
+	Evicted  int
+	Rejected int
+	Expired  int
+}
+
+type limits struct {
+	mu      sync.RWMutex
+	byQueue map[string]Limit
+}
+
+func newLimits() *limits {
+	return &limits{
+		byQueue: map[string]Limit{
+			QueueResponses: {Overflow: OverflowReject},
+			QueueRequests:  {Overflow: OverflowDropOldest},
+		},
+	}
+}
+
+func (l *limits) get(queue string) Limit {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.byQueue[queue]
+}
+
+// excess returns how many of the oldest records kept along with captured requests, e.g. failures and deliveries,
+// are dropped to fit capacity of Requests queue, regardless of its overflow.
+func (l *limits) excess(length int) int {
+	if capacity := l.get(QueueRequests).Capacity; capacity > 0 && length > capacity {
+		return length - capacity
+	}
+
+	return 0
+}
+
+// Limit returns limit of the queue, it is shared by all hosts and sessions.
+func (q *Queues) Limit(queue string) (Limit, error) {
+	if q.store(queue) == nil {
+		return Limit{}, fmt.Errorf("unknown queue %s", queue)
+	}
+
+	return q.limits.get(queue), nil
+}
+
+// SetLimit changes limit of the queue in all hosts and sessions.
+// Messages exceeding lowered capacity are evicted on the next push.
+func (q *Queues) SetLimit(queue string, limit Limit) error {
+	if q.store(queue) == nil {
+		return fmt.Errorf("unknown queue %s", queue)
+	}
+	if err := limit.Validate(); err != nil {
+		return err
+	}
+
+	q.limits.mu.Lock()
+	defer q.limits.mu.Unlock()
+
+	q.limits.byQueue[queue] = limit
+
+	return nil
+}
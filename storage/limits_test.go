@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestQueuesLimits(t *testing.T) {
+	for _, tt := range [...]struct {
+		overflow     string
+		wantBodies   []string
+		wantEvicted  int
+		wantRejected int
+	}{
+		{overflow: OverflowDropOldest, wantBodies: []string{"2", "3"}, wantEvicted: 2},
+		{overflow: OverflowDropNewest, wantBodies: []string{"0", "1"}, wantEvicted: 2},
+		{overflow: OverflowReject, wantBodies: []string{"0", "1"}, wantRejected: 2},
+	} {
+		t.Run(tt.overflow, func(t *testing.T) {
+			queues := NewQueues()
+			limit := Limit{Capacity: 2, Overflow: tt.overflow}
+			if err := queues.SetLimit(QueueRequests, limit); err != nil {
+				t.Fatalf("SetLimit: %v", err)
+			}
+
+			host := queues.Host("api.local")
+			for i := 0; i < 4; i++ {
+				err := host.Requests.PushLast(Message{Body: strconv.Itoa(i), Request: &Request{}})
+				if tt.overflow == OverflowReject && i >= 2 {
+					if !errors.Is(err, ErrQueueFull) {
+						t.Errorf("PushLast must return ErrQueueFull: %v", err)
+					}
+				} else if err != nil {
+					t.Errorf("PushLast: %v", err)
+				}
+			}
+
+			var bodies []string
+			for _, msg := range host.Requests.List() {
+				bodies = append(bodies, msg.Body)
+			}
+			if !reflect.DeepEqual(bodies, tt.wantBodies) {
+				t.Errorf("unexpected bodies: %#v", bodies)
+			}
+
+			want := Stats{Length: 2, Limit: limit, Evicted: tt.wantEvicted, Rejected: tt.wantRejected}
+			if got := host.Requests.Stats(); got != want {
+				t.Errorf("stats mismatch:\n got: %#v\nwant: %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestQueuesLoweredLimit(t *testing.T) {
+	queues := NewQueues()
+	for i := 0; i < 5; i++ {
+		if err := queues.Requests.PushLast(Message{Body: strconv.Itoa(i), Request: &Request{}}); err != nil {
+			t.Fatalf("PushLast: %v", err)
+		}
+	}
+
+	if err := queues.SetLimit(QueueRequests, Limit{Capacity: 2, Overflow: OverflowDropOldest}); err != nil {
+		t.Fatalf("SetLimit: %v", err)
+	}
+	if err := queues.Requests.PushLast(Message{Body: "5", Request: &Request{}}); err != nil {
+		t.Fatalf("PushLast: %v", err)
+	}
+
+	list := queues.Requests.List()
+	if len(list) != 2 || list[0].Body != "4" || list[1].Body != "5" {
+		t.Errorf("unexpected list: %#v", list)
+	}
+	if stats := queues.Requests.Stats(); stats.Evicted != 4 {
+		t.Errorf("unexpected stats: %#v", stats)
+	}
+}
+
+func TestQueuesSetLimitInvalid(t *testing.T) {
+	queues := NewQueues()
+	if err := queues.SetLimit("unknown", Limit{Overflow: OverflowReject}); err == nil {
+		t.Errorf("SetLimit must return error for unknown queue")
+	}
+	if err := queues.SetLimit(QueueRequests, Limit{Capacity: -1, Overflow: OverflowReject}); err == nil {
+		t.Errorf("SetLimit must return error for negative capacity")
+	}
+	if err := queues.SetLimit(QueueRequests, Limit{Overflow: "unknown"}); err == nil {
+		t.Errorf("SetLimit must return error for unknown overflow")
+	}
+}
+
+func TestJournalLimits(t *testing.T) {
+	dir := t.TempDir()
+	queues, err := OpenQueues(dir, nil)
+	if err != nil {
+		t.Fatalf("OpenQueues: %v", err)
+	}
+	if err := queues.SetLimit(QueueResponses, Limit{Capacity: 1, Overflow: OverflowDropOldest}); err != nil {
+		t.Fatalf("SetLimit: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := queues.Responses.PushLast(Message{Body: strconv.Itoa(i), Response: &Response{}}); err != nil {
+			t.Fatalf("PushLast: %v", err)
+		}
+	}
+
+	reopened := reopenQueues(t, queues, dir)
+	if list := reopened.Responses.List(); len(list) != 1 || list[0].Body != "2" {
+		t.Errorf("unexpected list: %#v", list)
+	}
+}
@@ -0,0 +1,277 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// AnyHeaderValue as header value of Matcher only requires presence of the header.
+const AnyHeaderValue = "*"
+
+// Matcher describes requests, its empty fields match anything.
+type Matcher struct {
+	Method string `json:"method,omitempty"`
+	// Path is glob pattern of URL path, where * matches any sequence of non-slash characters.
+	Path string `json:"path,omitempty"`
+	// PathRegex is regular expression which must match URL path.
+	PathRegex string `json:"pathRegex,omitempty"`
+	// Query parameters must have the given value, or be present for AnyHeaderValue.
+	Query map[string]string `json:"query,omitempty"`
+	// Headers must have the given value, or be present for AnyHeaderValue.
+	Headers      map[string]string `json:"headers,omitempty"`
+	BodyContains string            `json:"bodyContains,omitempty"`
+	JSON         []JSONCondition   `json:"json,omitempty"`
+	// JSONEquals requires body parsed as JSON to be equal to the document regardless of key order and formatting.
+	JSONEquals interface{} `json:"jsonEquals,omitempty"`
+	// JSONIgnore are paths removed from both body and JSONEquals before comparison, e.g. $.id or $.items[*].createdAt.
+	JSONIgnore []string `json:"jsonIgnore,omitempty"`
+	// SOAPAction is action of SOAP request, from SOAPAction header or action parameter of SOAP 1.2 content type.
+	SOAPAction string `json:"soapAction,omitempty"`
+	// XPath lists conditions on body parsed as XML.
+	XPath []XPathCondition `json:"xpath,omitempty"`
+	// XMLNamespaces binds prefixes of names in XPath to namespace URIs, unprefixed names match any namespace.
+	XMLNamespaces map[string]string `json:"xmlNamespaces,omitempty"`
+	// XMLEquals requires body parsed as XML to be equal to the document regardless of namespace prefixes,
+	// attribute order, formatting, and comments.
+	XMLEquals string `json:"xmlEquals,omitempty"`
+}
+
+// Mismatch explains why the request does not match one of Matcher criteria.
+type Mismatch struct {
+	Field    string `json:"field"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+func (m Matcher) Validate() error {
+	if m.Path != "" {
+		if _, err := path.Match(m.Path, ""); err != nil {
+			return fmt.Errorf("path %q: %w", m.Path, err)
+		}
+	}
+	if m.PathRegex != "" {
+		if _, err := compileRegexp(m.PathRegex); err != nil {
+			return fmt.Errorf("pathRegex %q: %w", m.PathRegex, err)
+		}
+	}
+	for _, condition := range m.JSON {
+		if err := condition.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, ignored := range m.JSONIgnore {
+		if _, err := parseJSONPath(ignored); err != nil {
+			return fmt.Errorf("jsonIgnore %w", err)
+		}
+	}
+	for _, condition := range m.XPath {
+		if err := condition.Validate(m.XMLNamespaces); err != nil {
+			return err
+		}
+	}
+	if m.XMLEquals != "" {
+		if _, err := parseXML(m.XMLEquals); err != nil {
+			return fmt.Errorf("xmlEquals: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Matches reports whether the request message satisfies all criteria.
+func (m Matcher) Matches(msg Message) bool {
+	return len(m.Explain(msg)) == 0
+}
+
+// Explain returns criteria the request message does not satisfy, it is empty if the message matches.
+func (m Matcher) Explain(msg Message) []Mismatch {
+	if !msg.IsRequest() {
+		return []Mismatch{{Field: "request", Expected: "request", Actual: "response"}}
+	}
+
+	var mismatches []Mismatch
+	if m.Method != "" && !strings.EqualFold(m.Method, msg.Request.Method) {
+		mismatches = append(mismatches, Mismatch{Field: "method", Expected: m.Method, Actual: msg.Request.Method})
+	}
+
+	requestPath := msg.Request.Path()
+	if m.Path != "" {
+		if ok, _ := path.Match(m.Path, requestPath); !ok {
+			mismatches = append(mismatches, Mismatch{Field: "path", Expected: m.Path, Actual: requestPath})
+		}
+	}
+	if m.PathRegex != "" {
+		re, err := compileRegexp(m.PathRegex)
+		if err != nil || !re.MatchString(requestPath) {
+			mismatches = append(mismatches, Mismatch{Field: "path", Expected: "~" + m.PathRegex, Actual: requestPath})
+		}
+	}
+
+	if len(m.Query) > 0 {
+		mismatches = append(mismatches, m.explainQuery(msg.Request.Query())...)
+	}
+
+	names := make([]string, 0, len(m.Headers))
+	for name := range m.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		expected := m.Headers[name]
+		values, ok := msg.Headers[http.CanonicalHeaderKey(name)]
+		actual := strings.Join(values, ", ")
+		if !ok {
+			mismatches = append(mismatches, Mismatch{Field: "headers." + name, Expected: expected, Actual: "<missing>"})
+		} else if expected != AnyHeaderValue && !containsString(values, expected) {
+			mismatches = append(mismatches, Mismatch{Field: "headers." + name, Expected: expected, Actual: actual})
+		}
+	}
+
+	if m.BodyContains != "" && !strings.Contains(msg.Body, m.BodyContains) {
+		mismatches = append(mismatches, Mismatch{Field: "body", Expected: "contains " + m.BodyContains, Actual: abbreviate(msg.Body)})
+	}
+
+	if len(m.JSON) > 0 || m.JSONEquals != nil {
+		mismatches = append(mismatches, m.explainJSON(msg.Body)...)
+	}
+
+	if m.SOAPAction != "" {
+		if action := soapAction(msg.Headers); action == "" {
+			mismatches = append(mismatches, Mismatch{Field: "soapAction", Expected: m.SOAPAction, Actual: "<missing>"})
+		} else if action != m.SOAPAction {
+			mismatches = append(mismatches, Mismatch{Field: "soapAction", Expected: m.SOAPAction, Actual: action})
+		}
+	}
+	if len(m.XPath) > 0 || m.XMLEquals != "" {
+		mismatches = append(mismatches, m.explainXML(msg.Body)...)
+	}
+
+	return mismatches
+}
+
+func (m Matcher) explainQuery(query url.Values) []Mismatch {
+	names := make([]string, 0, len(m.Query))
+	for name := range m.Query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var mismatches []Mismatch
+	for _, name := range names {
+		expected := m.Query[name]
+		values, ok := query[name]
+		if !ok {
+			mismatches = append(mismatches, Mismatch{Field: "query." + name, Expected: expected, Actual: "<missing>"})
+		} else if expected != AnyHeaderValue && !containsString(values, expected) {
+			mismatches = append(mismatches, Mismatch{Field: "query." + name, Expected: expected, Actual: strings.Join(values, ", ")})
+		}
+	}
+
+	return mismatches
+}
+
+// Path returns URL path of the request without query.
+func (r Request) Path() string {
+	u, err := url.ParseRequestURI(r.Url)
+	if err != nil {
+		path, _, _ := strings.Cut(r.Url, "?")
+		return path
+	}
+
+	return u.Path
+}
+
+// Query returns parsed URL query of the request.
+func (r Request) Query() url.Values {
+	_, rawQuery, _ := strings.Cut(r.Url, "?")
+	query, _ := url.ParseQuery(rawQuery)
+
+	return query
+}
+
+// AbsoluteURL returns URL of the request with scheme and host as received, e.g. https://api.local:8010/path?query.
+func (r Request) AbsoluteURL() string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	host := r.RawHost
+	if host == "" {
+		host = r.Host
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+	}
+
+	return scheme + "://" + host + r.Url
+}
+
+var regexpCache sync.Map
+
+func compileRegexp(expr string) (*regexp.Regexp, error) {
+	if re, ok := regexpCache.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	regexpCache.Store(expr, re)
+
+	return re, nil
+}
+
+// jsonEqual compares decoded JSON values, so 1 and 1.0 are equal.
+func jsonEqual(a, b interface{}) bool {
+	return reflect.DeepEqual(normalizeJSON(a), normalizeJSON(b))
+}
+
+func normalizeJSON(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return v
+	}
+
+	return normalized
+}
+
+func jsonString(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(data)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// abbreviate shortens long body in mismatch explanation.
+func abbreviate(s string) string {
+	const limit = 256
+	if len(s) <= limit {
+		return s
+	}
+
+	return s[:limit] + "..."
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestMatcherExplain(t *testing.T) {
+	msg := Message{
+		Headers: http.Header{
+			"Content-Type": {"application/json"},
+			"X-Api-Key":    {"secret"},
+		},
+		Body: `{"user": {"id": 1, "tags": ["a", "b"]}}`,
+		Request: &Request{
+			Method: "POST",
+			Url:    "/v1/user?verbose=1",
+		},
+	}
+
+	for _, tt := range [...]struct {
+		name    string
+		matcher Matcher
+		want    []Mismatch
+	}{
+		{
+			name:    "empty",
+			matcher: Matcher{},
+		},
+		{
+			name: "all match",
+			matcher: Matcher{
+				Method:       "post",
+				Path:         "/v1/*",
+				PathRegex:    `^/v1/user$`,
+				Query:        map[string]string{"verbose": "1"},
+				Headers:      map[string]string{"x-api-key": "secret", "Content-Type": AnyHeaderValue},
+				BodyContains: `"id": 1`,
+				JSON: []JSONCondition{
+					{Path: "$.user.id", Equals: 1.0},
+					{Path: "user.tags[1]", Equals: "b"},
+					{Path: "$['user'].tags", Equals: []interface{}{"a", "b"}},
+				},
+			},
+		},
+		{
+			name: "method and path",
+			matcher: Matcher{
+				Method:    "GET",
+				Path:      "/v1/users",
+				PathRegex: `^/v2/`,
+			},
+			want: []Mismatch{
+				{Field: "method", Expected: "GET", Actual: "POST"},
+				{Field: "path", Expected: "/v1/users", Actual: "/v1/user"},
+				{Field: "path", Expected: "~^/v2/", Actual: "/v1/user"},
+			},
+		},
+		{
+			name: "headers",
+			matcher: Matcher{
+				Headers: map[string]string{"X-Api-Key": "other", "Authorization": AnyHeaderValue},
+			},
+			want: []Mismatch{
+				{Field: "headers.Authorization", Expected: "*", Actual: "<missing>"},
+				{Field: "headers.X-Api-Key", Expected: "other", Actual: "secret"},
+			},
+		},
+		{
+			name: "query",
+			matcher: Matcher{
+				Query: map[string]string{"verbose": "2", "page": AnyHeaderValue},
+			},
+			want: []Mismatch{
+				{Field: "query.page", Expected: "*", Actual: "<missing>"},
+				{Field: "query.verbose", Expected: "2", Actual: "1"},
+			},
+		},
+		{
+			name: "body",
+			matcher: Matcher{
+				BodyContains: "name",
+				JSON: []JSONCondition{
+					{Path: "$.user.id", Equals: 2},
+					{Path: "$.user.name", Equals: "John"},
+				},
+			},
+			want: []Mismatch{
+				{Field: "body", Expected: "contains name", Actual: `{"user": {"id": 1, "tags": ["a", "b"]}}`},
+				{Field: "json.user.id", Expected: "2", Actual: "1"},
+				{Field: "json.user.name", Expected: `"John"`, Actual: "<missing>"},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.matcher.Validate(); err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+			got := tt.matcher.Explain(msg)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mismatches:\n got: %#v\nwant: %#v", got, tt.want)
+			}
+			if tt.matcher.Matches(msg) != (len(tt.want) == 0) {
+				t.Errorf("Matches must agree with Explain")
+			}
+		})
+	}
+}
+
+func TestMatcherValidate(t *testing.T) {
+	for name, matcher := range map[string]Matcher{
+		"path":      {Path: "["},
+		"pathRegex": {PathRegex: "("},
+		"json":      {JSON: []JSONCondition{{Path: "$.items[x]"}}},
+	} {
+		if err := matcher.Validate(); err == nil {
+			t.Errorf("Validate %s must return error", name)
+		}
+	}
+}
@@ -1,23 +1,303 @@
 package storage
 
-import "fmt"
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	QueueResponses = "responses"
+	QueueRequests  = "requests"
+
+	scopeHosts    = "hosts"
+	scopeSessions = "sessions"
+)
 
 type Queues struct {
 	Responses Store
 	Requests  Store
+
+	mu       sync.Mutex
+	hosts    map[string]*Queues
+	sessions map[string]*Queues
+	usedAt   atomic.Int64
+	// fallback is response served when no pending response matches, see SetDefault.
+	fallback *Message
+	// failures are unmatched requests recorded in strict mode, see Fail.
+	failures []Message
+	// deliveries are outcomes of sent callbacks, see Deliver.
+	deliveries []Delivery
+	// rateLimits are checked in order by Throttle, their buckets are by name and key.
+	rateLimits []RateLimit
+	buckets    map[string]map[string]*bucket
+	// contract requests are checked against, see SetContract.
+	contract Contract
+	// scenarios are states of scenarios other than ScenarioStarted.
+	scenarios map[string]string
+	// serveMu makes choice of served response and transition of its scenario atomic.
+	serveMu sync.Mutex
+	// pickers of variants of stubs by response ID, they require serveMu.
+	pickers map[string]*picker
+
+	// journal persists changes of the queues, they are kept in memory only if nil.
+	journal *Journal
+	// limits are shared by the root queues and all nested ones.
+	limits *limits
+	// scope is the path of kind and name pairs from root queues, e.g. ["sessions", "id", "hosts", "api.local"].
+	scope []string
 }
 
 func NewQueues() *Queues {
-	return &Queues{
-		Responses: NewStore(responseValidator),
-		Requests:  NewStore(requestValidator),
+	return newQueues(nil, newLimits(), nil)
+}
+
+func newQueues(journal *Journal, limits *limits, scope []string) *Queues {
+	q := &Queues{
+		journal: journal,
+		limits:  limits,
+		scope:   scope,
+	}
+	q.Responses = q.newStore(QueueResponses, responseValidator)
+	q.Requests = q.newStore(QueueRequests, requestValidator)
+	q.touch()
+
+	return q
+}
+
+func (q *Queues) newStore(queue string, validator func(Message) error) Store {
+	s := &store{
+		validator: validator,
+		limit: func() Limit {
+			return q.limits.get(queue)
+		},
+	}
+	if q.journal == nil {
+		return s
+	}
+
+	return &journalStore{
+		journal: q.journal,
+		scope:   q.scope,
+		queue:   queue,
+		store:   s,
+	}
+}
+
+// newChild returns empty queues nested into q, which are not attached to q yet.
+func (q *Queues) newChild(kind, name string) *Queues {
+	scope := append(append([]string(nil), q.scope...), kind, name)
+	q.mustJournal(journalRecord{Scope: scope, Op: opCreate})
+
+	return newQueues(q.journal, q.limits, scope)
+}
+
+// mustJournal persists change of queues structure, which has no way to report failure.
+func (q *Queues) mustJournal(record journalRecord) {
+	if q.journal == nil {
+		return
+	}
+	if err := q.journal.write(record); err != nil {
+		panic(err)
+	}
+}
+
+func (q *Queues) touch() {
+	q.usedAt.Store(time.Now().UnixNano())
+}
+
+func (q *Queues) idle(now time.Time) time.Duration {
+	return now.Sub(time.Unix(0, q.usedAt.Load()))
+}
+
+// Host returns queues of the virtual host, creating them on first use.
+// Empty name refers to the queues themselves.
+func (q *Queues) Host(name string) *Queues {
+	name = NormalizeHost(name)
+	if name == "" {
+		return q
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	host, ok := q.hosts[name]
+	if !ok {
+		if q.hosts == nil {
+			q.hosts = make(map[string]*Queues)
+		}
+		host = q.newChild(scopeHosts, name)
+		q.hosts[name] = host
+	}
+
+	return host
+}
+
+// LookupHost returns queues of the virtual host only if they already exist.
+func (q *Queues) LookupHost(name string) (*Queues, bool) {
+	name = NormalizeHost(name)
+	if name == "" {
+		return q, true
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	host, ok := q.hosts[name]
+
+	return host, ok
+}
+
+func (q *Queues) Hosts() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	names := make([]string, 0, len(q.hosts))
+	for name := range q.hosts {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (q *Queues) RemoveHost(name string) bool {
+	name = NormalizeHost(name)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	host, ok := q.hosts[name]
+	if !ok {
+		return false
+	}
+	q.mustJournal(journalRecord{Scope: host.scope, Op: opRemove})
+	delete(q.hosts, name)
+
+	return true
+}
+
+// walk calls fn for the queues and then for nested ones in stable order.
+func (q *Queues) walk(fn func(*Queues) error) error {
+	if err := fn(q); err != nil {
+		return err
+	}
+
+	for _, kind := range [...]string{scopeHosts, scopeSessions} {
+		for _, child := range q.sortedChildren(kind) {
+			if err := child.walk(fn); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// sortedChildren returns nested queues of the kind ordered by name, the map is read under lock only.
+func (q *Queues) sortedChildren(kind string) []*Queues {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	children := *q.children(kind)
+	names := make([]string, 0, len(children))
+	for name := range children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	nodes := make([]*Queues, 0, len(names))
+	for _, name := range names {
+		nodes = append(nodes, children[name])
+	}
+
+	return nodes
+}
+
+func (q *Queues) children(kind string) *map[string]*Queues {
+	switch kind {
+	case scopeHosts:
+		return &q.hosts
+	case scopeSessions:
+		return &q.sessions
+	default:
+		return nil
+	}
+}
+
+func (q *Queues) store(queue string) Store {
+	switch queue {
+	case QueueResponses:
+		return q.Responses
+	case QueueRequests:
+		return q.Requests
+	default:
+		return nil
+	}
+}
+
+// Expiration describes messages removed from the queue by Expire.
+type Expiration struct {
+	Scope []string
+	Queue string
+	Count int
+}
+
+// Expire removes messages expired at now from all queues including nested ones.
+func (q *Queues) Expire(now time.Time) []Expiration {
+	var expirations []Expiration
+	_ = q.walk(func(node *Queues) error {
+		for _, queue := range [...]string{QueueResponses, QueueRequests} {
+			if removed := node.store(queue).Expire(now); len(removed) > 0 {
+				expirations = append(expirations, Expiration{Scope: node.scope, Queue: queue, Count: len(removed)})
+			}
+		}
+		return nil
+	})
+
+	return expirations
+}
+
+// NormalizeHost lowercases host and strips port, so "API.local:8010" and "api.local" share queues.
+func NormalizeHost(host string) string {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+
+	return strings.ToLower(strings.Trim(host, "[]"))
 }
 
 func responseValidator(message Message) error {
 	if !message.IsResponse() {
 		return fmt.Errorf("%#v is not Response", message)
 	}
+	if match := message.Response.Match; match != nil {
+		if err := match.Validate(); err != nil {
+			return fmt.Errorf("match %w", err)
+		}
+	}
+	if step := message.Response.Scenario; step != nil {
+		if err := step.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, variant := range message.Response.Variants {
+		if err := variant.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := validatePick(message.Response.Pick); err != nil {
+		return err
+	}
+	for _, callback := range message.Response.Callbacks {
+		if err := callback.Validate(); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
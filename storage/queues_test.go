@@ -1,7 +1,10 @@
 package storage
 
 import (
+	"fmt"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestQueues(t *testing.T) {
@@ -28,3 +31,106 @@ func TestQueues(t *testing.T) {
 		t.Errorf("PushLast must return error")
 	}
 }
+
+func TestQueuesHost(t *testing.T) {
+	queues := NewQueues()
+
+	if host := queues.Host(""); host != queues {
+		t.Errorf("empty host must refer to default queues")
+	}
+	if _, ok := queues.LookupHost("api.local"); ok {
+		t.Errorf("LookupHost must not find unknown host")
+	}
+
+	host := queues.Host("API.local:8010")
+	if host == queues {
+		t.Errorf("host queues must differ from default queues")
+	}
+	if got, ok := queues.LookupHost("api.local"); !ok || got != host {
+		t.Errorf("LookupHost must find created host")
+	}
+	if got := queues.Host("api.local"); got != host {
+		t.Errorf("Host must return existing host queues")
+	}
+
+	if err := host.Responses.PushLast(Message{Response: &Response{}}); err != nil {
+		t.Fatalf("PushLast: %v", err)
+	}
+	if list := queues.Responses.List(); len(list) != 0 {
+		t.Errorf("%#v must be empty", list)
+	}
+
+	if hosts := queues.Hosts(); len(hosts) != 1 || hosts[0] != "api.local" {
+		t.Errorf("unexpected hosts: %#v", hosts)
+	}
+
+	if !queues.RemoveHost("api.local") {
+		t.Errorf("RemoveHost must remove existing host")
+	}
+	if queues.RemoveHost("api.local") {
+		t.Errorf("RemoveHost must not remove unknown host")
+	}
+}
+
+func TestNormalizeHost(t *testing.T) {
+	for host, want := range map[string]string{
+		"":                   "",
+		"api.local":          "api.local",
+		"API.Local:8010":     "api.local",
+		"[::1]:8010":         "::1",
+		"[::1]":              "::1",
+		"127.0.0.1:8010":     "127.0.0.1",
+		"api.payments.local": "api.payments.local",
+	} {
+		if got := NormalizeHost(host); got != want {
+			t.Errorf("NormalizeHost(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
+
+func TestQueuesExpire(t *testing.T) {
+	now := time.Now()
+	queues := NewQueues()
+	stale := Message{ExpiresAt: now.Add(-time.Second), Response: &Response{}}
+	if err := queues.Responses.PushLast(stale); err != nil {
+		t.Fatalf("PushLast: %v", err)
+	}
+	if err := queues.Host("api.local").Requests.PushLast(Message{ExpiresAt: stale.ExpiresAt, Request: &Request{}}); err != nil {
+		t.Fatalf("PushLast: %v", err)
+	}
+	if err := queues.Host("api.local").Requests.PushLast(Message{Request: &Request{}}); err != nil {
+		t.Fatalf("PushLast: %v", err)
+	}
+
+	want := []Expiration{
+		{Queue: QueueResponses, Count: 1},
+		{Scope: []string{"hosts", "api.local"}, Queue: QueueRequests, Count: 1},
+	}
+	if got := queues.Expire(now); !reflect.DeepEqual(got, want) {
+		t.Errorf("expirations mismatch:\n got: %#v\nwant: %#v", got, want)
+	}
+	if got := queues.Expire(now); len(got) != 0 {
+		t.Errorf("unexpected expirations: %#v", got)
+	}
+}
+
+func TestQueuesExpireConcurrent(t *testing.T) {
+	queues := NewQueues()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10000; i++ {
+			queues.Host(fmt.Sprintf("host-%d", i))
+			queues.CreateSession()
+		}
+	}()
+
+	for {
+		queues.Expire(time.Now())
+		select {
+		case <-done:
+			return
+		default:
+		}
+	}
+}
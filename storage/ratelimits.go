@@ -0,0 +1,257 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+)
+
+// RateLimit is token bucket limiting requests, which match it, to Limit per Period.
+type RateLimit struct {
+	Name string `json:"name"`
+	// Match restricts requests the limit applies to, it applies to any request if nil.
+	Match  *Matcher      `json:"match,omitempty"`
+	Limit  int           `json:"limit"`
+	Period time.Duration `json:"period"`
+	// KeyHeader makes separate bucket for each value of the request header, e.g. API key.
+	KeyHeader string `json:"keyHeader,omitempty"`
+
+	// Status, Headers, and Body of response to requests exceeding the limit, Status is 429 if zero.
+	Status  int         `json:"status,omitempty"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+func (l RateLimit) Validate() error {
+	if l.Name == "" {
+		return fmt.Errorf("rate limit name must not be empty")
+	}
+	if l.Limit <= 0 {
+		return fmt.Errorf("rate limit %d must be positive", l.Limit)
+	}
+	if l.Period <= 0 {
+		return fmt.Errorf("rate limit period must be positive")
+	}
+	if l.Status != 0 && (l.Status < 100 || l.Status >= 600) {
+		return fmt.Errorf("rate limit status %d must be in [100; 600)", l.Status)
+	}
+	if l.Match != nil {
+		if err := l.Match.Validate(); err != nil {
+			return fmt.Errorf("match %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Quota is state of the rate limit bucket after a request.
+type Quota struct {
+	RateLimit RateLimit
+	// Remaining is count of requests allowed right away.
+	Remaining int
+	// Reset is time until the bucket is full.
+	Reset time.Duration
+	// RetryAfter is time until the next request is allowed, it is zero unless Exceeded.
+	RetryAfter time.Duration
+	Exceeded   bool
+}
+
+// Headers returns X-RateLimit-* headers of the quota, and Retry-After if it is exceeded.
+func (q Quota) Headers() http.Header {
+	headers := http.Header{
+		"X-Ratelimit-Limit":     {fmt.Sprint(q.RateLimit.Limit)},
+		"X-Ratelimit-Remaining": {fmt.Sprint(q.Remaining)},
+		"X-Ratelimit-Reset":     {fmt.Sprint(seconds(q.Reset))},
+	}
+	if q.Exceeded {
+		headers.Set("Retry-After", fmt.Sprint(seconds(q.RetryAfter)))
+	}
+
+	return headers
+}
+
+// Response returns response to request exceeding the quota.
+func (q Quota) Response() Message {
+	status := q.RateLimit.Status
+	if status == 0 {
+		status = http.StatusTooManyRequests
+	}
+
+	headers := q.RateLimit.Headers.Clone()
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	for name, values := range q.Headers() {
+		headers[name] = values
+	}
+
+	return Message{Headers: headers, Body: q.RateLimit.Body, Response: &Response{Status: status}}
+}
+
+// seconds rounds the duration up to whole seconds.
+func seconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// bucket holds tokens, each request takes one, and they are refilled continuously up to the limit.
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// refill adds tokens for time elapsed since the last update, up to the limit.
+func (b *bucket) refill(limit RateLimit, now time.Time) {
+	if elapsed := now.Sub(b.updatedAt); elapsed > 0 {
+		b.tokens = math.Min(float64(limit.Limit), b.tokens+float64(elapsed)/perToken(limit))
+		b.updatedAt = now
+	}
+}
+
+// quota describes the bucket, exceeded one has no token for the request.
+func (b *bucket) quota(limit RateLimit, exceeded bool) Quota {
+	quota := Quota{RateLimit: limit, Exceeded: exceeded}
+	if exceeded {
+		quota.RetryAfter = time.Duration((1 - b.tokens) * perToken(limit))
+	}
+	quota.Remaining = int(b.tokens)
+	quota.Reset = time.Duration((float64(limit.Limit) - b.tokens) * perToken(limit))
+
+	return quota
+}
+
+func perToken(limit RateLimit) float64 {
+	return float64(limit.Period) / float64(limit.Limit)
+}
+
+// RateLimits returns rate limits in order they are checked.
+func (q *Queues) RateLimits() []RateLimit {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return append([]RateLimit(nil), q.rateLimits...)
+}
+
+// SetRateLimit adds the rate limit, or replaces one with the same name resetting its buckets.
+func (q *Queues) SetRateLimit(limit RateLimit) error {
+	if err := limit.Validate(); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	limits := append([]RateLimit(nil), q.rateLimits...)
+	replaced := false
+	for i := range limits {
+		if limits[i].Name == limit.Name {
+			limits[i] = limit
+			replaced = true
+		}
+	}
+	if !replaced {
+		limits = append(limits, limit)
+	}
+	delete(q.buckets, limit.Name)
+	q.setRateLimitsLocked(limits)
+
+	return nil
+}
+
+// SetRateLimits replaces all rate limits.
+func (q *Queues) SetRateLimits(limits []RateLimit) error {
+	names := make(map[string]bool, len(limits))
+	for _, limit := range limits {
+		if err := limit.Validate(); err != nil {
+			return err
+		}
+		if names[limit.Name] {
+			return fmt.Errorf("rate limit name %s must be unique", limit.Name)
+		}
+		names[limit.Name] = true
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.setRateLimitsLocked(append([]RateLimit(nil), limits...))
+
+	return nil
+}
+
+// RemoveRateLimit removes the named rate limit, or all of them if name is empty.
+func (q *Queues) RemoveRateLimit(name string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var limits []RateLimit
+	for _, limit := range q.rateLimits {
+		if name != "" && limit.Name != name {
+			limits = append(limits, limit)
+		}
+	}
+	q.setRateLimitsLocked(limits)
+}
+
+func (q *Queues) setRateLimitsLocked(limits []RateLimit) {
+	q.mustJournal(journalRecord{Scope: q.scope, Op: opRateLimits, RateLimits: limits})
+	q.rateLimits = limits
+
+	buckets := make(map[string]map[string]*bucket)
+	for _, limit := range limits {
+		if keys, ok := q.buckets[limit.Name]; ok {
+			buckets[limit.Name] = keys
+		}
+	}
+	q.buckets = buckets
+}
+
+// Throttle checks buckets of matching rate limits in order, and takes token for the request from each of them
+// only if none is exceeded. It returns their quotas up to the first exceeded one.
+func (q *Queues) Throttle(request Message, now time.Time) []Quota {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var limits []RateLimit
+	var buckets []*bucket
+	for _, limit := range q.rateLimits {
+		if limit.Match != nil && !limit.Match.Matches(request) {
+			continue
+		}
+
+		keys := q.buckets[limit.Name]
+		if keys == nil {
+			keys = make(map[string]*bucket)
+			q.buckets[limit.Name] = keys
+		}
+		key := ""
+		if limit.KeyHeader != "" {
+			key = request.Headers.Get(limit.KeyHeader)
+		}
+		b, ok := keys[key]
+		if !ok {
+			b = &bucket{tokens: float64(limit.Limit), updatedAt: now}
+			keys[key] = b
+		}
+		b.refill(limit, now)
+		limits = append(limits, limit)
+		buckets = append(buckets, b)
+
+		if b.tokens < 1 {
+			// Rejected request does not spend tokens of the other limits.
+			quotas := make([]Quota, len(buckets))
+			for i, b := range buckets {
+				quotas[i] = b.quota(limits[i], i == len(buckets)-1)
+			}
+			return quotas
+		}
+	}
+
+	var quotas []Quota
+	for i, b := range buckets {
+		b.tokens--
+		quotas = append(quotas, b.quota(limits[i], false))
+	}
+
+	return quotas
+}
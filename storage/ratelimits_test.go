@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestQueuesThrottle(t *testing.T) {
+	queues := NewQueues()
+	if err := queues.SetRateLimit(RateLimit{Name: "api", Match: &Matcher{Path: "/api/*"}, Limit: 2, Period: time.Second, KeyHeader: "X-Api-Key"}); err != nil {
+		t.Fatalf("SetRateLimit: %v", err)
+	}
+	if err := queues.SetRateLimit(RateLimit{Limit: 1, Period: time.Second}); err == nil {
+		t.Errorf("rate limit without name must be invalid")
+	}
+
+	request := func(url, key string) Message {
+		return Message{Headers: http.Header{"X-Api-Key": {key}}, Request: &Request{Method: "GET", Url: url}}
+	}
+	now := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	for _, tt := range [...]struct {
+		name    string
+		request Message
+		at      time.Duration
+		want    []Quota
+	}{
+		{name: "unmatched", request: request("/health", "a"), want: nil},
+		{name: "first", request: request("/api/users", "a"), want: []Quota{{Remaining: 1, Reset: 500 * time.Millisecond}}},
+		{name: "second", request: request("/api/users", "a"), want: []Quota{{Remaining: 0, Reset: time.Second}}},
+		{name: "exceeded", request: request("/api/users", "a"), at: 250 * time.Millisecond, want: []Quota{{Remaining: 0, Reset: 750 * time.Millisecond, RetryAfter: 250 * time.Millisecond, Exceeded: true}}},
+		{name: "other key", request: request("/api/users", "b"), at: 250 * time.Millisecond, want: []Quota{{Remaining: 1, Reset: 500 * time.Millisecond}}},
+		{name: "refilled", request: request("/api/users", "a"), at: 500 * time.Millisecond, want: []Quota{{Remaining: 0, Reset: time.Second}}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := queues.Throttle(tt.request, now.Add(tt.at))
+			for i := range got {
+				got[i].RateLimit = RateLimit{}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mismatch quotas:\n got: %#v\nwant: %#v", got, tt.want)
+			}
+		})
+	}
+
+	exceeded := queues.Throttle(request("/api/users", "a"), now.Add(600*time.Millisecond))
+	res := exceeded[0].Response()
+	want := http.Header{
+		"X-Ratelimit-Limit":     {"2"},
+		"X-Ratelimit-Remaining": {"0"},
+		"X-Ratelimit-Reset":     {"1"},
+		"Retry-After":           {"1"},
+	}
+	if res.Response.Status != http.StatusTooManyRequests || !reflect.DeepEqual(res.Headers, want) {
+		t.Errorf("unexpected response %#v", res)
+	}
+
+	queues.RemoveRateLimit("api")
+	if got := queues.Throttle(request("/api/users", "a"), now.Add(time.Second)); len(got) != 0 {
+		t.Errorf("removed rate limit must not apply: %#v", got)
+	}
+}
+
+func TestQueuesThrottleRejected(t *testing.T) {
+	queues := NewQueues()
+	if err := queues.SetRateLimits([]RateLimit{
+		{Name: "burst", Limit: 10, Period: time.Second},
+		{Name: "api", Match: &Matcher{Path: "/api/*"}, Limit: 1, Period: time.Second},
+	}); err != nil {
+		t.Fatalf("SetRateLimits: %v", err)
+	}
+	now := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	for _, tt := range [...]struct {
+		url  string
+		want []int
+	}{
+		{url: "/api/users", want: []int{9, 0}},
+		// Rejected by api limit, burst one keeps its tokens.
+		{url: "/api/users", want: []int{9, 0}},
+		{url: "/health", want: []int{8}},
+	} {
+		quotas := queues.Throttle(Message{Request: &Request{Method: "GET", Url: tt.url}}, now)
+		var got []int
+		for _, quota := range quotas {
+			got = append(got, quota.Remaining)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: mismatch remaining:\n got: %v\nwant: %v", tt.url, got, tt.want)
+		}
+	}
+}
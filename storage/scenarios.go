@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ScenarioStarted is the state of scenario, which was never set or was reset.
+const ScenarioStarted = "Started"
+
+// ScenarioStep makes response depend on state of the named scenario.
+type ScenarioStep struct {
+	Name string `json:"name"`
+	// State is required to serve the response, any state matches if empty.
+	State string `json:"state,omitempty"`
+	// NewState is set when the response is served, the state is kept if empty.
+	NewState string `json:"newState,omitempty"`
+}
+
+func (s ScenarioStep) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("scenario name must not be empty")
+	}
+
+	return nil
+}
+
+// Scenarios returns states of scenarios, which were set and not reset.
+func (q *Queues) Scenarios() map[string]string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	states := make(map[string]string, len(q.scenarios))
+	for name, state := range q.scenarios {
+		states[name] = state
+	}
+
+	return states
+}
+
+// ScenarioNames returns sorted names of scenarios, which were set or are referenced by pending responses.
+func (q *Queues) ScenarioNames() []string {
+	names := q.Scenarios()
+	for _, msg := range q.Responses.List() {
+		if step := msg.Response.Scenario; step != nil {
+			names[step.Name] = ""
+		}
+	}
+
+	list := make([]string, 0, len(names))
+	for name := range names {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+
+	return list
+}
+
+// ScenarioState returns current state of the scenario.
+func (q *Queues) ScenarioState(name string) string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if state, ok := q.scenarios[name]; ok {
+		return state
+	}
+
+	return ScenarioStarted
+}
+
+// SetScenarioState changes state of the scenario, setting ScenarioStarted resets it.
+func (q *Queues) SetScenarioState(name, state string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.mustJournal(journalRecord{Scope: q.scope, Op: opScenario, Name: name, State: state})
+	q.setScenarioStateLocked(name, state)
+}
+
+// ResetScenarios returns all scenarios to ScenarioStarted state.
+func (q *Queues) ResetScenarios() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.mustJournal(journalRecord{Scope: q.scope, Op: opScenario})
+	q.scenarios = nil
+}
+
+// setScenariosLocked replaces states of all scenarios at once.
+func (q *Queues) setScenariosLocked(states map[string]string) {
+	names := make([]string, 0, len(states))
+	for name := range states {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	q.mustJournal(journalRecord{Scope: q.scope, Op: opScenario})
+	q.scenarios = nil
+	for _, name := range names {
+		q.mustJournal(journalRecord{Scope: q.scope, Op: opScenario, Name: name, State: states[name]})
+		q.setScenarioStateLocked(name, states[name])
+	}
+}
+
+func (q *Queues) setScenarioStateLocked(name, state string) {
+	if state == ScenarioStarted || state == "" {
+		delete(q.scenarios, name)
+		return
+	}
+	if q.scenarios == nil {
+		q.scenarios = make(map[string]string)
+	}
+	q.scenarios[name] = state
+}
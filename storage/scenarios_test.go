@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQueuesServeScenario(t *testing.T) {
+	queues := NewQueues()
+	for _, res := range [...]Message{
+		{Body: "pending", Response: &Response{Status: 200, Stub: true, Match: &Matcher{Method: "GET"}, Scenario: &ScenarioStep{Name: "order", State: ScenarioStarted}}},
+		{Body: "paid", Response: &Response{Status: 200, Stub: true, Match: &Matcher{Method: "GET"}, Scenario: &ScenarioStep{Name: "order", State: "paid"}}},
+		{Body: "pay", Response: &Response{Status: 204, Stub: true, Match: &Matcher{Method: "POST"}, Scenario: &ScenarioStep{Name: "order", NewState: "paid"}}},
+	} {
+		if err := queues.Responses.PushLast(res); err != nil {
+			t.Fatalf("PushLast: %v", err)
+		}
+	}
+	get := Message{Request: &Request{Method: "GET", Url: "/order/1"}}
+	post := Message{Request: &Request{Method: "POST", Url: "/order/1/pay"}}
+
+	for _, tt := range [...]struct {
+		request   Message
+		wantBody  string
+		wantState string
+	}{
+		{request: get, wantBody: "pending", wantState: ScenarioStarted},
+		{request: get, wantBody: "pending", wantState: ScenarioStarted},
+		{request: post, wantBody: "pay", wantState: "paid"},
+		{request: get, wantBody: "paid", wantState: "paid"},
+	} {
+		res, _ := queues.Serve(tt.request)
+		if res == nil || res.Body != tt.wantBody {
+			t.Errorf("unexpected served response %#v, want %v", res, tt.wantBody)
+		}
+		if got := queues.ScenarioState("order"); got != tt.wantState {
+			t.Errorf("unexpected state %v, want %v", got, tt.wantState)
+		}
+	}
+	if list := queues.Responses.List(); len(list) != 3 {
+		t.Errorf("stubs must stay queued: %#v", list)
+	}
+	if got := queues.ScenarioNames(); !reflect.DeepEqual(got, []string{"order"}) {
+		t.Errorf("unexpected scenario names: %#v", got)
+	}
+
+	queues.ResetScenarios()
+	_, unmatched := queues.Serve(Message{Request: &Request{Method: "PUT", Url: "/"}})
+	want := []Mismatch{
+		{Field: "method", Expected: "GET", Actual: "PUT"},
+		{Field: "scenario.order", Expected: "paid", Actual: ScenarioStarted},
+	}
+	if unmatched == nil || len(unmatched.Candidates) != 3 || !reflect.DeepEqual(unmatched.Candidates[1].Mismatches, want) {
+		t.Errorf("mismatch unmatched:\n got: %#v\nwant: %#v", unmatched, want)
+	}
+}
@@ -0,0 +1,89 @@
+package storage
+
+import "time"
+
+// Unmatched explains why no pending response was served for the request.
+type Unmatched struct {
+	Candidates []Candidate `json:"candidates"`
+}
+
+// Candidate is pending response whose matcher or scenario rejected the request.
+type Candidate struct {
+	// Index is position of the response in the queue.
+	Index      int        `json:"index"`
+	ID         string     `json:"id,omitempty"`
+	Status     int        `json:"status"`
+	Match      Matcher    `json:"match"`
+	Mismatches []Mismatch `json:"mismatches"`
+}
+
+// Serve returns the first pending response, which is not expired, matches the request and state of its scenario.
+// The response is removed unless it is a stub, its scenario transitions to the new state, and its variant is picked.
+// If there is no such response, it explains how the request differs from pending ones.
+func (q *Queues) Serve(request Message) (*Message, *Unmatched) {
+	q.serveMu.Lock()
+	defer q.serveMu.Unlock()
+
+	now := time.Now()
+	states := q.Scenarios()
+	var served *Message
+	removed := q.Responses.Remove(func(msg Message) bool {
+		if served != nil || msg.IsExpired(now) || len(explainResponse(msg, request, states)) > 0 {
+			return false
+		}
+		served = &msg
+		return !msg.Response.Stub
+	})
+	if len(removed) > 0 {
+		served = &removed[0]
+	}
+	if served != nil {
+		if step := served.Response.Scenario; step != nil && step.NewState != "" {
+			q.SetScenarioState(step.Name, step.NewState)
+		}
+		picked := q.pickVariant(*served)
+		return &picked, nil
+	}
+
+	unmatched := &Unmatched{Candidates: []Candidate{}}
+	for i, msg := range q.Responses.List() {
+		if msg.IsExpired(now) {
+			continue
+		}
+		mismatches := explainResponse(msg, request, states)
+		if len(mismatches) == 0 {
+			continue
+		}
+		candidate := Candidate{
+			Index:      i,
+			ID:         msg.Response.ID,
+			Status:     msg.Response.Status,
+			Mismatches: mismatches,
+		}
+		if msg.Response.Match != nil {
+			candidate.Match = *msg.Response.Match
+		}
+		unmatched.Candidates = append(unmatched.Candidates, candidate)
+	}
+
+	return nil, unmatched
+}
+
+// explainResponse returns why the response can not be served for the request in the scenario states.
+func explainResponse(msg Message, request Message, states map[string]string) []Mismatch {
+	var mismatches []Mismatch
+	if msg.Response.Match != nil {
+		mismatches = msg.Response.Match.Explain(request)
+	}
+	if step := msg.Response.Scenario; step != nil && step.State != "" {
+		state, ok := states[step.Name]
+		if !ok {
+			state = ScenarioStarted
+		}
+		if state != step.State {
+			mismatches = append(mismatches, Mismatch{Field: "scenario." + step.Name, Expected: step.State, Actual: state})
+		}
+	}
+
+	return mismatches
+}
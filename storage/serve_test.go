@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestServe(t *testing.T) {
+	queues := NewQueues()
+	responses := queues.Responses
+	for _, msg := range [...]Message{
+		{Body: "expired", ExpiresAt: time.Now().Add(-time.Second), Response: &Response{Status: 200}},
+		{Body: "post", Response: &Response{Status: 201, Match: &Matcher{Method: "POST"}}},
+		{Body: "any", Response: &Response{Status: 200}},
+		{Body: "get", Response: &Response{Status: 200, Match: &Matcher{Method: "GET"}}},
+	} {
+		if err := responses.PushLast(msg); err != nil {
+			t.Fatalf("PushLast: %v", err)
+		}
+	}
+	request := Message{Request: &Request{Method: "GET", Url: "/"}}
+
+	for _, want := range []string{"any", "get"} {
+		res, unmatched := queues.Serve(request)
+		if res == nil || res.Body != want || unmatched != nil {
+			t.Errorf("unexpected served response %#v, want %v", res, want)
+		}
+	}
+
+	res, unmatched := queues.Serve(request)
+	if res != nil {
+		t.Errorf("unexpected served response %#v", res)
+	}
+	want := &Unmatched{Candidates: []Candidate{{
+		Index:      1,
+		Status:     201,
+		Match:      Matcher{Method: "POST"},
+		Mismatches: []Mismatch{{Field: "method", Expected: "POST", Actual: "GET"}},
+	}}}
+	if !reflect.DeepEqual(unmatched, want) {
+		t.Errorf("mismatch unmatched:\n got: %#v\nwant: %#v", unmatched, want)
+	}
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"time"
+)
+
+// CreateSession adds isolated queues and returns their ID.
+func (q *Queues) CreateSession() string {
+	id := NewID()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.sessions == nil {
+		q.sessions = make(map[string]*Queues)
+	}
+	q.sessions[id] = q.newChild(scopeSessions, id)
+
+	return id
+}
+
+// LookupSession returns queues of the session and marks it as used.
+// Empty ID refers to the queues themselves.
+func (q *Queues) LookupSession(id string) (*Queues, bool) {
+	if id == "" {
+		return q, true
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	session, ok := q.sessions[id]
+	if ok {
+		session.touch()
+	}
+
+	return session, ok
+}
+
+func (q *Queues) Sessions() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ids := make([]string, 0, len(q.sessions))
+	for id := range q.sessions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+func (q *Queues) RemoveSession(id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	session, ok := q.sessions[id]
+	if !ok {
+		return false
+	}
+	q.mustJournal(journalRecord{Scope: session.scope, Op: opRemove})
+	delete(q.sessions, id)
+
+	return true
+}
+
+// ExpireSessions removes sessions unused for longer than idle and returns their IDs.
+func (q *Queues) ExpireSessions(idle time.Duration) []string {
+	now := time.Now()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var ids []string
+	for id, session := range q.sessions {
+		if session.idle(now) > idle {
+			q.mustJournal(journalRecord{Scope: session.scope, Op: opRemove})
+			delete(q.sessions, id)
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+// NewID returns random identifier.
+func NewID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueuesSessions(t *testing.T) {
+	queues := NewQueues()
+
+	if session, ok := queues.LookupSession(""); !ok || session != queues {
+		t.Errorf("empty session must refer to default queues")
+	}
+
+	id := queues.CreateSession()
+	if len(id) != 32 {
+		t.Errorf("unexpected session id: %v", id)
+	}
+	if other := queues.CreateSession(); other == id {
+		t.Errorf("session ids must be unique: %v", id)
+	}
+
+	session, ok := queues.LookupSession(id)
+	if !ok || session == queues {
+		t.Fatalf("LookupSession must find created session")
+	}
+	if err := session.Requests.PushLast(Message{Request: &Request{}}); err != nil {
+		t.Fatalf("PushLast: %v", err)
+	}
+	if list := queues.Requests.List(); len(list) != 0 {
+		t.Errorf("%#v must be empty", list)
+	}
+
+	if ids := queues.Sessions(); len(ids) != 2 {
+		t.Errorf("unexpected sessions: %#v", ids)
+	}
+
+	if !queues.RemoveSession(id) {
+		t.Errorf("RemoveSession must remove existing session")
+	}
+	if _, ok := queues.LookupSession(id); ok {
+		t.Errorf("LookupSession must not find removed session")
+	}
+	if queues.RemoveSession(id) {
+		t.Errorf("RemoveSession must not remove unknown session")
+	}
+}
+
+func TestQueuesExpireSessions(t *testing.T) {
+	queues := NewQueues()
+	stale := queues.CreateSession()
+	active := queues.CreateSession()
+
+	session, _ := queues.LookupSession(stale)
+	session.usedAt.Store(time.Now().Add(-time.Hour).UnixNano())
+
+	expired := queues.ExpireSessions(time.Minute)
+	if len(expired) != 1 || expired[0] != stale {
+		t.Errorf("unexpected expired sessions: %#v", expired)
+	}
+	if ids := queues.Sessions(); len(ids) != 1 || ids[0] != active {
+		t.Errorf("unexpected sessions: %#v", ids)
+	}
+}
@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"fmt"
+)
+
+// SnapshotVersion is incremented on incompatible changes of Snapshot layout.
+const SnapshotVersion = 1
+
+type Snapshot struct {
+	Version int `json:"version"`
+	QueuesSnapshot
+}
+
+// QueuesSnapshot is content of queues, Contract is document their contract is built from.
+type QueuesSnapshot struct {
+	Responses  []Message                 `json:"responses"`
+	Requests   []Message                 `json:"requests"`
+	Default    *Message                  `json:"default,omitempty"`
+	Scenarios  map[string]string         `json:"scenarios,omitempty"`
+	RateLimits []RateLimit               `json:"rateLimits,omitempty"`
+	Failures   []Message                 `json:"failures,omitempty"`
+	Deliveries []Delivery                `json:"deliveries,omitempty"`
+	Contract   string                    `json:"contract,omitempty"`
+	Hosts      map[string]QueuesSnapshot `json:"hosts,omitempty"`
+	Sessions   map[string]QueuesSnapshot `json:"sessions,omitempty"`
+}
+
+// Export returns content of the queues including hosts and sessions.
+func (q *Queues) Export() Snapshot {
+	return Snapshot{
+		Version:        SnapshotVersion,
+		QueuesSnapshot: q.export(),
+	}
+}
+
+func (q *Queues) export() QueuesSnapshot {
+	snapshot := QueuesSnapshot{
+		Responses: q.Responses.List(),
+		Requests:  q.Requests.List(),
+		Default:   q.Default(),
+	}
+	if scenarios := q.Scenarios(); len(scenarios) > 0 {
+		snapshot.Scenarios = scenarios
+	}
+	if limits := q.RateLimits(); len(limits) > 0 {
+		snapshot.RateLimits = limits
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.failures) > 0 {
+		snapshot.Failures = append([]Message(nil), q.failures...)
+	}
+	if len(q.deliveries) > 0 {
+		snapshot.Deliveries = append([]Delivery(nil), q.deliveries...)
+	}
+	if q.contract != nil {
+		snapshot.Contract = q.contract.Document()
+	}
+
+	if len(q.hosts) > 0 {
+		snapshot.Hosts = make(map[string]QueuesSnapshot, len(q.hosts))
+		for name, host := range q.hosts {
+			snapshot.Hosts[name] = host.export()
+		}
+	}
+	if len(q.sessions) > 0 {
+		snapshot.Sessions = make(map[string]QueuesSnapshot, len(q.sessions))
+		for id, session := range q.sessions {
+			snapshot.Sessions[id] = session.export()
+		}
+	}
+
+	return snapshot
+}
+
+// Import replaces content of the queues with the snapshot, its contracts are built by parseContract.
+// Nothing is changed if the snapshot is invalid.
+func (q *Queues) Import(snapshot Snapshot, parseContract ContractParser) error {
+	if snapshot.Version != SnapshotVersion {
+		return fmt.Errorf("snapshot version %d is not supported, must be %d", snapshot.Version, SnapshotVersion)
+	}
+
+	if err := NewQueues().load(snapshot.QueuesSnapshot, parseContract); err != nil {
+		return err
+	}
+
+	q.mustJournal(journalRecord{Scope: q.scope, Op: opReset})
+
+	return q.load(snapshot.QueuesSnapshot, parseContract)
+}
+
+// load replaces content of the queues with the snapshot. Nested queues are loaded aside and swapped in at once,
+// and the content is replaced while Serve is blocked, so requests never see the queues partially loaded.
+func (q *Queues) load(snapshot QueuesSnapshot, parseContract ContractParser) error {
+	hosts := make(map[string]*Queues, len(snapshot.Hosts))
+	for name, hostSnapshot := range snapshot.Hosts {
+		if name == "" || NormalizeHost(name) != name {
+			return fmt.Errorf("host %q must be normalized", name)
+		}
+		host := q.newChild(scopeHosts, name)
+		if err := host.load(hostSnapshot, parseContract); err != nil {
+			return fmt.Errorf("host %s: %w", name, err)
+		}
+		hosts[name] = host
+	}
+
+	sessions := make(map[string]*Queues, len(snapshot.Sessions))
+	for id, sessionSnapshot := range snapshot.Sessions {
+		if id == "" {
+			return fmt.Errorf("session id must not be empty")
+		}
+		session := q.newChild(scopeSessions, id)
+		if err := session.load(sessionSnapshot, parseContract); err != nil {
+			return fmt.Errorf("session %s: %w", id, err)
+		}
+		sessions[id] = session
+	}
+
+	for name := range snapshot.Scenarios {
+		if name == "" {
+			return fmt.Errorf("scenario name must not be empty")
+		}
+	}
+	for _, failure := range snapshot.Failures {
+		if err := requestValidator(failure); err != nil {
+			return fmt.Errorf("failures: %w", err)
+		}
+	}
+	var contract Contract
+	if snapshot.Contract != "" {
+		if parseContract == nil {
+			return fmt.Errorf("contract is not supported")
+		}
+		var err error
+		if contract, err = parseContract(snapshot.Contract); err != nil {
+			return fmt.Errorf("contract: %w", err)
+		}
+	}
+
+	q.serveMu.Lock()
+	defer q.serveMu.Unlock()
+
+	if err := q.Responses.Replace(snapshot.Responses); err != nil {
+		return fmt.Errorf("responses: %w", err)
+	}
+	if err := q.Requests.Replace(snapshot.Requests); err != nil {
+		return fmt.Errorf("requests: %w", err)
+	}
+	if err := q.SetDefault(snapshot.Default); err != nil {
+		return fmt.Errorf("default: %w", err)
+	}
+	if err := q.SetRateLimits(snapshot.RateLimits); err != nil {
+		return fmt.Errorf("rate limits: %w", err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.hosts = hosts
+	q.sessions = sessions
+	q.setScenariosLocked(snapshot.Scenarios)
+	q.failures = append([]Message(nil), snapshot.Failures...)
+	q.deliveries = append([]Delivery(nil), snapshot.Deliveries...)
+	q.contract = contract
+
+	return nil
+}
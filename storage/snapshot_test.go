@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestQueuesExportImport(t *testing.T) {
+	queues := NewQueues()
+	response := Message{
+		Delay:    time.Second,
+		Headers:  http.Header{"Content-Type": {"application/octet-stream"}},
+		Body:     "\x00\xff",
+		Response: &Response{Status: 200},
+	}
+	request := Message{
+		Body:    "Hello",
+		Request: &Request{Method: "GET", Url: "/", Host: "api.local"},
+	}
+	if err := queues.Responses.PushLast(response); err != nil {
+		t.Fatalf("PushLast: %v", err)
+	}
+	if err := queues.Host("api.local").Requests.PushLast(request); err != nil {
+		t.Fatalf("PushLast: %v", err)
+	}
+	id := queues.CreateSession()
+	session, _ := queues.LookupSession(id)
+	if err := session.Responses.PushLast(response); err != nil {
+		t.Fatalf("PushLast: %v", err)
+	}
+
+	data, err := json.Marshal(queues.Export())
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	imported := NewQueues()
+	imported.Host("other.local")
+	if err := imported.Import(snapshot, nil); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	if got := imported.Responses.List(); !reflect.DeepEqual(got, []Message{response}) {
+		t.Errorf("responses mismatch:\n got: %#v\nwant: %#v", got, []Message{response})
+	}
+	if got := imported.Hosts(); !reflect.DeepEqual(got, []string{"api.local"}) {
+		t.Errorf("unexpected hosts: %#v", got)
+	}
+	host, _ := imported.LookupHost("api.local")
+	if got := host.Requests.List(); !reflect.DeepEqual(got, []Message{request}) {
+		t.Errorf("requests mismatch:\n got: %#v\nwant: %#v", got, []Message{request})
+	}
+	importedSession, ok := imported.LookupSession(id)
+	if !ok {
+		t.Fatalf("session %s must be imported", id)
+	}
+	if got := importedSession.Responses.List(); !reflect.DeepEqual(got, []Message{response}) {
+		t.Errorf("session responses mismatch:\n got: %#v\nwant: %#v", got, []Message{response})
+	}
+}
+
+func TestQueuesImportInvalid(t *testing.T) {
+	queues := NewQueues()
+	if err := queues.Responses.PushLast(Message{Response: &Response{}}); err != nil {
+		t.Fatalf("PushLast: %v", err)
+	}
+
+	for name, snapshot := range map[string]Snapshot{
+		"version": {Version: SnapshotVersion + 1},
+		"requests": {Version: SnapshotVersion, QueuesSnapshot: QueuesSnapshot{
+			Requests: []Message{{Response: &Response{}}},
+		}},
+		"host": {Version: SnapshotVersion, QueuesSnapshot: QueuesSnapshot{
+			Hosts: map[string]QueuesSnapshot{"api.local": {Responses: []Message{{Request: &Request{}}}}},
+		}},
+	} {
+		if err := queues.Import(snapshot, nil); err == nil {
+			t.Errorf("Import %s must return error", name)
+		}
+	}
+
+	if list := queues.Responses.List(); len(list) != 1 {
+		t.Errorf("%#v must be left intact", list)
+	}
+}
+
+type documentContract string
+
+func (c documentContract) Validate(request Message) []Violation {
+	return nil
+}
+
+func (c documentContract) Document() string {
+	return string(c)
+}
+
+func TestQueuesExportImportAll(t *testing.T) {
+	queues := NewQueues()
+	for _, q := range []*Queues{queues, queues.Host("api.local")} {
+		if err := q.Responses.PushLast(Message{Body: "OK", Response: &Response{Status: 200}}); err != nil {
+			t.Fatalf("PushLast: %v", err)
+		}
+		if err := q.Requests.PushLast(Message{Request: &Request{Method: "GET", Url: "/"}}); err != nil {
+			t.Fatalf("PushLast: %v", err)
+		}
+		if err := q.SetDefault(&Message{Body: "Default", Response: &Response{Status: 404}}); err != nil {
+			t.Fatalf("SetDefault: %v", err)
+		}
+		q.SetScenarioState("order", "paid")
+		if err := q.SetRateLimits([]RateLimit{{Name: "api", Limit: 10, Period: time.Minute}}); err != nil {
+			t.Fatalf("SetRateLimits: %v", err)
+		}
+		if err := q.Fail(Message{Request: &Request{Method: "POST", Url: "/unmatched"}}); err != nil {
+			t.Fatalf("Fail: %v", err)
+		}
+		q.Deliver(Delivery{ID: "d-1", Method: "POST", URL: "http://hooks.local/", Status: 204, Attempts: 1, SentAt: time.Unix(1700000000, 0).UTC()})
+		q.SetContract(documentContract(`{"openapi": "3.0.3"}`))
+	}
+
+	data, err := json.Marshal(queues.Export())
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	imported := NewQueues()
+	parse := func(document string) (Contract, error) { return documentContract(document), nil }
+	if err := imported.Import(snapshot, parse); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if got, want := imported.Export(), queues.Export(); !reflect.DeepEqual(got, want) {
+		t.Errorf("snapshot mismatch:\n got: %#v\nwant: %#v", got, want)
+	}
+	host, _ := imported.LookupHost("api.local")
+	if got := host.Contract(); got != documentContract(`{"openapi": "3.0.3"}`) {
+		t.Errorf("unexpected contract: %#v", got)
+	}
+
+	if err := NewQueues().Import(snapshot, nil); err == nil {
+		t.Errorf("Import of contract must return error without parser")
+	}
+}
+
+func TestQueuesImportConcurrentServe(t *testing.T) {
+	snapshot := func(state string) Snapshot {
+		return Snapshot{Version: SnapshotVersion, QueuesSnapshot: QueuesSnapshot{
+			Responses: []Message{{Headers: http.Header{}, Response: &Response{
+				ID: state, Status: 200, Stub: true, Scenario: &ScenarioStep{Name: "flow", State: state},
+			}}},
+			Scenarios: map[string]string{"flow": state},
+		}}
+	}
+	queues := NewQueues()
+	if err := queues.Import(snapshot("A"), nil); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			if err := queues.Import(snapshot([...]string{"A", "B"}[i%2]), nil); err != nil {
+				t.Errorf("Import: %v", err)
+				return
+			}
+		}
+	}()
+
+	request := Message{Headers: http.Header{}, Request: &Request{Method: "GET", Url: "/"}}
+	for {
+		if served, unmatched := queues.Serve(request); served == nil {
+			t.Fatalf("request must be served by loaded stub: %#v", unmatched)
+		}
+		select {
+		case <-done:
+			return
+		default:
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	// SOAP11 is SOAP 1.1, its requests have SOAPAction header and text/xml content type.
+	SOAP11 = "1.1"
+	// SOAP12 is SOAP 1.2, its requests have action parameter of application/soap+xml content type.
+	SOAP12 = "1.2"
+)
+
+var soapEnvelopes = map[string]string{
+	SOAP11: "http://schemas.xmlsoap.org/soap/envelope/",
+	SOAP12: "http://www.w3.org/2003/05/soap-envelope",
+}
+
+// SOAPFault is compact description of SOAP Fault response.
+type SOAPFault struct {
+	// Version is SOAP11 if empty, or SOAP12.
+	Version string `json:"version,omitempty"`
+	// Code is qualified name like soap:Client, unprefixed one is qualified by envelope namespace,
+	// it is Server for SOAP 1.1 and Receiver for SOAP 1.2 if empty.
+	Code   string `json:"code,omitempty"`
+	String string `json:"string"`
+	// Detail is XML fragment put into detail element as is.
+	Detail string `json:"detail,omitempty"`
+}
+
+func (f SOAPFault) Validate() error {
+	if _, ok := soapEnvelopes[f.version()]; !ok {
+		return fmt.Errorf("soap version %q must be %s or %s", f.Version, SOAP11, SOAP12)
+	}
+	if f.String == "" {
+		return fmt.Errorf("soap fault string must not be empty")
+	}
+	if strings.ContainsAny(f.Code, "<>&\"' ") {
+		return fmt.Errorf("soap fault code %q must be qualified name", f.Code)
+	}
+	if _, err := parseXML("<detail>" + f.Detail + "</detail>"); err != nil {
+		return fmt.Errorf("soap fault detail must be XML fragment: %w", err)
+	}
+
+	return nil
+}
+
+func (f SOAPFault) version() string {
+	if f.Version == "" {
+		return SOAP11
+	}
+
+	return f.Version
+}
+
+// ContentType returns content type of SOAP message of the fault version.
+func (f SOAPFault) ContentType() string {
+	if f.version() == SOAP12 {
+		return "application/soap+xml; charset=utf-8"
+	}
+
+	return "text/xml; charset=utf-8"
+}
+
+// Envelope returns SOAP envelope with the fault in its body.
+func (f SOAPFault) Envelope() string {
+	code := f.Code
+	switch {
+	case code == "" && f.version() == SOAP12:
+		code = "soap:Receiver"
+	case code == "":
+		code = "soap:Server"
+	case !strings.Contains(code, ":"):
+		code = "soap:" + code
+	}
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n")
+	fmt.Fprintf(&b, `<soap:Envelope xmlns:soap="%s">`+"\n", soapEnvelopes[f.version()])
+	b.WriteString("  <soap:Body>\n    <soap:Fault>\n")
+	if f.version() == SOAP12 {
+		fmt.Fprintf(&b, "      <soap:Code><soap:Value>%s</soap:Value></soap:Code>\n", code)
+		fmt.Fprintf(&b, `      <soap:Reason><soap:Text xml:lang="en">%s</soap:Text></soap:Reason>`+"\n", xmlEscape(f.String))
+		if f.Detail != "" {
+			fmt.Fprintf(&b, "      <soap:Detail>%s</soap:Detail>\n", f.Detail)
+		}
+	} else {
+		fmt.Fprintf(&b, "      <faultcode>%s</faultcode>\n", code)
+		fmt.Fprintf(&b, "      <faultstring>%s</faultstring>\n", xmlEscape(f.String))
+		if f.Detail != "" {
+			fmt.Fprintf(&b, "      <detail>%s</detail>\n", f.Detail)
+		}
+	}
+	b.WriteString("    </soap:Fault>\n  </soap:Body>\n</soap:Envelope>\n")
+
+	return b.String()
+}
+
+// soapAction returns action of SOAP request from SOAPAction header of SOAP 1.1,
+// or action parameter of SOAP 1.2 content type, without quotes. It is empty if there is none.
+func soapAction(headers http.Header) string {
+	if values := headers.Values("SOAPAction"); len(values) > 0 {
+		return strings.Trim(values[0], `"`)
+	}
+	if _, params, err := mime.ParseMediaType(headers.Get("Content-Type")); err == nil {
+		return params["action"]
+	}
+
+	return ""
+}
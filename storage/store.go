@@ -1,25 +1,121 @@
 package storage
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"sync"
 	"time"
 )
 
 type Request struct {
-	Method string
-	Url    string
+	ID     string `json:"id,omitempty"`
+	Method string `json:"method"`
+	Url    string `json:"url"`
+	Host   string `json:"host"`
+	// RawHost is Host header as received, Host is its normalized form without port.
+	RawHost string `json:"rawHost,omitempty"`
+
+	ReceivedAt time.Time `json:"receivedAt"`
+	RemoteAddr string    `json:"remoteAddr,omitempty"`
+	Proto      string    `json:"proto,omitempty"`
+	TLS        *TLS      `json:"tls,omitempty"`
+	// ContentLength is the declared length of body, -1 if unknown.
+	ContentLength    int64       `json:"contentLength"`
+	TransferEncoding []string    `json:"transferEncoding,omitempty"`
+	Trailer          http.Header `json:"trailer,omitempty"`
+
+	// Served is set if a response was served for the request.
+	Served *Served `json:"served,omitempty"`
+	// Unmatched is set if no pending response was served for the request.
+	Unmatched *Unmatched `json:"unmatched,omitempty"`
+	// Violations of contract of the queues the request was received by.
+	Violations []Violation `json:"violations,omitempty"`
+}
+
+// TLS describes connection the request was received over.
+type TLS struct {
+	Version            string `json:"version"`
+	CipherSuite        string `json:"cipherSuite"`
+	ServerName         string `json:"serverName,omitempty"`
+	NegotiatedProtocol string `json:"negotiatedProtocol,omitempty"`
+}
+
+// Served describes response served for the request.
+type Served struct {
+	ResponseID string        `json:"responseId,omitempty"`
+	Status     int           `json:"status"`
+	Delay      time.Duration `json:"delay,omitempty"`
+	Headers    http.Header   `json:"headers,omitempty"`
+	Body       []byte        `json:"body,omitempty"`
+	// Latency is time from receiving the request to sending the response, including its delay.
+	Latency time.Duration `json:"latency"`
 }
+
 type Response struct {
-	Status int
+	ID     string `json:"id,omitempty"`
+	Status int    `json:"status"`
+	// Match restricts requests the response is served for, it is served for any request if nil.
+	Match *Matcher `json:"match,omitempty"`
+	// Stub stays in the queue after it is served.
+	Stub     bool          `json:"stub,omitempty"`
+	Scenario *ScenarioStep `json:"scenario,omitempty"`
+	// Callbacks are sent after the response is served.
+	Callbacks []Callback `json:"callbacks,omitempty"`
+	// Variants replace content of the response, one of them is picked for each request.
+	Variants []Variant `json:"variants,omitempty"`
+	// Pick is how variants are picked, PickRandom if empty.
+	Pick string `json:"pick,omitempty"`
+	// Seed makes random picks reproducible, they are seeded by current time if nil.
+	Seed *int64 `json:"seed,omitempty"`
 }
 type Message struct {
-	Delay time.Duration
+	Delay time.Duration `json:"delay,omitempty"`
+
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"-"`
+
+	// ExpiresAt is the time the message is removed at, it never expires if zero.
+	ExpiresAt time.Time `json:"-"`
+
+	Request  *Request  `json:"request,omitempty"`
+	Response *Response `json:"response,omitempty"`
+}
 
-	Headers http.Header
-	Body    string
+type messageJSON struct {
+	message
+	// Body is base64 encoded to keep binary data intact.
+	Body      []byte     `json:"body,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+type message Message
+
+func (m Message) MarshalJSON() ([]byte, error) {
+	v := messageJSON{message: message(m), Body: []byte(m.Body)}
+	if !m.ExpiresAt.IsZero() {
+		v.ExpiresAt = &m.ExpiresAt
+	}
+
+	return json.Marshal(v)
+}
+
+func (m *Message) UnmarshalJSON(data []byte) error {
+	var v messageJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*m = Message(v.message)
+	m.Body = string(v.Body)
+	if v.ExpiresAt != nil {
+		m.ExpiresAt = *v.ExpiresAt
+	}
+
+	return nil
+}
 
-	Request  *Request
-	Response *Response
+// IsExpired reports whether the message must be removed at now.
+func (m Message) IsExpired(now time.Time) bool {
+	return !m.ExpiresAt.IsZero() && !now.Before(m.ExpiresAt)
 }
 
 func (m Message) IsRequest() bool {
@@ -31,8 +127,13 @@ func (m Message) IsResponse() bool {
 }
 
 type store struct {
+	mu        sync.Mutex
 	items     []*Message
 	validator func(Message) error
+	limit     func() Limit
+	evicted   int
+	rejected  int
+	expired   int
 }
 
 type Store interface {
@@ -40,13 +141,42 @@ type Store interface {
 	PopFirst() *Message
 	List() []Message
 	Clear()
+	// Replace validates all messages and only then substitutes store content with them, ignoring limit.
+	Replace(messages []Message) error
+	// Remove deletes messages matching fn and returns them.
+	Remove(match func(Message) bool) []Message
+	// Expire removes messages expired at now and returns them.
+	Expire(now time.Time) []Message
+	Stats() Stats
+}
+
+func (s *store) validate(message Message) error {
+	if s.validator == nil {
+		return nil
+	}
+
+	return s.validator(message)
 }
 
 func (s *store) PushLast(message Message) error {
-	if s.validator != nil {
-		if err := s.validator(message); err != nil {
-			return err
-		}
+	if err := s.validate(message); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	overflow, excess := s.overflow(len(s.items))
+	switch overflow {
+	case OverflowReject:
+		s.rejected++
+		return fmt.Errorf("%w: capacity is %d", ErrQueueFull, s.limit().Capacity)
+	case OverflowDropNewest:
+		s.evicted++
+		return nil
+	case OverflowDropOldest:
+		s.items = s.items[excess:]
+		s.evicted += excess
 	}
 
 	s.items = append(s.items, &message)
@@ -54,7 +184,24 @@ func (s *store) PushLast(message Message) error {
 	return nil
 }
 
+// overflow returns policy to apply when one more message does not fit the store of length,
+// and how many messages exceed the capacity.
+func (s *store) overflow(length int) (string, int) {
+	if s.limit == nil {
+		return "", 0
+	}
+	limit := s.limit()
+	if limit.Capacity <= 0 || length < limit.Capacity {
+		return "", 0
+	}
+
+	return limit.Overflow, length - limit.Capacity + 1
+}
+
 func (s *store) PopFirst() *Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	if len(s.items) <= 0 {
 		return nil
 	}
@@ -65,6 +212,9 @@ func (s *store) PopFirst() *Message {
 	return item
 }
 func (s *store) List() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	res := make([]Message, len(s.items))
 	for i, mes := range s.items {
 		res[i] = *mes
@@ -73,10 +223,129 @@ func (s *store) List() []Message {
 	return res
 }
 
+func (s *store) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.items)
+}
+
 func (s *store) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	s.items = nil
 }
 
+func (s *store) Replace(messages []Message) error {
+	items := make([]*Message, len(messages))
+	for i := range messages {
+		if err := s.validate(messages[i]); err != nil {
+			return err
+		}
+		message := messages[i]
+		items[i] = &message
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items = items
+
+	return nil
+}
+
+func (s *store) Remove(match func(Message) bool) []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.removeAtLocked(s.findLocked(match))
+}
+
+func (s *store) Expire(now time.Time) []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := s.removeAtLocked(s.findLocked(func(msg Message) bool {
+		return msg.IsExpired(now)
+	}))
+	s.expired += len(removed)
+
+	return removed
+}
+
+func (s *store) addExpired(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expired += n
+}
+
+// find returns ascending indices of messages matching fn.
+func (s *store) find(match func(Message) bool) []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.findLocked(match)
+}
+
+func (s *store) findLocked(match func(Message) bool) []int {
+	var indices []int
+	for i, item := range s.items {
+		if match(*item) {
+			indices = append(indices, i)
+		}
+	}
+
+	return indices
+}
+
+// removeAt deletes messages by ascending indices and returns them.
+func (s *store) removeAt(indices []int) []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.removeAtLocked(indices)
+}
+
+func (s *store) removeAtLocked(indices []int) []Message {
+	if len(indices) == 0 {
+		return nil
+	}
+
+	removed := make([]Message, 0, len(indices))
+	items := make([]*Message, 0, len(s.items)-len(indices))
+	next := 0
+	for i, item := range s.items {
+		if next < len(indices) && indices[next] == i {
+			removed = append(removed, *item)
+			next++
+			continue
+		}
+		items = append(items, item)
+	}
+	s.items = items
+
+	return removed
+}
+
+func (s *store) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := Stats{
+		Length:   len(s.items),
+		Evicted:  s.evicted,
+		Rejected: s.rejected,
+		Expired:  s.expired,
+	}
+	if s.limit != nil {
+		stats.Limit = s.limit()
+	}
+
+	return stats
+}
+
 func NewStore(validator func(Message) error) Store {
 	return &store{validator: validator}
 }
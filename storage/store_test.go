@@ -3,6 +3,7 @@ package storage
 import (
 	"strconv"
 	"testing"
+	"time"
 )
 
 func TestNewStoreEmpty(t *testing.T) {
@@ -137,3 +138,49 @@ func TestStoreLIFO(t *testing.T) {
 		}
 	}
 }
+
+func TestStoreRemove(t *testing.T) {
+	store := NewStore(nil)
+	for i := 0; i < 5; i++ {
+		if err := store.PushLast(Message{Body: strconv.Itoa(i)}); err != nil {
+			t.Fatalf("PushLast: %v", err)
+		}
+	}
+
+	removed := store.Remove(func(msg Message) bool {
+		return msg.Body == "1" || msg.Body == "3"
+	})
+	if len(removed) != 2 || removed[0].Body != "1" || removed[1].Body != "3" {
+		t.Errorf("unexpected removed: %#v", removed)
+	}
+
+	list := store.List()
+	if len(list) != 3 || list[0].Body != "0" || list[1].Body != "2" || list[2].Body != "4" {
+		t.Errorf("unexpected list: %#v", list)
+	}
+}
+
+func TestStoreExpire(t *testing.T) {
+	now := time.Now()
+	store := NewStore(nil)
+	for _, msg := range []Message{
+		{Body: "forever"},
+		{Body: "expired", ExpiresAt: now.Add(-time.Second)},
+		{Body: "expires now", ExpiresAt: now},
+		{Body: "fresh", ExpiresAt: now.Add(time.Second)},
+	} {
+		if err := store.PushLast(msg); err != nil {
+			t.Fatalf("PushLast: %v", err)
+		}
+	}
+
+	if removed := store.Expire(now); len(removed) != 2 {
+		t.Errorf("unexpected removed: %#v", removed)
+	}
+	if list := store.List(); len(list) != 2 || list[0].Body != "forever" || list[1].Body != "fresh" {
+		t.Errorf("unexpected list: %#v", list)
+	}
+	if stats := store.Stats(); stats.Expired != 2 {
+		t.Errorf("unexpected stats: %#v", stats)
+	}
+}
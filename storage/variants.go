@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+const (
+	// PickRandom picks variants randomly in proportion to their weights.
+	PickRandom = "random"
+	// PickRoundRobin picks variants in turn.
+	PickRoundRobin = "roundRobin"
+)
+
+// Variant is alternative content of the response.
+type Variant struct {
+	// Weight is relative chance of the variant to be picked randomly, 1 if zero.
+	Weight int           `json:"weight,omitempty"`
+	Status int           `json:"status"`
+	Delay  time.Duration `json:"delay,omitempty"`
+	// Headers are added to the response ones replacing values of the same names.
+	Headers http.Header `json:"headers,omitempty"`
+	Body    []byte      `json:"body,omitempty"`
+}
+
+func (v Variant) Validate() error {
+	if v.Status < 100 || v.Status >= 600 {
+		return fmt.Errorf("variant status %d must be in [100; 600)", v.Status)
+	}
+	if v.Weight < 0 {
+		return fmt.Errorf("variant weight must not be negative")
+	}
+	if v.Delay < 0 {
+		return fmt.Errorf("variant delay must not be negative")
+	}
+
+	return nil
+}
+
+func (v Variant) weight() int {
+	if v.Weight == 0 {
+		return 1
+	}
+
+	return v.Weight
+}
+
+// apply returns the response with content of the variant.
+func (v Variant) apply(msg Message) Message {
+	res := *msg.Response
+	res.Status = v.Status
+	msg.Response = &res
+
+	headers := msg.Headers.Clone()
+	if headers == nil {
+		headers = make(http.Header, len(v.Headers))
+	}
+	for name, values := range v.Headers {
+		headers[name] = values
+	}
+	msg.Headers = headers
+	msg.Body = string(v.Body)
+	if v.Delay > 0 {
+		msg.Delay = v.Delay
+	}
+
+	return msg
+}
+
+func validatePick(pick string) error {
+	switch pick {
+	case "", PickRandom, PickRoundRobin:
+		return nil
+	default:
+		return fmt.Errorf("pick %q must be %s or %s", pick, PickRandom, PickRoundRobin)
+	}
+}
+
+// picker keeps state of picking variants of a response between requests.
+type picker struct {
+	rand *rand.Rand
+	next int
+}
+
+func newPicker(res Response) *picker {
+	seed := time.Now().UnixNano()
+	if res.Seed != nil {
+		seed = *res.Seed
+	}
+
+	return &picker{rand: rand.New(rand.NewSource(seed))}
+}
+
+func (p *picker) pick(res Response) Variant {
+	if res.Pick == PickRoundRobin {
+		variant := res.Variants[p.next%len(res.Variants)]
+		p.next++
+		return variant
+	}
+
+	total := 0
+	for _, variant := range res.Variants {
+		total += variant.weight()
+	}
+	n := p.rand.Intn(total)
+	for _, variant := range res.Variants {
+		if n -= variant.weight(); n < 0 {
+			return variant
+		}
+	}
+
+	return res.Variants[len(res.Variants)-1]
+}
+
+// ServeDefault returns default response with content of its next variant, it is nil if not set.
+func (q *Queues) ServeDefault() *Message {
+	q.serveMu.Lock()
+	defer q.serveMu.Unlock()
+
+	msg := q.Default()
+	if msg == nil {
+		return nil
+	}
+	picked := q.pickVariant(*msg)
+
+	return &picked
+}
+
+// pickVariant returns the response with content of its next variant, the response is returned as is if it has no variants.
+// Pickers are kept while their responses are pending or default, so the sequence continues between requests.
+// It requires serveMu.
+func (q *Queues) pickVariant(msg Message) Message {
+	res := *msg.Response
+	if len(res.Variants) == 0 {
+		return msg
+	}
+
+	p, ok := q.pickers[res.ID]
+	if !ok {
+		kept := make(map[string]*picker)
+		responses := q.Responses.List()
+		if fallback := q.Default(); fallback != nil {
+			responses = append(responses, *fallback)
+		}
+		for _, pending := range responses {
+			if p, ok := q.pickers[pending.Response.ID]; ok {
+				kept[pending.Response.ID] = p
+			}
+		}
+		p = newPicker(res)
+		kept[res.ID] = p
+		q.pickers = kept
+	}
+
+	return p.pick(res).apply(msg)
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func servedStatuses(t *testing.T, queues *Queues, count int) []int {
+	t.Helper()
+
+	var statuses []int
+	for i := 0; i < count; i++ {
+		res, _ := queues.Serve(Message{Request: &Request{Method: "GET", Url: "/"}})
+		if res == nil {
+			t.Fatalf("response must be served")
+		}
+		statuses = append(statuses, res.Response.Status)
+	}
+
+	return statuses
+}
+
+func TestQueuesServeVariants(t *testing.T) {
+	variants := []Variant{
+		{Status: 200, Weight: 9, Body: []byte("ok")},
+		{Status: 503, Weight: 1, Headers: http.Header{"Retry-After": {"1"}}},
+	}
+
+	t.Run("round robin", func(t *testing.T) {
+		queues := NewQueues()
+		if err := queues.Responses.PushLast(Message{
+			Headers:  http.Header{"Content-Type": {"text/plain"}},
+			Response: &Response{ID: "stub", Status: 200, Stub: true, Variants: variants, Pick: PickRoundRobin},
+		}); err != nil {
+			t.Fatalf("PushLast: %v", err)
+		}
+
+		if got, want := servedStatuses(t, queues, 5), []int{200, 503, 200, 503, 200}; !reflect.DeepEqual(got, want) {
+			t.Errorf("unexpected statuses %v, want %v", got, want)
+		}
+		res, _ := queues.Serve(Message{Request: &Request{Method: "GET", Url: "/"}})
+		want := http.Header{"Content-Type": {"text/plain"}, "Retry-After": {"1"}}
+		if res == nil || !reflect.DeepEqual(res.Headers, want) || res.Body != "" {
+			t.Errorf("variant headers must be added to response ones: %#v", res)
+		}
+	})
+
+	t.Run("seeded random", func(t *testing.T) {
+		seed := int64(42)
+		var sequences [2][]int
+		for i := range sequences {
+			queues := NewQueues()
+			if err := queues.Responses.PushLast(Message{Response: &Response{ID: "stub", Status: 200, Stub: true, Variants: variants, Seed: &seed}}); err != nil {
+				t.Fatalf("PushLast: %v", err)
+			}
+			sequences[i] = servedStatuses(t, queues, 1000)
+		}
+		if !reflect.DeepEqual(sequences[0], sequences[1]) {
+			t.Errorf("sequences with the same seed must be equal")
+		}
+
+		failed := 0
+		for _, status := range sequences[0] {
+			if status == 503 {
+				failed++
+			}
+		}
+		if failed < 50 || failed > 150 {
+			t.Errorf("unexpected count %d of 503 in 1000 picks weighted 1 of 10", failed)
+		}
+	})
+
+	t.Run("default", func(t *testing.T) {
+		queues := NewQueues()
+		if err := queues.SetDefault(&Message{Response: &Response{ID: "default", Status: 200, Variants: variants, Pick: PickRoundRobin}}); err != nil {
+			t.Fatalf("SetDefault: %v", err)
+		}
+		var got []int
+		for i := 0; i < 3; i++ {
+			got = append(got, queues.ServeDefault().Response.Status)
+		}
+		if want := []int{200, 503, 200}; !reflect.DeepEqual(got, want) {
+			t.Errorf("unexpected statuses %v, want %v", got, want)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		queues := NewQueues()
+		for _, res := range [...]Response{
+			{Status: 200, Variants: []Variant{{Status: 99}}},
+			{Status: 200, Variants: []Variant{{Status: 200, Weight: -1}}},
+			{Status: 200, Variants: variants, Pick: "first"},
+		} {
+			res := res
+			if err := queues.Responses.PushLast(Message{Response: &res}); err == nil {
+				t.Errorf("response %#v must be invalid", res)
+			}
+		}
+	})
+}
@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// XPathCondition checks nodes selected by Path in request body parsed as XML,
+// values of elements are their text trimmed of surrounding whitespace.
+// Without operators it requires the nodes to exist, otherwise any of them must satisfy the condition.
+type XPathCondition struct {
+	Path   string  `json:"path"`
+	Equals *string `json:"equals,omitempty"`
+	// Exists requires the nodes to be present if true, or absent if false.
+	Exists *bool `json:"exists,omitempty"`
+	// Matches is regular expression the value must match.
+	Matches string `json:"matches,omitempty"`
+}
+
+func (c XPathCondition) Validate(namespaces map[string]string) error {
+	if _, err := parseXPath(c.Path, namespaces); err != nil {
+		return err
+	}
+	if c.Matches != "" {
+		if _, err := compileRegexp(c.Matches); err != nil {
+			return fmt.Errorf("xpath %q matches %q: %w", c.Path, c.Matches, err)
+		}
+	}
+	if c.Exists != nil && !*c.Exists && (c.Equals != nil || c.Matches != "") {
+		return fmt.Errorf("xpath %q must not exist, so it can not have other operators", c.Path)
+	}
+
+	return nil
+}
+
+// expected describes the condition, e.g. "exists and ~^2".
+func (c XPathCondition) expected() string {
+	var parts []string
+	if c.Equals != nil {
+		parts = append(parts, fmt.Sprintf("%q", *c.Equals))
+	}
+	if c.Matches != "" {
+		parts = append(parts, "~"+c.Matches)
+	}
+	if len(parts) == 0 {
+		return "exists"
+	}
+
+	return strings.Join(parts, " and ")
+}
+
+// explain returns mismatch of the condition in parsed XML document, it is nil if the condition is satisfied.
+func (c XPathCondition) explain(doc *xmlNode, namespaces map[string]string) *Mismatch {
+	field := "xpath." + c.Path
+	p, err := parseXPath(c.Path, namespaces)
+	if err != nil {
+		return &Mismatch{Field: field, Expected: c.expected(), Actual: err.Error()}
+	}
+
+	nodes := p.find(doc)
+	if c.Exists != nil && !*c.Exists {
+		if len(nodes) > 0 {
+			return &Mismatch{Field: field, Expected: "<missing>", Actual: abbreviate(strings.TrimSpace(nodes[0].value()))}
+		}
+		return nil
+	}
+	if len(nodes) == 0 {
+		return &Mismatch{Field: field, Expected: c.expected(), Actual: "<missing>"}
+	}
+
+	values := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		value := strings.TrimSpace(node.value())
+		if c.satisfiedBy(value) {
+			return nil
+		}
+		values = append(values, value)
+	}
+
+	return &Mismatch{Field: field, Expected: c.expected(), Actual: abbreviate(strings.Join(values, ", "))}
+}
+
+func (c XPathCondition) satisfiedBy(value string) bool {
+	if c.Equals != nil && value != *c.Equals {
+		return false
+	}
+	if c.Matches != "" {
+		re, err := compileRegexp(c.Matches)
+		if err != nil || !re.MatchString(value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (m Matcher) explainXML(body string) []Mismatch {
+	doc, err := parseXML(body)
+	if err != nil {
+		return []Mismatch{{Field: "body", Expected: "XML", Actual: err.Error()}}
+	}
+
+	var mismatches []Mismatch
+	for _, condition := range m.XPath {
+		if mismatch := condition.explain(doc, m.XMLNamespaces); mismatch != nil {
+			mismatches = append(mismatches, *mismatch)
+		}
+	}
+	if m.XMLEquals != "" {
+		expected, err := parseXML(m.XMLEquals)
+		if err != nil {
+			return append(mismatches, Mismatch{Field: "xml", Expected: "XML", Actual: err.Error()})
+		}
+		mismatches = append(mismatches, xmlDiff("xml", expected.significant()[0], doc.significant()[0])...)
+	}
+
+	return mismatches
+}
+
+// xmlDiff returns differences of actual element from expected one, the field is path of their parent.
+// Elements are compared by namespace URIs and local names rather than prefixes, attributes regardless of order,
+// and texts trimmed of whitespace, whitespace-only texts and comments are skipped.
+func xmlDiff(field string, expected, actual *xmlNode) []Mismatch {
+	if expected.name != actual.name {
+		return []Mismatch{{Field: field, Expected: "<" + xmlString(expected.name) + ">", Actual: "<" + xmlString(actual.name) + ">"}}
+	}
+	field += "/" + expected.name.Local
+
+	var mismatches []Mismatch
+	attrs := make(map[string][2]*xmlNode)
+	for _, attr := range expected.attrs {
+		pair := attrs[xmlString(attr.name)]
+		pair[0] = attr
+		attrs[xmlString(attr.name)] = pair
+	}
+	for _, attr := range actual.attrs {
+		pair := attrs[xmlString(attr.name)]
+		pair[1] = attr
+		attrs[xmlString(attr.name)] = pair
+	}
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pair := attrs[name]
+		e, a := "<missing>", "<missing>"
+		if pair[0] != nil {
+			e = pair[0].text
+		}
+		if pair[1] != nil {
+			a = pair[1].text
+		}
+		if pair[0] == nil || pair[1] == nil || e != a {
+			mismatches = append(mismatches, Mismatch{Field: field + "/@" + name, Expected: e, Actual: a})
+		}
+	}
+
+	expectedChildren, actualChildren := expected.significant(), actual.significant()
+	if len(expectedChildren) != len(actualChildren) {
+		return append(mismatches, Mismatch{Field: field, Expected: xmlOutline(expectedChildren), Actual: xmlOutline(actualChildren)})
+	}
+	for i, e := range expectedChildren {
+		a := actualChildren[i]
+		switch {
+		case e.kind != a.kind:
+			mismatches = append(mismatches, Mismatch{Field: field, Expected: xmlOutline(expectedChildren), Actual: xmlOutline(actualChildren)})
+		case e.kind == xmlText:
+			if strings.TrimSpace(e.text) != strings.TrimSpace(a.text) {
+				mismatches = append(mismatches, Mismatch{Field: field + "/text()", Expected: abbreviate(strings.TrimSpace(e.text)), Actual: abbreviate(strings.TrimSpace(a.text))})
+			}
+		default:
+			mismatches = append(mismatches, xmlDiff(field, e, a)...)
+		}
+	}
+
+	return mismatches
+}
+
+// xmlOutline lists child nodes like <id>, text().
+func xmlOutline(nodes []*xmlNode) string {
+	outline := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		if node.kind == xmlText {
+			outline = append(outline, "text()")
+		} else {
+			outline = append(outline, "<"+xmlString(node.name)+">")
+		}
+	}
+	if len(outline) == 0 {
+		return "<empty>"
+	}
+
+	return strings.Join(outline, ", ")
+}
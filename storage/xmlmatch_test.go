@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+const soapRequest = `<?xml version="1.0" encoding="utf-8"?>
+<!-- generated by client -->
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" xmlns:u="http://example.com/users">
+  <s:Header><u:Auth token="secret"/></s:Header>
+  <s:Body>
+    <u:GetUser version="2">
+      <u:id> 42 </u:id>
+      <u:fields><u:field>name</u:field><u:field>email</u:field></u:fields>
+    </u:GetUser>
+  </s:Body>
+</s:Envelope>`
+
+func TestMatcherExplainXML(t *testing.T) {
+	msg := Message{
+		Headers: http.Header{"Content-Type": {"text/xml; charset=utf-8"}, "Soapaction": {`"http://example.com/users/GetUser"`}},
+		Body:    soapRequest,
+		Request: &Request{Method: "POST", Url: "/soap"},
+	}
+	namespaces := map[string]string{"soap": "http://schemas.xmlsoap.org/soap/envelope/", "u": "http://example.com/users"}
+	value := func(s string) *string { return &s }
+	yes, no := true, false
+
+	for _, tt := range [...]struct {
+		name    string
+		matcher Matcher
+		want    []Mismatch
+	}{
+		{
+			name: "xpath",
+			matcher: Matcher{
+				SOAPAction:    "http://example.com/users/GetUser",
+				XMLNamespaces: namespaces,
+				XPath: []XPathCondition{
+					{Path: "/soap:Envelope/soap:Body/u:GetUser"},
+					{Path: "//GetUser/id", Equals: value("42")},
+					{Path: "//u:GetUser[@version='2']/u:id/text()", Matches: `^\s*\d+\s*$`},
+					{Path: "//fields/field[2]", Equals: value("email")},
+					{Path: "//fields/*", Equals: value("name")},
+					{Path: "//Auth/@token", Equals: value("secret"), Exists: &yes},
+					{Path: "//GetUser[id='42']"},
+					{Path: "//GetUser[@locale]", Exists: &no},
+				},
+			},
+		},
+		{
+			name: "xpath mismatch",
+			matcher: Matcher{
+				SOAPAction:    "http://example.com/users/DeleteUser",
+				XMLNamespaces: namespaces,
+				XPath: []XPathCondition{
+					{Path: "/soap:Envelope/soap:Body/u:DeleteUser"},
+					{Path: "//soap:GetUser"},
+					{Path: "//fields/field", Equals: value("phone")},
+					{Path: "//GetUser/@version", Matches: "^1"},
+					{Path: "//Auth", Exists: &no},
+				},
+			},
+			want: []Mismatch{
+				{Field: "soapAction", Expected: "http://example.com/users/DeleteUser", Actual: "http://example.com/users/GetUser"},
+				{Field: "xpath./soap:Envelope/soap:Body/u:DeleteUser", Expected: "exists", Actual: "<missing>"},
+				{Field: "xpath.//soap:GetUser", Expected: "exists", Actual: "<missing>"},
+				{Field: "xpath.//fields/field", Expected: `"phone"`, Actual: "name, email"},
+				{Field: "xpath.//GetUser/@version", Expected: "~^1", Actual: "2"},
+				{Field: "xpath.//Auth", Expected: "<missing>", Actual: ""},
+			},
+		},
+		{
+			name: "equals regardless of prefixes, attribute order, and formatting",
+			matcher: Matcher{XMLEquals: `<Envelope xmlns="http://schemas.xmlsoap.org/soap/envelope/">
+				<Header><Auth xmlns="http://example.com/users" token="secret"></Auth></Header>
+				<Body><x:GetUser xmlns:x="http://example.com/users" version="2"><x:id>42</x:id>
+				<x:fields><x:field>name</x:field><x:field>email</x:field></x:fields></x:GetUser></Body>
+			</Envelope>`},
+		},
+		{
+			name: "equals mismatch",
+			matcher: Matcher{XMLEquals: `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" xmlns:u="http://example.com/users">
+				<s:Header><u:Auth token="other" scope="read"/></s:Header>
+				<s:Body><u:GetUser version="2"><u:id>7</u:id><u:fields><u:field>name</u:field></u:fields></u:GetUser></s:Body>
+			</s:Envelope>`},
+			want: []Mismatch{
+				{Field: "xml/Envelope/Header/Auth/@scope", Expected: "read", Actual: "<missing>"},
+				{Field: "xml/Envelope/Header/Auth/@token", Expected: "other", Actual: "secret"},
+				{Field: "xml/Envelope/Body/GetUser/id/text()", Expected: "7", Actual: "42"},
+				{Field: "xml/Envelope/Body/GetUser/fields", Expected: "<{http://example.com/users}field>", Actual: "<{http://example.com/users}field>, <{http://example.com/users}field>"},
+			},
+		},
+		{
+			name:    "equals namespace mismatch",
+			matcher: Matcher{XMLEquals: `<Envelope xmlns="http://www.w3.org/2003/05/soap-envelope"/>`},
+			want: []Mismatch{
+				{Field: "xml", Expected: "<{http://www.w3.org/2003/05/soap-envelope}Envelope>", Actual: "<{http://schemas.xmlsoap.org/soap/envelope/}Envelope>"},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.matcher.Validate(); err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+			got := tt.matcher.Explain(msg)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mismatches:\n got: %#v\nwant: %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatcherExplainXMLInvalid(t *testing.T) {
+	matcher := Matcher{SOAPAction: "urn:GetUser", XPath: []XPathCondition{{Path: "//id"}}}
+	msg := Message{
+		Headers: http.Header{"Content-Type": {"application/soap+xml; charset=utf-8; action=\"urn:GetUser\""}},
+		Body:    "<a><b></a>",
+		Request: &Request{Method: "POST", Url: "/soap"},
+	}
+	want := []Mismatch{{Field: "body", Expected: "XML", Actual: "XML syntax error on line 1: element <b> closed by </a>"}}
+	if got := matcher.Explain(msg); !reflect.DeepEqual(got, want) {
+		t.Errorf("mismatches:\n got: %#v\nwant: %#v", got, want)
+	}
+}
+
+func TestXMLMatcherValidate(t *testing.T) {
+	no := false
+	for name, matcher := range map[string]Matcher{
+		"empty xpath":      {XPath: []XPathCondition{{Path: ""}}},
+		"unbound prefix":   {XPath: []XPathCondition{{Path: "//u:id"}}},
+		"predicate":        {XPath: []XPathCondition{{Path: "//id[@a=1]"}}},
+		"position":         {XPath: []XPathCondition{{Path: "//id[0]"}}},
+		"unclosed":         {XPath: []XPathCondition{{Path: "//id[@a='1'"}}},
+		"after attribute":  {XPath: []XPathCondition{{Path: "//id/@a/b"}}},
+		"matches":          {XPath: []XPathCondition{{Path: "//id", Matches: "("}}},
+		"absent and equal": {XPath: []XPathCondition{{Path: "//id", Exists: &no, Matches: "1"}}},
+		"xmlEquals":        {XMLEquals: "<a>"},
+		"two roots":        {XMLEquals: "<a/><b/>"},
+	} {
+		if err := matcher.Validate(); err == nil {
+			t.Errorf("Validate %s must return error", name)
+		}
+	}
+}
+
+func TestSOAPFault(t *testing.T) {
+	for _, tt := range [...]struct {
+		name            string
+		fault           SOAPFault
+		wantContentType string
+		wantEnvelope    string
+	}{
+		{
+			name:            "1.1",
+			fault:           SOAPFault{Code: "Client", String: "User <42> not found", Detail: `<e:NotFound xmlns:e="urn:errors"><e:id>42</e:id></e:NotFound>`},
+			wantContentType: "text/xml; charset=utf-8",
+			wantEnvelope: `<?xml version="1.0" encoding="utf-8"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap:Body>
+    <soap:Fault>
+      <faultcode>soap:Client</faultcode>
+      <faultstring>User &lt;42&gt; not found</faultstring>
+      <detail><e:NotFound xmlns:e="urn:errors"><e:id>42</e:id></e:NotFound></detail>
+    </soap:Fault>
+  </soap:Body>
+</soap:Envelope>
+`,
+		},
+		{
+			name:            "1.2",
+			fault:           SOAPFault{Version: SOAP12, String: "Try later"},
+			wantContentType: "application/soap+xml; charset=utf-8",
+			wantEnvelope: `<?xml version="1.0" encoding="utf-8"?>
+<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope">
+  <soap:Body>
+    <soap:Fault>
+      <soap:Code><soap:Value>soap:Receiver</soap:Value></soap:Code>
+      <soap:Reason><soap:Text xml:lang="en">Try later</soap:Text></soap:Reason>
+    </soap:Fault>
+  </soap:Body>
+</soap:Envelope>
+`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.fault.Validate(); err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+			if got := tt.fault.ContentType(); got != tt.wantContentType {
+				t.Errorf("unexpected content type %q", got)
+			}
+			got := tt.fault.Envelope()
+			if got != tt.wantEnvelope {
+				t.Errorf("envelope mismatch:\n got: %s\nwant: %s", got, tt.wantEnvelope)
+			}
+			if _, err := parseXML(got); err != nil {
+				t.Errorf("envelope must be XML: %v", err)
+			}
+		})
+	}
+
+	for name, fault := range map[string]SOAPFault{
+		"version": {Version: "2.0", String: "x"},
+		"string":  {},
+		"code":    {Code: "a b", String: "x"},
+		"detail":  {String: "x", Detail: "<a>"},
+	} {
+		if err := fault.Validate(); err == nil {
+			t.Errorf("Validate %s must return error", name)
+		}
+	}
+}
@@ -0,0 +1,430 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// xmlNode is node of parsed XML document: document itself, element, attribute, or text.
+// Names of elements and attributes have namespace URI in Space.
+type xmlNode struct {
+	kind     xmlKind
+	name     xml.Name
+	attrs    []*xmlNode
+	children []*xmlNode
+	// text is content of text node and value of attribute.
+	text string
+}
+
+type xmlKind int
+
+const (
+	xmlDocument xmlKind = iota
+	xmlElement
+	xmlAttribute
+	xmlText
+)
+
+// parseXML parses XML document, namespace declarations, comments, and processing instructions are dropped.
+func parseXML(data string) (*xmlNode, error) {
+	doc := &xmlNode{kind: xmlDocument}
+	stack := []*xmlNode{doc}
+	dec := xml.NewDecoder(strings.NewReader(data))
+	dec.Strict = true
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		parent := stack[len(stack)-1]
+		switch token := token.(type) {
+		case xml.StartElement:
+			element := &xmlNode{kind: xmlElement, name: token.Name}
+			for _, attr := range token.Attr {
+				if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+					continue
+				}
+				element.attrs = append(element.attrs, &xmlNode{kind: xmlAttribute, name: attr.Name, text: attr.Value})
+			}
+			parent.children = append(parent.children, element)
+			stack = append(stack, element)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if parent.kind == xmlDocument {
+				continue
+			}
+			if n := len(parent.children); n > 0 && parent.children[n-1].kind == xmlText {
+				parent.children[n-1].text += string(token)
+			} else {
+				parent.children = append(parent.children, &xmlNode{kind: xmlText, text: string(token)})
+			}
+		}
+	}
+
+	elements := 0
+	for _, child := range doc.children {
+		if child.kind == xmlElement {
+			elements++
+		}
+	}
+	if elements != 1 {
+		return nil, fmt.Errorf("XML document must have one root element, it has %d", elements)
+	}
+
+	return doc, nil
+}
+
+// value returns text of the node, elements have text of their descendants.
+func (n *xmlNode) value() string {
+	if n.kind == xmlText || n.kind == xmlAttribute {
+		return n.text
+	}
+
+	var b strings.Builder
+	for _, child := range n.children {
+		b.WriteString(child.value())
+	}
+
+	return b.String()
+}
+
+// significant returns child elements and texts except whitespace-only ones.
+func (n *xmlNode) significant() []*xmlNode {
+	var children []*xmlNode
+	for _, child := range n.children {
+		if child.kind == xmlText && strings.TrimSpace(child.text) == "" {
+			continue
+		}
+		children = append(children, child)
+	}
+
+	return children
+}
+
+// xpath is parsed XPath subset: absolute and descendant location paths of element, attribute, and text() steps
+// with position, attribute, and child element predicates, e.g. //soap:Body/GetUser[@version='2']/id/text().
+type xpath []xpathStep
+
+type xpathStep struct {
+	// descendant step follows //, it selects among descendants of context nodes rather than their children.
+	descendant bool
+	kind       xmlKind
+	test       xmlNameTest
+	predicates []xpathPredicate
+}
+
+// xmlNameTest matches names, * matches any local name, and any namespace matches unprefixed names.
+type xmlNameTest struct {
+	space    string
+	anySpace bool
+	local    string
+}
+
+func (t xmlNameTest) matches(name xml.Name) bool {
+	return (t.anySpace || t.space == name.Space) && (t.local == "*" || t.local == name.Local)
+}
+
+// xpathPredicate selects nodes by position, or by presence or value of attribute or child element.
+type xpathPredicate struct {
+	position  int
+	attribute bool
+	test      xmlNameTest
+	value     *string
+}
+
+// parseXPath parses the expression, prefixes of names must be bound in namespaces.
+func parseXPath(expr string, namespaces map[string]string) (xpath, error) {
+	rest := strings.TrimSpace(expr)
+	if rest == "" {
+		return nil, fmt.Errorf("xpath must not be empty")
+	}
+
+	var path xpath
+	for rest != "" {
+		var step xpathStep
+		switch {
+		case strings.HasPrefix(rest, "//"):
+			step.descendant = true
+			rest = rest[2:]
+		case strings.HasPrefix(rest, "/"):
+			rest = rest[1:]
+		case len(path) > 0:
+			return nil, fmt.Errorf("xpath %q is invalid at %q", expr, rest)
+		}
+
+		end := xpathStepEnd(rest)
+		text := rest[:end]
+		rest = rest[end:]
+		if err := step.parse(text, namespaces); err != nil {
+			return nil, fmt.Errorf("xpath %q: %w", expr, err)
+		}
+		if len(path) > 0 && path[len(path)-1].kind != xmlElement {
+			return nil, fmt.Errorf("xpath %q has steps after %s", expr, text)
+		}
+		path = append(path, step)
+	}
+
+	return path, nil
+}
+
+// xpathStepEnd returns index of slash ending the first step, slashes in predicates are skipped.
+func xpathStepEnd(s string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == '/' && depth == 0:
+			return i
+		}
+	}
+
+	return len(s)
+}
+
+func (s *xpathStep) parse(text string, namespaces map[string]string) error {
+	name := text
+	if start := strings.IndexByte(text, '['); start >= 0 {
+		name = text[:start]
+		predicates := text[start:]
+		for predicates != "" {
+			end := xpathPredicateEnd(predicates)
+			if !strings.HasPrefix(predicates, "[") || end < 0 {
+				return fmt.Errorf("step %q has invalid predicate", text)
+			}
+			predicate, err := parseXPathPredicate(predicates[1:end], namespaces)
+			if err != nil {
+				return err
+			}
+			s.predicates = append(s.predicates, predicate)
+			predicates = predicates[end+1:]
+		}
+	}
+
+	switch {
+	case name == "":
+		return fmt.Errorf("step must not be empty")
+	case name == "text()":
+		s.kind = xmlText
+		if len(s.predicates) > 0 {
+			return fmt.Errorf("step text() must not have predicates")
+		}
+		return nil
+	case strings.HasPrefix(name, "@"):
+		s.kind = xmlAttribute
+		name = name[1:]
+		if len(s.predicates) > 0 {
+			return fmt.Errorf("attribute step @%s must not have predicates", name)
+		}
+	default:
+		s.kind = xmlElement
+	}
+
+	test, err := parseXMLNameTest(name, namespaces)
+	if err != nil {
+		return err
+	}
+	s.test = test
+
+	return nil
+}
+
+// xpathPredicateEnd returns index of bracket closing the predicate the string starts with, or -1.
+func xpathPredicateEnd(s string) int {
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ']':
+			return i
+		}
+	}
+
+	return -1
+}
+
+func parseXPathPredicate(text string, namespaces map[string]string) (xpathPredicate, error) {
+	text = strings.TrimSpace(text)
+	if position, err := strconv.Atoi(text); err == nil {
+		if position < 1 {
+			return xpathPredicate{}, fmt.Errorf("position %d must be positive", position)
+		}
+		return xpathPredicate{position: position}, nil
+	}
+
+	var predicate xpathPredicate
+	name := text
+	if left, right, ok := strings.Cut(text, "="); ok {
+		name = strings.TrimSpace(left)
+		right = strings.TrimSpace(right)
+		if len(right) < 2 || (right[0] != '\'' && right[0] != '"') || right[len(right)-1] != right[0] {
+			return xpathPredicate{}, fmt.Errorf("predicate [%s] must compare with quoted string", text)
+		}
+		value := right[1 : len(right)-1]
+		predicate.value = &value
+	}
+	if strings.HasPrefix(name, "@") {
+		predicate.attribute = true
+		name = name[1:]
+	}
+	test, err := parseXMLNameTest(name, namespaces)
+	if err != nil {
+		return xpathPredicate{}, fmt.Errorf("predicate [%s]: %w", text, err)
+	}
+	predicate.test = test
+
+	return predicate, nil
+}
+
+func parseXMLNameTest(name string, namespaces map[string]string) (xmlNameTest, error) {
+	prefix, local, ok := strings.Cut(name, ":")
+	if !ok {
+		local = prefix
+	}
+	if local == "" || strings.ContainsAny(local, "[]/@=()'\" ") {
+		return xmlNameTest{}, fmt.Errorf("name %q is invalid", name)
+	}
+	if !ok {
+		return xmlNameTest{anySpace: true, local: local}, nil
+	}
+
+	space, bound := namespaces[prefix]
+	if !bound {
+		return xmlNameTest{}, fmt.Errorf("prefix %q of %q is not bound in xmlNamespaces", prefix, name)
+	}
+
+	return xmlNameTest{space: space, local: local}, nil
+}
+
+// find returns nodes selected by the path in the document.
+func (p xpath) find(doc *xmlNode) []*xmlNode {
+	context := []*xmlNode{doc}
+	for _, step := range p {
+		var selected []*xmlNode
+		for _, node := range context {
+			parents := []*xmlNode{node}
+			if step.descendant {
+				parents = node.descendantsOrSelf()
+			}
+			for _, parent := range parents {
+				selected = append(selected, step.apply(parent)...)
+			}
+		}
+		context = selected
+	}
+
+	return context
+}
+
+func (n *xmlNode) descendantsOrSelf() []*xmlNode {
+	nodes := []*xmlNode{n}
+	for _, child := range n.children {
+		if child.kind == xmlElement {
+			nodes = append(nodes, child.descendantsOrSelf()...)
+		}
+	}
+
+	return nodes
+}
+
+// apply returns nodes the step selects among children or attributes of the node.
+func (s xpathStep) apply(node *xmlNode) []*xmlNode {
+	var nodes []*xmlNode
+	switch s.kind {
+	case xmlAttribute:
+		for _, attr := range node.attrs {
+			if s.test.matches(attr.name) {
+				nodes = append(nodes, attr)
+			}
+		}
+		return nodes
+	case xmlText:
+		for _, child := range node.children {
+			if child.kind == xmlText {
+				nodes = append(nodes, child)
+			}
+		}
+		return nodes
+	}
+
+	for _, child := range node.children {
+		if child.kind == xmlElement && s.test.matches(child.name) {
+			nodes = append(nodes, child)
+		}
+	}
+	for _, predicate := range s.predicates {
+		nodes = predicate.filter(nodes)
+	}
+
+	return nodes
+}
+
+func (p xpathPredicate) filter(nodes []*xmlNode) []*xmlNode {
+	if p.position > 0 {
+		if p.position > len(nodes) {
+			return nil
+		}
+		return nodes[p.position-1 : p.position]
+	}
+
+	var filtered []*xmlNode
+	for _, node := range nodes {
+		candidates := node.children
+		if p.attribute {
+			candidates = node.attrs
+		}
+		for _, candidate := range candidates {
+			if candidate.kind == xmlText || !p.test.matches(candidate.name) {
+				continue
+			}
+			if p.value == nil || strings.TrimSpace(candidate.value()) == *p.value {
+				filtered = append(filtered, node)
+				break
+			}
+		}
+	}
+
+	return filtered
+}
+
+// xmlString returns name of the node with namespace URI in braces like {http://example.com/ns}GetUser.
+func xmlString(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+
+	return "{" + name.Space + "}" + name.Local
+}
+
+// xmlEscape returns the text escaped for XML content and attribute values.
+func xmlEscape(text string) string {
+	var b bytes.Buffer
+	if err := xml.EscapeText(&b, []byte(text)); err != nil {
+		return text
+	}
+
+	return b.String()
+}